@@ -1,13 +1,27 @@
 package config
 
-import "github.com/rs/zerolog"
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
 
 const (
 	BlockchainRpcUrlKey = "blockchain.rpc"
 
+	DatabasePostgreSQLKey = "database.pgsql"
+
 	FileSystemRootPathKey = "filesystem.rootPath"
 
+	ServiceScheduleIndexBlockIntervalKey = "service.schedule.indexBlockInterval"
+	ServiceScheduleIndexBlockBatchKey    = "service.schedule.indexBlockBatch"
+
+	ServiceRpcBatchGetBlockKey   = "service.rpcBatch.getBlock"
+	ServiceRpcBatchTraceBlockKey = "service.rpcBatch.traceBlock"
+
 	ServiceWorkerGetBlockKey   = "service.worker.getBlock"
+	ServiceWorkerGetReceiptKey = "service.worker.getReceipt"
+	ServiceWorkerGetTokenKey   = "service.worker.getToken"
 	ServiceWorkerTraceBlockKey = "service.worker.traceBlock"
 )
 
@@ -23,7 +37,47 @@ type RootConfig struct {
 }
 
 type BlockchainConfig struct {
-	RpcUrl string `koanf:"rpc"`
+	Rpc     RpcEndpoints   `koanf:"rpc"`
+	RpcPool *RpcPoolConfig `koanf:"rpcPool"`
+}
+
+type RpcEndpointConfig struct {
+	Url     string `koanf:"url"`
+	Weight  int    `koanf:"weight"`
+	Archive bool   `koanf:"archive"`
+	Debug   bool   `koanf:"debug"`
+}
+
+type RpcEndpoints []*RpcEndpointConfig
+
+// Parse comma-separated list of URLs. All endpoints have the same weight and support all capabilities.
+func (e *RpcEndpoints) UnmarshalText(text []byte) error {
+	endpoints := RpcEndpoints{}
+	for _, url := range strings.Split(string(text), ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		endpoints = append(endpoints, &RpcEndpointConfig{
+			Url:     url,
+			Weight:  1,
+			Archive: true,
+			Debug:   true,
+		})
+	}
+	*e = endpoints
+	return nil
+}
+
+type RpcPoolConfig struct {
+	// Number of blocks an endpoint can fall behind the highest known head before being ejected.
+	MaxBlockLag uint64 `koanf:"maxBlockLag"`
+	// Number of consecutive errors before an endpoint is ejected.
+	MaxFailures int `koanf:"maxFailures"`
+	// Duration in seconds an endpoint stays ejected before being probed again.
+	EjectDuration int64 `koanf:"ejectDuration"`
+	// Interval in seconds between health checks.
+	HealthCheckInterval int64 `koanf:"healthCheckInterval"`
 }
 
 type DatabaseConfig struct {
@@ -40,24 +94,104 @@ type ZeroLogConfig struct {
 }
 
 type ServiceConfig struct {
+	Retry    *RetryConfig     `koanf:"retry"`
+	RpcBatch *RpcBatchConfig  `koanf:"rpcBatch"`
 	Schedule *SchedulerConfig `koanf:"schedule"`
 	Worker   *JobWorkerConfig `koanf:"worker"`
 }
 
+const (
+	JitterNone  = "none"
+	JitterFull  = "full"
+	JitterEqual = "equal"
+)
+
+type RetryConfig struct {
+	GetBlock   *RetryPolicyConfig `koanf:"getBlock"`
+	GetReceipt *RetryPolicyConfig `koanf:"getReceipt"`
+	GetToken   *RetryPolicyConfig `koanf:"getToken"`
+	TraceBlock *RetryPolicyConfig `koanf:"traceBlock"`
+}
+
+// Exponential backoff of a service. Delay of the n-th retry is BaseDelay * Multiplier^(n-1), capped at MaxDelay.
+type RetryPolicyConfig struct {
+	// Number of attempts including the first request.
+	MaxAttempts int `koanf:"maxAttempts"`
+	// Delay in milliseconds before the first retry.
+	BaseDelay  int64   `koanf:"baseDelay"`
+	Multiplier float64 `koanf:"multiplier"`
+	// Maximum delay in milliseconds between retries.
+	MaxDelay int64 `koanf:"maxDelay"`
+	// One of none, full or equal. Full jitter waits a random delay up to the computed one,
+	// equal jitter waits at least half of it.
+	Jitter string `koanf:"jitter"`
+	// Duration in milliseconds after which a request is not retried anymore. Zero means no deadline.
+	Deadline int64 `koanf:"deadline"`
+	// Retry of transient RPC error classes keyed by class name: RATE_LIMITED, TIMEOUT, CONNECTION_REFUSED or UNKNOWN.
+	// Errors caused by the request itself (JSON_RPC, NOT_FOUND and DECODE) are never retried.
+	Classes map[string]*ErrorClassRetryConfig `koanf:"classes"`
+}
+
+// Retry of one class of RPC errors. Attempts are capped at the attempts of the service, zero means the same attempts.
+// Every delay of the class is multiplied by DelayFactor.
+type ErrorClassRetryConfig struct {
+	MaxAttempts int     `koanf:"maxAttempts"`
+	DelayFactor float64 `koanf:"delayFactor"`
+}
+
+// Default delay factors per error class. Rate limited endpoints need the longest pause to recover.
+const (
+	RateLimitedDelayFactor       = 4
+	TimeoutDelayFactor           = 2
+	ConnectionRefusedDelayFactor = 1
+	UnknownDelayFactor           = 1
+)
+
+func defaultRetryClasses() map[string]*ErrorClassRetryConfig {
+	return map[string]*ErrorClassRetryConfig{
+		"RATE_LIMITED":       {DelayFactor: RateLimitedDelayFactor},
+		"TIMEOUT":            {DelayFactor: TimeoutDelayFactor},
+		"CONNECTION_REFUSED": {DelayFactor: ConnectionRefusedDelayFactor},
+		"UNKNOWN":            {DelayFactor: UnknownDelayFactor},
+	}
+}
+
+// Number of requests sent in one JSON-RPC batch. Values less than 2 disable batching.
+type RpcBatchConfig struct {
+	GetBlock   int `koanf:"getBlock"`
+	TraceBlock int `koanf:"traceBlock"`
+}
+
 type SchedulerConfig struct {
+	// Interval in seconds between checking for new blocks.
 	IndexBlockInterval int64 `koanf:"indexBlockInterval"`
 	IndexBlockBatch    int   `koanf:"indexBlockBatch"`
 }
 
 type JobWorkerConfig struct {
 	GetBlock   uint64 `koanf:"getBlock"`
+	GetReceipt uint64 `koanf:"getReceipt"`
+	GetToken   uint64 `koanf:"getToken"`
 	TraceBlock uint64 `koanf:"traceBlock"`
 }
 
 func DefaultRootConfig() *RootConfig {
 	return &RootConfig{
 		Blockchain: &BlockchainConfig{
-			RpcUrl: "http://localhost:8545",
+			Rpc: RpcEndpoints{
+				{
+					Url:     "http://localhost:8545",
+					Weight:  1,
+					Archive: true,
+					Debug:   true,
+				},
+			},
+			RpcPool: &RpcPoolConfig{
+				MaxBlockLag:         30,
+				MaxFailures:         3,
+				EjectDuration:       30,
+				HealthCheckInterval: 15,
+			},
 		},
 		Database:   &DatabaseConfig{},
 		FileSystem: &FileSystemConfig{},
@@ -66,12 +200,56 @@ func DefaultRootConfig() *RootConfig {
 			ConsoleLevel: int8(zerolog.DebugLevel),
 		},
 		Service: &ServiceConfig{
+			Retry: &RetryConfig{
+				GetBlock: &RetryPolicyConfig{
+					MaxAttempts: 4,
+					BaseDelay:   100,
+					Multiplier:  2,
+					MaxDelay:    2000,
+					Jitter:      JitterFull,
+					Deadline:    30000,
+					Classes:     defaultRetryClasses(),
+				},
+				GetReceipt: &RetryPolicyConfig{
+					MaxAttempts: 4,
+					BaseDelay:   100,
+					Multiplier:  2,
+					MaxDelay:    2000,
+					Jitter:      JitterFull,
+					Deadline:    30000,
+					Classes:     defaultRetryClasses(),
+				},
+				GetToken: &RetryPolicyConfig{
+					MaxAttempts: 4,
+					BaseDelay:   100,
+					Multiplier:  2,
+					MaxDelay:    2000,
+					Jitter:      JitterFull,
+					Deadline:    30000,
+					Classes:     defaultRetryClasses(),
+				},
+				TraceBlock: &RetryPolicyConfig{
+					MaxAttempts: 6,
+					BaseDelay:   1000,
+					Multiplier:  2,
+					MaxDelay:    30000,
+					Jitter:      JitterEqual,
+					Deadline:    300000,
+					Classes:     defaultRetryClasses(),
+				},
+			},
+			RpcBatch: &RpcBatchConfig{
+				GetBlock:   1,
+				TraceBlock: 1,
+			},
 			Schedule: &SchedulerConfig{
 				IndexBlockInterval: 30,
 				IndexBlockBatch:    900,
 			},
 			Worker: &JobWorkerConfig{
 				GetBlock:   8,
+				GetReceipt: 8,
+				GetToken:   4,
 				TraceBlock: 8,
 			},
 		},
@@ -1,10 +1,12 @@
 package db
 
 import (
+	"math"
 	"math/big"
 
 	"github.com/gurukami/typ"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type Block struct {
@@ -19,20 +21,42 @@ type Block struct {
 	TotalDifficulty        decimal.Decimal `gorm:"column:total_difficulty;type:decimal(78,0)"`
 	TransactionCount       typ.NullUint16  `gorm:"column:transaction_count"`
 	TransactionCountSystem typ.NullUint16  `gorm:"column:transaction_count_system"`
-	TransactionCountDebug  typ.NullUint16  `gorm:"column:transaction_count_debug"`
-	BlockMintDuration      typ.NullUint64  `gorm:"column:block_mint_duration"`
-	UncleHash              []byte          `gorm:"column:uncle_hash;length:32"`
-	StateRoot              []byte          `gorm:"column:state_root;length:32"`
-	TransactionsRoot       []byte          `gorm:"column:transaction_root;length:32"`
-	ReceiptsRoot           []byte          `gorm:"column:receipts_root;length:32"`
-	LogsBloom              []byte          `gorm:"column:logs_bloom;length:256"`
-	Miner                  []byte          `gorm:"column:miner;length:20"`
-	ExtraData              []byte          `gorm:"column:extra_data"`
-	MixDigest              []byte          `gorm:"column:mix_digest"`
-	Nonce                  []byte          `gorm:"column:nonce"`
-	Validator              []byte          `gorm:"column:validator"`
-	Creator                typ.NullString  `gorm:"column:creator"`
-	Attestor               typ.NullString  `gorm:"column:attestor"`
+	// Number of transactions of every system category. Their sum is TransactionCountSystem.
+	TransactionCountSign              typ.NullUint16 `gorm:"column:transaction_count_sign"`
+	TransactionCountRandomize         typ.NullUint16 `gorm:"column:transaction_count_randomize"`
+	TransactionCountTomoX             typ.NullUint16 `gorm:"column:transaction_count_tomox"`
+	TransactionCountTomoXTradingState typ.NullUint16 `gorm:"column:transaction_count_tomox_trading_state"`
+	TransactionCountLending           typ.NullUint16 `gorm:"column:transaction_count_lending"`
+	TransactionCountFinalLending      typ.NullUint16 `gorm:"column:transaction_count_final_lending"`
+	TransactionCountDebug             typ.NullUint16 `gorm:"column:transaction_count_debug"`
+	BlockMintDuration                 typ.NullUint64 `gorm:"column:block_mint_duration"`
+	UncleHash                         []byte         `gorm:"column:uncle_hash;length:32"`
+	StateRoot                         []byte         `gorm:"column:state_root;length:32"`
+	TransactionsRoot                  []byte         `gorm:"column:transaction_root;length:32"`
+	ReceiptsRoot                      []byte         `gorm:"column:receipts_root;length:32"`
+	LogsBloom                         []byte         `gorm:"column:logs_bloom;length:256"`
+	Miner                             []byte         `gorm:"column:miner;length:20"`
+	ExtraData                         []byte         `gorm:"column:extra_data"`
+	MixDigest                         []byte         `gorm:"column:mix_digest"`
+	Nonce                             []byte         `gorm:"column:nonce"`
+	Validator                         []byte         `gorm:"column:validator"`
+	Validators                        []byte         `gorm:"column:validators"`
+	Penalties                         []byte         `gorm:"column:penalties"`
+	Creator                           typ.NullString `gorm:"column:creator"`
+	Attestor                          typ.NullString `gorm:"column:attestor"`
+}
+
+type RollbackSummary struct {
+	BlockCount               int64
+	TransactionCount         int64
+	InternalTransactionCount int64
+	ReceiptCount             int64
+	LogCount                 int64
+	TokenTransferCount       int64
+	BlockSignatureCount      int64
+	EpochCount               int64
+	ValidatorStatCount       int64
+	IssueCount               int64
 }
 
 func NewBlock(blockNumber *big.Int, blockHash, parentHash string, timestamp int64, size uint16, gasLimit, gasUsed uint64, difficulty, totalDifficulty *big.Int,
@@ -84,6 +108,11 @@ func (c *DbClient) GetBlocksByHashes(hashes []string) ([]*Block, error) {
 	return c.findBlocksByHashes(hashes)
 }
 
+// Return blocks between from and to inclusively ordered by number.
+func (c *DbClient) GetBlocksInRange(from, to uint64) ([]*Block, error) {
+	return c.findBlocksInRange(from, to)
+}
+
 func (c *DbClient) SaveBlock(newBlock *Block) error {
 	block, err := c.findBlock(newBlock.ID)
 	if err != nil {
@@ -101,8 +130,80 @@ func (c *DbClient) SaveBlock(newBlock *Block) error {
 	return c.updateBlockByID(block.ID, block)
 }
 
-func (c *DbClient) SaveBlocks(newBlocks []*Block, chnagedBlocks []*Block) error {
-	return c.writeBlocks(newBlocks, chnagedBlocks)
+// BlockData is data decoded from blocks, saved in the same transaction as the blocks.
+type BlockData struct {
+	// Blocks whose signatures are replaced. Signatures are left untouched if empty.
+	SignatureBlockIDs []uint64
+	Signatures        []*BlockSignature
+	Epochs            []*Epoch
+	EpochValidators   []*EpochValidator
+	EpochPenalties    []*EpochPenalty
+}
+
+// Insert new blocks, update changed blocks and replace data decoded from them in one transaction. Data can be nil.
+// Data derived from new blocks and blocks whose hash changed is stale, so derived checkpoints are lowered below the lowest
+// of them. Rewriting a block with the stored hash leaves checkpoints unchanged.
+func (c *DbClient) SaveBlocks(newBlocks []*Block, chnagedBlocks []*Block, data *BlockData) error {
+	return c.writeBlocks(newBlocks, chnagedBlocks, data)
+}
+
+// Delete all blocks above `number` with their transactions and lower checkpoints to `number`.
+// Issues are saved in the same transaction.
+func (c *DbClient) RevertBlocks(number uint64, issues []*Issue) error {
+	return c.revertBlocks(number, issues)
+}
+
+// Delete blocks and their transactions, internal transactions, receipts, logs, token transfers, block signatures, epochs and validator statistics above number and lower all checkpoints above number to number in one transaction.
+// Issues of blocks above number are deleted only if removeIssues is set.
+func (c *DbClient) RollbackBlocks(number uint64, removeIssues bool) error {
+	return c.rollbackBlocks(number, removeIssues)
+}
+
+// Return number of blocks, transactions and issues above number.
+func (c *DbClient) GetRollbackSummary(number uint64) (*RollbackSummary, error) {
+	summary := &RollbackSummary{}
+	var err error
+	summary.BlockCount, err = c.countBlocksAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.TransactionCount, err = c.countTransactionsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.InternalTransactionCount, err = c.countInternalTransactionsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.ReceiptCount, err = c.countReceiptsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.LogCount, err = c.countLogsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.TokenTransferCount, err = c.countTokenTransfersAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.BlockSignatureCount, err = c.countBlockSignaturesAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.EpochCount, err = c.countEpochsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.ValidatorStatCount, err = c.countValidatorStatsAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	summary.IssueCount, err = c.countIssuesAbove(number)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
 }
 
 func (c *DbClient) findBlock(id uint64) (*Block, error) {
@@ -124,6 +225,15 @@ func (c *DbClient) findBlocks(ids []uint64) ([]*Block, error) {
 	return docs, result.Error
 }
 
+func (c *DbClient) findBlocksInRange(from, to uint64) ([]*Block, error) {
+	var docs []*Block
+	result := c.d.Model(&Block{}).
+		Where("id BETWEEN ? AND ?", from, to).
+		Order("id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
 func (c *DbClient) findBlockByHash(hash string) (*Block, error) {
 	var doc *Block
 	result := c.d.Model(&Block{}).
@@ -152,77 +262,227 @@ func (c *DbClient) updateBlockByID(id uint64, block *Block) error {
 	result := c.d.Model(&Block{}).
 		Where("id = ?", id).
 		Updates(map[string]interface{}{
-			"hash":                     block.Hash,
-			"parent_hash":              block.ParentHash,
-			"timestamp":                block.Timestamp,
-			"size":                     block.Size,
-			"gas_limit":                block.GasLimit,
-			"gas_used":                 block.GasUsed,
-			"difficulty":               block.Difficulty,
-			"total_difficulty":         block.TotalDifficulty,
-			"transaction_count":        block.TransactionCount,
-			"transaction_count_system": block.TransactionCountSystem,
-			"transaction_count_debug":  block.TransactionCountDebug,
-			"block_mint_duration":      block.BlockMintDuration,
-			"uncle_hash":               block.UncleHash,
-			"state_root":               block.StateRoot,
-			"transaction_root":         block.TransactionsRoot,
-			"receipts_root":            block.ReceiptsRoot,
-			"logs_bloom":               block.LogsBloom,
-			"miner":                    block.Miner,
-			"extra_data":               block.ExtraData,
-			"mix_digest":               block.MixDigest,
-			"nonce":                    block.Nonce,
-			"validator":                block.Validator,
-			"creator":                  block.Creator,
-			"attestor":                 block.Attestor,
+			"hash":                                  block.Hash,
+			"parent_hash":                           block.ParentHash,
+			"timestamp":                             block.Timestamp,
+			"size":                                  block.Size,
+			"gas_limit":                             block.GasLimit,
+			"gas_used":                              block.GasUsed,
+			"difficulty":                            block.Difficulty,
+			"total_difficulty":                      block.TotalDifficulty,
+			"transaction_count":                     block.TransactionCount,
+			"transaction_count_system":              block.TransactionCountSystem,
+			"transaction_count_sign":                block.TransactionCountSign,
+			"transaction_count_randomize":           block.TransactionCountRandomize,
+			"transaction_count_tomox":               block.TransactionCountTomoX,
+			"transaction_count_tomox_trading_state": block.TransactionCountTomoXTradingState,
+			"transaction_count_lending":             block.TransactionCountLending,
+			"transaction_count_final_lending":       block.TransactionCountFinalLending,
+			"transaction_count_debug":               block.TransactionCountDebug,
+			"block_mint_duration":                   block.BlockMintDuration,
+			"uncle_hash":                            block.UncleHash,
+			"state_root":                            block.StateRoot,
+			"transaction_root":                      block.TransactionsRoot,
+			"receipts_root":                         block.ReceiptsRoot,
+			"logs_bloom":                            block.LogsBloom,
+			"miner":                                 block.Miner,
+			"extra_data":                            block.ExtraData,
+			"mix_digest":                            block.MixDigest,
+			"nonce":                                 block.Nonce,
+			"validator":                             block.Validator,
+			"validators":                            block.Validators,
+			"penalties":                             block.Penalties,
+			"creator":                               block.Creator,
+			"attestor":                              block.Attestor,
 		})
 	return result.Error
 }
 
-func (c *DbClient) writeBlocks(newBlocks []*Block, changedBlocks []*Block) error {
+func (c *DbClient) writeBlocks(newBlocks []*Block, changedBlocks []*Block, data *BlockData) error {
 	tx := c.d.Begin()
+	// Lowest block whose data differs from what derived checkpoints were built on.
+	lowestID := uint64(math.MaxUint64)
 	for _, block := range newBlocks {
 		result := tx.Create(block)
 		if result.Error != nil {
 			tx.Rollback()
 			return result.Error
 		}
+		lowestID = min(lowestID, block.ID)
+	}
+	if len(changedBlocks) > 0 {
+		ids := make([]uint64, len(changedBlocks))
+		for i, block := range changedBlocks {
+			ids[i] = block.ID
+		}
+		storedBlocks := []*Block{}
+		result := tx.Select("id", "hash").Where("id IN ?", ids).Find(&storedBlocks)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+		storedHashes := map[uint64]string{}
+		for _, block := range storedBlocks {
+			storedHashes[block.ID] = block.Hash
+		}
+		for _, block := range changedBlocks {
+			if storedHash, ok := storedHashes[block.ID]; !ok || storedHash != block.Hash {
+				lowestID = min(lowestID, block.ID)
+			}
+		}
 	}
 	for _, block := range changedBlocks {
 		result := tx.Model(&Block{}).
 			Where("id = ?", block.ID).
 			Updates(map[string]interface{}{
-				"hash":                     block.Hash,
-				"parent_hash":              block.ParentHash,
-				"timestamp":                block.Timestamp,
-				"size":                     block.Size,
-				"gas_limit":                block.GasLimit,
-				"gas_used":                 block.GasUsed,
-				"difficulty":               block.Difficulty,
-				"total_difficulty":         block.TotalDifficulty,
-				"transaction_count":        block.TransactionCount,
-				"transaction_count_system": block.TransactionCountSystem,
-				"transaction_count_debug":  block.TransactionCountDebug,
-				"block_mint_duration":      block.BlockMintDuration,
-				"uncle_hash":               block.UncleHash,
-				"state_root":               block.StateRoot,
-				"transaction_root":         block.TransactionsRoot,
-				"receipts_root":            block.ReceiptsRoot,
-				"logs_bloom":               block.LogsBloom,
-				"miner":                    block.Miner,
-				"extra_data":               block.ExtraData,
-				"mix_digest":               block.MixDigest,
-				"nonce":                    block.Nonce,
-				"validator":                block.Validator,
-				"creator":                  block.Creator,
-				"attestor":                 block.Attestor,
+				"hash":                                  block.Hash,
+				"parent_hash":                           block.ParentHash,
+				"timestamp":                             block.Timestamp,
+				"size":                                  block.Size,
+				"gas_limit":                             block.GasLimit,
+				"gas_used":                              block.GasUsed,
+				"difficulty":                            block.Difficulty,
+				"total_difficulty":                      block.TotalDifficulty,
+				"transaction_count":                     block.TransactionCount,
+				"transaction_count_system":              block.TransactionCountSystem,
+				"transaction_count_sign":                block.TransactionCountSign,
+				"transaction_count_randomize":           block.TransactionCountRandomize,
+				"transaction_count_tomox":               block.TransactionCountTomoX,
+				"transaction_count_tomox_trading_state": block.TransactionCountTomoXTradingState,
+				"transaction_count_lending":             block.TransactionCountLending,
+				"transaction_count_final_lending":       block.TransactionCountFinalLending,
+				"transaction_count_debug":               block.TransactionCountDebug,
+				"block_mint_duration":                   block.BlockMintDuration,
+				"uncle_hash":                            block.UncleHash,
+				"state_root":                            block.StateRoot,
+				"transaction_root":                      block.TransactionsRoot,
+				"receipts_root":                         block.ReceiptsRoot,
+				"logs_bloom":                            block.LogsBloom,
+				"miner":                                 block.Miner,
+				"extra_data":                            block.ExtraData,
+				"mix_digest":                            block.MixDigest,
+				"nonce":                                 block.Nonce,
+				"validator":                             block.Validator,
+				"validators":                            block.Validators,
+				"penalties":                             block.Penalties,
+				"creator":                               block.Creator,
+				"attestor":                              block.Attestor,
 			})
 		if result.Error != nil {
 			tx.Rollback()
 			return result.Error
 		}
 	}
-	tx.Commit()
-	return nil
+	if data != nil && len(data.SignatureBlockIDs) > 0 {
+		err := replaceBlockSignatures(tx, data.SignatureBlockIDs, data.Signatures)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if data != nil && len(data.Epochs) > 0 {
+		err := replaceEpochs(tx, data.Epochs, data.EpochValidators, data.EpochPenalties)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if lowestID != math.MaxUint64 {
+		for _, typ := range derivedCheckpoints {
+			err := lowerCheckpoint(tx, typ, max(lowestID, 1)-1)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (c *DbClient) revertBlocks(number uint64, issues []*Issue) error {
+	tx := c.d.Begin()
+	err := deleteBlocksAbove(tx, number)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(issues) > 0 {
+		stampIssues(issues)
+		result := tx.CreateInBatches(issues, len(issues))
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (c *DbClient) rollbackBlocks(number uint64, removeIssues bool) error {
+	tx := c.d.Begin()
+	err := deleteBlocksAbove(tx, number)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if removeIssues {
+		result := tx.Where("block_number > ?", number).
+			Delete(&Issue{})
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (c *DbClient) countBlocksAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Block{}).
+		Where("id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+// Delete blocks and their transactions, internal transactions, receipts, logs, token transfers, block signatures, epochs and validator statistics above number and lower checkpoints above number to number.
+func deleteBlocksAbove(tx *gorm.DB, number uint64) error {
+	err := deleteInternalTransactionsAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	err = deleteReceiptsAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	err = deleteTokenTransfersAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	err = deleteBlockSignaturesAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	err = deleteEpochsAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	err = deleteValidatorStatsAbove(tx, number)
+	if err != nil {
+		return err
+	}
+	result := tx.Where("block_id > ?", number).
+		Delete(&Transaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("id > ?", number).
+		Delete(&Block{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Model(&Checkpoint{}).
+		Where("block_number > ?", number).
+		Updates(map[string]interface{}{
+			"block_number": number,
+		})
+	return result.Error
 }
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"github.com/gurukami/typ"
+	"gorm.io/gorm"
+)
+
+// BlockSignature is a masternode signature of a block, sent as a transaction to the block-signing contract.
+// BlockID is the block including the signing transaction, SignedBlockID is the block being signed.
+type BlockSignature struct {
+	ID              uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	BlockID         uint64 `gorm:"column:block_id;index"`
+	TransactionHash string `gorm:"column:transaction_hash;length:32;index"`
+	Signer          string `gorm:"column:signer;length:20;index"`
+	SignedBlockID   uint64 `gorm:"column:signed_block_id;index"`
+	SignedBlockHash string `gorm:"column:signed_block_hash;length:32"`
+	// Receipt status of the signing transaction. Null until receipts of BlockID are indexed.
+	Status typ.NullUint16 `gorm:"column:status"`
+}
+
+// Replace signatures included in blocks in `blockIDs`.
+func (c *DbClient) SaveBlockSignatures(blockIDs []uint64, signatures []*BlockSignature) error {
+	return c.writeBlockSignatures(blockIDs, signatures)
+}
+
+// Return signatures of signed blocks between from and to inclusively ordered by signed block number.
+func (c *DbClient) GetBlockSignaturesBySignedRange(from, to uint64) ([]*BlockSignature, error) {
+	return c.findBlockSignaturesBySignedRange(from, to)
+}
+
+func (c *DbClient) findBlockSignaturesBySignedRange(from, to uint64) ([]*BlockSignature, error) {
+	var docs []*BlockSignature
+	result := c.d.Model(&BlockSignature{}).
+		Where("signed_block_id BETWEEN ? AND ?", from, to).
+		Order("signed_block_id ASC, block_id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countBlockSignaturesAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&BlockSignature{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeBlockSignatures(blockIDs []uint64, signatures []*BlockSignature) error {
+	tx := c.d.Begin()
+	err := replaceBlockSignatures(tx, blockIDs, signatures)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func replaceBlockSignatures(tx *gorm.DB, blockIDs []uint64, signatures []*BlockSignature) error {
+	result := tx.Where("block_id IN ?", blockIDs).
+		Delete(&BlockSignature{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(signatures) > 0 {
+		result = tx.CreateInBatches(signatures, 1000)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	// Receipts may be indexed before blocks are written again.
+	return updateBlockSignatureStatus(tx, blockIDs)
+}
+
+// Copy receipt status of signing transactions in blocks in `blockIDs` from stored receipts.
+func updateBlockSignatureStatus(tx *gorm.DB, blockIDs []uint64) error {
+	result := tx.Exec(`UPDATE block_signatures AS s SET status = r.status FROM receipts AS r
+		WHERE s.block_id IN ? AND r.block_id = s.block_id AND r.transaction_hash = s.transaction_hash`, blockIDs)
+	return result.Error
+}
+
+func deleteBlockSignaturesAbove(tx *gorm.DB, number uint64) error {
+	result := tx.Where("block_id > ?", number).
+		Delete(&BlockSignature{})
+	return result.Error
+}
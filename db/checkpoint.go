@@ -2,11 +2,19 @@ package db
 
 import (
 	"math/big"
+
+	"gorm.io/gorm"
 )
 
 const (
 	INDEX_CHECKPOINT = iota
 	TRACE_CHECKPOINT
+	RECEIPT_CHECKPOINT
+	TOKEN_CHECKPOINT
+	VALIDATOR_CHECKPOINT
+	// Highest block imported from filesystem. Imported blocks are not checked against the live chain,
+	// so they never move the index checkpoint.
+	IMPORT_CHECKPOINT
 )
 
 type Checkpoint struct {
@@ -23,6 +31,22 @@ func (c *DbClient) GetHighestTraceBlock() (*Checkpoint, error) {
 	return c.findBlockByType(TRACE_CHECKPOINT)
 }
 
+func (c *DbClient) GetHighestReceiptBlock() (*Checkpoint, error) {
+	return c.findBlockByType(RECEIPT_CHECKPOINT)
+}
+
+func (c *DbClient) GetHighestTokenBlock() (*Checkpoint, error) {
+	return c.findBlockByType(TOKEN_CHECKPOINT)
+}
+
+func (c *DbClient) GetHighestValidatorBlock() (*Checkpoint, error) {
+	return c.findBlockByType(VALIDATOR_CHECKPOINT)
+}
+
+func (c *DbClient) GetHighestImportBlock() (*Checkpoint, error) {
+	return c.findBlockByType(IMPORT_CHECKPOINT)
+}
+
 func (c *DbClient) SaveHighestIndexBlock(number *big.Int) error {
 	checkpoint, err := c.GetHighestIndexBlock()
 	if err != nil {
@@ -61,6 +85,82 @@ func (c *DbClient) SaveHighestTraceBlock(number *big.Int) error {
 	return c.updateCheckpointByType(TRACE_CHECKPOINT, checkpoint)
 }
 
+func (c *DbClient) SaveHighestReceiptBlock(number *big.Int) error {
+	checkpoint, err := c.GetHighestReceiptBlock()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{
+			Type:        RECEIPT_CHECKPOINT,
+			BlockNumber: number.Uint64(),
+		}
+		return c.insertCheckpoint(checkpoint)
+	}
+	if checkpoint.BlockNumber == number.Uint64() {
+		return nil
+	}
+	checkpoint.BlockNumber = number.Uint64()
+	return c.updateCheckpointByType(RECEIPT_CHECKPOINT, checkpoint)
+}
+
+func (c *DbClient) SaveHighestTokenBlock(number *big.Int) error {
+	checkpoint, err := c.GetHighestTokenBlock()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{
+			Type:        TOKEN_CHECKPOINT,
+			BlockNumber: number.Uint64(),
+		}
+		return c.insertCheckpoint(checkpoint)
+	}
+	if checkpoint.BlockNumber == number.Uint64() {
+		return nil
+	}
+	checkpoint.BlockNumber = number.Uint64()
+	return c.updateCheckpointByType(TOKEN_CHECKPOINT, checkpoint)
+}
+
+func (c *DbClient) SaveHighestValidatorBlock(number *big.Int) error {
+	checkpoint, err := c.GetHighestValidatorBlock()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{
+			Type:        VALIDATOR_CHECKPOINT,
+			BlockNumber: number.Uint64(),
+		}
+		return c.insertCheckpoint(checkpoint)
+	}
+	if checkpoint.BlockNumber == number.Uint64() {
+		return nil
+	}
+	checkpoint.BlockNumber = number.Uint64()
+	return c.updateCheckpointByType(VALIDATOR_CHECKPOINT, checkpoint)
+}
+
+func (c *DbClient) SaveHighestImportBlock(number *big.Int) error {
+	checkpoint, err := c.GetHighestImportBlock()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{
+			Type:        IMPORT_CHECKPOINT,
+			BlockNumber: number.Uint64(),
+		}
+		return c.insertCheckpoint(checkpoint)
+	}
+	if checkpoint.BlockNumber == number.Uint64() {
+		return nil
+	}
+	checkpoint.BlockNumber = number.Uint64()
+	return c.updateCheckpointByType(IMPORT_CHECKPOINT, checkpoint)
+}
+
 func (c *DbClient) findBlockByType(typ uint16) (*Checkpoint, error) {
 	var doc *Checkpoint
 	result := c.d.Model(&Checkpoint{}).
@@ -85,3 +185,16 @@ func (c *DbClient) updateCheckpointByType(typ uint16, checkpoint *Checkpoint) er
 		})
 	return result.Error
 }
+
+// Checkpoints of data derived from stored blocks.
+var derivedCheckpoints = []uint16{TRACE_CHECKPOINT, RECEIPT_CHECKPOINT, TOKEN_CHECKPOINT, VALIDATOR_CHECKPOINT}
+
+// Lower checkpoint of type typ to number. Checkpoints at or below number are left unchanged.
+func lowerCheckpoint(tx *gorm.DB, typ uint16, number uint64) error {
+	result := tx.Model(&Checkpoint{}).
+		Where("type = ? AND block_number > ?", typ, number).
+		Updates(map[string]interface{}{
+			"block_number": number,
+		})
+	return result.Error
+}
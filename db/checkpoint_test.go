@@ -28,6 +28,56 @@ func TestGetHighestBlock(t *testing.T) {
 	}
 }
 
+func TestSaveBlocksLowerDerivedCheckpoints(t *testing.T) {
+	db, err := Connect(TEST_CONNECTION, "")
+	if err != nil {
+		t.Fatalf("Error while connecting to database. %v", err)
+	}
+	defer db.Disconnect()
+	err = db.d.Where("id >= ?", 900000).Delete(&Block{}).Error
+	if err != nil {
+		t.Fatalf("Error while deleting blocks. %v", err)
+	}
+	saves := []func(*big.Int) error{db.SaveHighestTraceBlock, db.SaveHighestReceiptBlock, db.SaveHighestTokenBlock, db.SaveHighestValidatorBlock}
+	gets := []func() (*Checkpoint, error){db.GetHighestTraceBlock, db.GetHighestReceiptBlock, db.GetHighestTokenBlock, db.GetHighestValidatorBlock}
+
+	tests := []struct {
+		Name    string
+		New     []*Block
+		Changed []*Block
+		Number  uint64
+	}{
+		{"new_below", []*Block{{ID: 900050, Hash: "a1"}}, nil, 900049},
+		{"new_above", []*Block{{ID: 900150, Hash: "b1"}}, nil, 900100},
+		{"unchanged", nil, []*Block{{ID: 900050, Hash: "a1"}}, 900100},
+		{"reorged_above", nil, []*Block{{ID: 900150, Hash: "b2"}}, 900100},
+		{"reorged_below", nil, []*Block{{ID: 900050, Hash: "a2"}}, 900049},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			for _, save := range saves {
+				err := save(big.NewInt(900100))
+				if err != nil {
+					t.Fatalf("Error while saving checkpoint. %v", err)
+				}
+			}
+			err := db.SaveBlocks(tt.New, tt.Changed, nil)
+			if err != nil {
+				t.Fatalf("Error while saving blocks. %v", err)
+			}
+			for _, get := range gets {
+				checkpoint, err := get()
+				if err != nil {
+					t.Fatalf("Error while getting checkpoint. %v", err)
+				}
+				if checkpoint.BlockNumber != tt.Number {
+					t.Fatalf("Checkpoint %d mismatch. Expected '%d' Actual '%d'", checkpoint.Type, tt.Number, checkpoint.BlockNumber)
+				}
+			}
+		})
+	}
+}
+
 func prepareDatabaseForBlocks() *DbClient {
 	db, err := Connect(TEST_CONNECTION, "")
 	if err != nil {
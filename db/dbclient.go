@@ -34,7 +34,14 @@ func (c *DbClient) Disconnect() {
 }
 
 func (c *DbClient) Migrate() error {
-	return c.d.AutoMigrate(&Block{}, &Checkpoint{}, &Issue{}, &Transaction{})
+	// Receipts used to be unique by transaction hash.
+	if c.d.Migrator().HasIndex(&Receipt{}, "idx_receipts_transaction_hash") {
+		err := c.d.Migrator().DropIndex(&Receipt{}, "idx_receipts_transaction_hash")
+		if err != nil {
+			return err
+		}
+	}
+	return c.d.AutoMigrate(&Block{}, &BlockSignature{}, &Checkpoint{}, &Epoch{}, &EpochPenalty{}, &EpochValidator{}, &InternalTransaction{}, &Issue{}, &Log{}, &MissedSlot{}, &Receipt{}, &Token{}, &TokenTransfer{}, &Transaction{}, &ValidatorStat{})
 }
 
 func (c *DbClient) isEmptyResultError(err error) bool {
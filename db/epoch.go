@@ -0,0 +1,157 @@
+package db
+
+import (
+	"github.com/gurukami/typ"
+	"gorm.io/gorm"
+)
+
+// Epoch is decoded from the checkpoint block opening it. ID is the epoch number.
+type Epoch struct {
+	ID                  uint64 `gorm:"column:id;primaryKey"`
+	CheckpointBlockID   uint64 `gorm:"column:checkpoint_block_id;uniqueIndex"`
+	CheckpointBlockHash string `gorm:"column:checkpoint_block_hash;length:32"`
+	Timestamp           int64  `gorm:"column:timestamp"`
+	ValidatorCount      uint16 `gorm:"column:validator_count"`
+	PenaltyCount        uint16 `gorm:"column:penalty_count"`
+}
+
+// EpochValidator is a masternode listed in the checkpoint block in list order.
+// M2Index is the position of its double validator in the same list before rotation,
+// null if the checkpoint block does not assign one.
+type EpochValidator struct {
+	ID       uint64         `gorm:"column:id;primaryKey;autoIncrement"`
+	EpochID  uint64         `gorm:"column:epoch_id;index"`
+	Position uint16         `gorm:"column:position"`
+	Address  string         `gorm:"column:address;length:20;index"`
+	M2Index  typ.NullUint16 `gorm:"column:m2_index"`
+}
+
+// EpochPenalty is a masternode penalized at the checkpoint block.
+type EpochPenalty struct {
+	ID      uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	EpochID uint64 `gorm:"column:epoch_id;index"`
+	Address string `gorm:"column:address;length:20;index"`
+}
+
+// Replace epochs with their validators and penalties.
+func (c *DbClient) SaveEpochs(epochs []*Epoch, validators []*EpochValidator, penalties []*EpochPenalty) error {
+	return c.writeEpochs(epochs, validators, penalties)
+}
+
+// Return epochs between from and to inclusively ordered by number.
+func (c *DbClient) GetEpochsInRange(from, to uint64) ([]*Epoch, error) {
+	return c.findEpochsInRange(from, to)
+}
+
+// Return validators of epochs between from and to inclusively ordered by epoch and position.
+func (c *DbClient) GetEpochValidatorsInRange(from, to uint64) ([]*EpochValidator, error) {
+	return c.findEpochValidatorsInRange(from, to)
+}
+
+// Return penalties of epochs between from and to inclusively ordered by epoch.
+func (c *DbClient) GetEpochPenaltiesInRange(from, to uint64) ([]*EpochPenalty, error) {
+	return c.findEpochPenaltiesInRange(from, to)
+}
+
+func (c *DbClient) findEpochsInRange(from, to uint64) ([]*Epoch, error) {
+	var docs []*Epoch
+	result := c.d.Model(&Epoch{}).
+		Where("id BETWEEN ? AND ?", from, to).
+		Order("id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) findEpochValidatorsInRange(from, to uint64) ([]*EpochValidator, error) {
+	var docs []*EpochValidator
+	result := c.d.Model(&EpochValidator{}).
+		Where("epoch_id BETWEEN ? AND ?", from, to).
+		Order("epoch_id ASC, position ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) findEpochPenaltiesInRange(from, to uint64) ([]*EpochPenalty, error) {
+	var docs []*EpochPenalty
+	result := c.d.Model(&EpochPenalty{}).
+		Where("epoch_id BETWEEN ? AND ?", from, to).
+		Order("epoch_id ASC, id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countEpochsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Epoch{}).
+		Where("checkpoint_block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeEpochs(epochs []*Epoch, validators []*EpochValidator, penalties []*EpochPenalty) error {
+	tx := c.d.Begin()
+	err := replaceEpochs(tx, epochs, validators, penalties)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func replaceEpochs(tx *gorm.DB, epochs []*Epoch, validators []*EpochValidator, penalties []*EpochPenalty) error {
+	epochIDs := make([]uint64, len(epochs))
+	for i, epoch := range epochs {
+		epochIDs[i] = epoch.ID
+	}
+	result := tx.Where("epoch_id IN ?", epochIDs).
+		Delete(&EpochValidator{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("epoch_id IN ?", epochIDs).
+		Delete(&EpochPenalty{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("id IN ?", epochIDs).
+		Delete(&Epoch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Create(epochs)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(validators) > 0 {
+		result = tx.CreateInBatches(validators, 1000)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	if len(penalties) > 0 {
+		result = tx.CreateInBatches(penalties, 1000)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+func deleteEpochsAbove(tx *gorm.DB, number uint64) error {
+	epochIDs := tx.Model(&Epoch{}).
+		Select("id").
+		Where("checkpoint_block_id > ?", number)
+	result := tx.Where("epoch_id IN (?)", epochIDs).
+		Delete(&EpochValidator{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("epoch_id IN (?)", epochIDs).
+		Delete(&EpochPenalty{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("checkpoint_block_id > ?", number).
+		Delete(&Epoch{})
+	return result.Error
+}
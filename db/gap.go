@@ -0,0 +1,130 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// BlockRange is an inclusive range of block numbers.
+type BlockRange struct {
+	From uint64 `gorm:"column:from_id" json:"from"`
+	To   uint64 `gorm:"column:to_id" json:"to"`
+}
+
+func (r *BlockRange) Count() uint64 {
+	return r.To - r.From + 1
+}
+
+func (r *BlockRange) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("%d", r.From)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+// Format ranges as a comma-separated list, e.g. 10-20, 35, 40-41.
+func FormatBlockRanges(ranges []*BlockRange) string {
+	strs := make([]string, len(ranges))
+	for i, r := range ranges {
+		strs[i] = r.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+// Group sorted block numbers into ranges of consecutive numbers.
+func CompactBlockNumbers(numbers []uint64) []*BlockRange {
+	ranges := []*BlockRange{}
+	for _, number := range numbers {
+		if len(ranges) > 0 && ranges[len(ranges)-1].To+1 == number {
+			ranges[len(ranges)-1].To = number
+			continue
+		}
+		ranges = append(ranges, &BlockRange{From: number, To: number})
+	}
+	return ranges
+}
+
+// Sort ranges and merge the overlapping or adjacent ones.
+func MergeBlockRanges(ranges []*BlockRange) []*BlockRange {
+	sorted := make([]*BlockRange, len(ranges))
+	for i, r := range ranges {
+		sorted[i] = &BlockRange{From: r.From, To: r.To}
+	}
+	slices.SortFunc(sorted, func(a, b *BlockRange) int {
+		return cmp.Compare(a.From, b.From)
+	})
+	merged := []*BlockRange{}
+	for _, r := range sorted {
+		if len(merged) > 0 && merged[len(merged)-1].To+1 >= r.From {
+			merged[len(merged)-1].To = max(merged[len(merged)-1].To, r.To)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Block whose number of stored transactions differs from its transaction count.
+type TransactionCountMismatch struct {
+	BlockNumber uint64 `gorm:"column:block_number"`
+	Expected    uint64 `gorm:"column:expected"`
+	Actual      uint64 `gorm:"column:actual"`
+}
+
+// Return ranges of block numbers between from and to inclusively that are missing in blocks table.
+func (c *DbClient) GetBlockGaps(from, to uint64) ([]*BlockRange, error) {
+	return c.findBlockGaps(from, to)
+}
+
+// Return blocks between from and to inclusively whose transactions are not fully stored.
+func (c *DbClient) GetTransactionCountMismatches(from, to uint64) ([]*TransactionCountMismatch, error) {
+	return c.findTransactionCountMismatches(from, to)
+}
+
+func (c *DbClient) findBlockGaps(from, to uint64) ([]*BlockRange, error) {
+	var bounds struct {
+		MinID *uint64 `gorm:"column:min_id"`
+		MaxID *uint64 `gorm:"column:max_id"`
+	}
+	result := c.d.Model(&Block{}).
+		Select("MIN(id) AS min_id, MAX(id) AS max_id").
+		Where("id BETWEEN ? AND ?", from, to).
+		Scan(&bounds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if bounds.MinID == nil {
+		return []*BlockRange{{From: from, To: to}}, nil
+	}
+	gaps := []*BlockRange{}
+	if *bounds.MinID > from {
+		gaps = append(gaps, &BlockRange{From: from, To: *bounds.MinID - 1})
+	}
+	var innerGaps []*BlockRange
+	result = c.d.Raw(`SELECT prev_id + 1 AS from_id, id - 1 AS to_id FROM (
+			SELECT id, LAG(id) OVER (ORDER BY id) AS prev_id FROM blocks WHERE id BETWEEN ? AND ?
+		) t WHERE id > prev_id + 1 ORDER BY id`, from, to).
+		Scan(&innerGaps)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	gaps = append(gaps, innerGaps...)
+	if *bounds.MaxID < to {
+		gaps = append(gaps, &BlockRange{From: *bounds.MaxID + 1, To: to})
+	}
+	return gaps, nil
+}
+
+func (c *DbClient) findTransactionCountMismatches(from, to uint64) ([]*TransactionCountMismatch, error) {
+	var docs []*TransactionCountMismatch
+	result := c.d.Raw(`SELECT b.id AS block_number, b.transaction_count AS expected, COUNT(t.id) AS actual
+		FROM blocks b LEFT JOIN transactions t ON t.block_id = b.id
+		WHERE b.id BETWEEN ? AND ? AND b.transaction_count IS NOT NULL
+		GROUP BY b.id, b.transaction_count
+		HAVING COUNT(t.id) <> b.transaction_count
+		ORDER BY b.id`, from, to).
+		Scan(&docs)
+	return docs, result.Error
+}
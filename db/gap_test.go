@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestCompactBlockNumbers(t *testing.T) {
+	ranges := CompactBlockNumbers([]uint64{10, 11, 12, 20, 30, 31})
+	expected := "10-12, 20, 30-31"
+	if FormatBlockRanges(ranges) != expected {
+		t.Fatalf("Ranges mismatch. Expected '%s' Actual '%s'", expected, FormatBlockRanges(ranges))
+	}
+}
+
+func TestMergeBlockRanges(t *testing.T) {
+	ranges := MergeBlockRanges([]*BlockRange{
+		{From: 30, To: 40},
+		{From: 1, To: 5},
+		{From: 6, To: 8},
+		{From: 35, To: 36},
+		{From: 50, To: 50},
+	})
+	expected := "1-8, 30-40, 50"
+	if FormatBlockRanges(ranges) != expected {
+		t.Fatalf("Ranges mismatch. Expected '%s' Actual '%s'", expected, FormatBlockRanges(ranges))
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"math/big"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// InternalTransaction is a single call frame of a transaction trace. Top-level call has depth 0 and empty trace address.
+type InternalTransaction struct {
+	ID               uint64          `gorm:"column:id;primaryKey;autoIncrement"`
+	BlockID          uint64          `gorm:"column:block_id;index"`
+	TransactionHash  string          `gorm:"column:transaction_hash;length:32;index"`
+	TransactionIndex uint16          `gorm:"column:transaction_index"`
+	TraceAddress     string          `gorm:"column:trace_address"`
+	Depth            uint16          `gorm:"column:depth"`
+	Type             string          `gorm:"column:type"`
+	From             string          `gorm:"column:from;length:20;index"`
+	To               string          `gorm:"column:to;length:20;index"`
+	Value            decimal.Decimal `gorm:"column:value;type:decimal(78,0)"`
+	Gas              uint64          `gorm:"column:gas"`
+	GasUsed          uint64          `gorm:"column:gas_used"`
+	Error            string          `gorm:"column:error"`
+}
+
+func NewInternalTransaction(blockNumber uint64, transactionHash string, transactionIndex uint16, traceAddress string, depth uint16,
+	callType, from, to string, value *big.Int, gas, gasUsed uint64, callError string) *InternalTransaction {
+	return &InternalTransaction{
+		BlockID:          blockNumber,
+		TransactionHash:  transactionHash,
+		TransactionIndex: transactionIndex,
+		TraceAddress:     traceAddress,
+		Depth:            depth,
+		Type:             callType,
+		From:             from,
+		To:               to,
+		Value:            decimal.NewFromBigInt(value, 0),
+		Gas:              gas,
+		GasUsed:          gasUsed,
+		Error:            callError,
+	}
+}
+
+// Replace internal transactions of blocks in `txCounts` and set their debug transaction count.
+// `txCounts` is number of traced transactions keyed by block number.
+func (c *DbClient) SaveInternalTransactions(txCounts map[uint64]uint16, internalTxs []*InternalTransaction) error {
+	return c.writeInternalTransactions(txCounts, internalTxs)
+}
+
+// Return internal transactions of blocks between from and to inclusively ordered by block number and id.
+func (c *DbClient) GetInternalTransactionsInRange(from, to uint64) ([]*InternalTransaction, error) {
+	return c.findInternalTransactionsInRange(from, to)
+}
+
+func (c *DbClient) findInternalTransactionsInRange(from, to uint64) ([]*InternalTransaction, error) {
+	var docs []*InternalTransaction
+	result := c.d.Model(&InternalTransaction{}).
+		Where("block_id BETWEEN ? AND ?", from, to).
+		Order("block_id ASC, id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countInternalTransactionsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&InternalTransaction{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeInternalTransactions(txCounts map[uint64]uint16, internalTxs []*InternalTransaction) error {
+	blockIDs := make([]uint64, 0, len(txCounts))
+	for blockID := range txCounts {
+		blockIDs = append(blockIDs, blockID)
+	}
+	tx := c.d.Begin()
+	result := tx.Where("block_id IN ?", blockIDs).
+		Delete(&InternalTransaction{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if len(internalTxs) > 0 {
+		result = tx.CreateInBatches(internalTxs, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	for blockID, txCount := range txCounts {
+		result = tx.Model(&Block{}).
+			Where("id = ?", blockID).
+			Updates(map[string]interface{}{
+				"transaction_count_debug": txCount,
+			})
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+func deleteInternalTransactionsAbove(tx *gorm.DB, number uint64) error {
+	result := tx.Where("block_id > ?", number).
+		Delete(&InternalTransaction{})
+	return result.Error
+}
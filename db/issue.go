@@ -5,16 +5,64 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"time"
 	"viction-rpc-crawler-go/ethutil"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
 	ERROR_ISSUE uint16 = iota
 	REORG_BLOCK_ISSUE
 	DUPLICATED_TX_HASH_ISSUE
+	BROKEN_PARENT_LINK_ISSUE
+	INVALID_BLOCK_HASH_ISSUE
+	INVALID_CREATOR_ISSUE
+	TX_COUNT_MISMATCH_ISSUE
 )
 
+var issueTypeNames = map[uint16]string{
+	ERROR_ISSUE:              "error",
+	REORG_BLOCK_ISSUE:        "reorg_block",
+	DUPLICATED_TX_HASH_ISSUE: "duplicated_tx_hash",
+	BROKEN_PARENT_LINK_ISSUE: "broken_parent_link",
+	INVALID_BLOCK_HASH_ISSUE: "invalid_block_hash",
+	INVALID_CREATOR_ISSUE:    "invalid_creator",
+	TX_COUNT_MISMATCH_ISSUE:  "tx_count_mismatch",
+}
+
+// Return readable name of an issue type.
+func IssueTypeName(issueType uint16) string {
+	if name, ok := issueTypeNames[issueType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown_%d", issueType)
+}
+
+// Return issue type of a readable name.
+func ParseIssueType(name string) (uint16, bool) {
+	for issueType, typeName := range issueTypeNames {
+		if typeName == name {
+			return issueType, true
+		}
+	}
+	return 0, false
+}
+
+// IssueFilter selects issues. Zero values match all issues.
+type IssueFilter struct {
+	Types     []uint16
+	FromBlock uint64
+	// Highest block number. Use 0 for no upper bound.
+	ToBlock uint64
+	// Nil matches both open and resolved issues.
+	Status *bool
+	// Maximum number of issues returned. Use 0 for no limit.
+	Limit int
+}
+
 type Issue struct {
 	ID          uint64 `gorm:"column:id;primaryKey;autoIncrement"`
 	Type        uint16 `gorm:"column:type"`
@@ -28,14 +76,28 @@ type Issue struct {
 	Extras map[string]interface{} `gorm:"column:extras;serializer:json"`
 }
 
+// Compute hash identifying the issue from its type, block hash, transaction hash and extras.
+// Error issues are identified by block number and category instead of extras, so the same block failing again
+// with another error message maps to the same issue.
 func (i *Issue) Checksum() {
 	typeBytes := make([]byte, 4)
 	binary.BigEndian.PutUint16(typeBytes, i.Type)
-	extraBytes, _ := json.Marshal(i.Extras)
 	issueBytes := typeBytes
+	if i.Type == ERROR_ISSUE {
+		numberBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(numberBytes, i.BlockNumber)
+		issueBytes = append(issueBytes, numberBytes...)
+	}
 	issueBytes = append(issueBytes, ethutil.HexToBytes(i.BlockHash)...)
 	issueBytes = append(issueBytes, ethutil.HexToBytes(i.TxHash)...)
-	issueBytes = append(issueBytes, extraBytes...)
+	if i.Type == ERROR_ISSUE {
+		if category, ok := i.Extras["category"].(string); ok {
+			issueBytes = append(issueBytes, []byte(category)...)
+		}
+	} else {
+		extraBytes, _ := json.Marshal(i.Extras)
+		issueBytes = append(issueBytes, extraBytes...)
+	}
 	hashBytes := sha256.Sum256(issueBytes)
 	i.Hash = hex.EncodeToString(hashBytes[:])
 }
@@ -54,6 +116,16 @@ func (c *DbClient) NewErrorIssue(txHash string, blockNumber uint64, blockHash st
 	return issue
 }
 
+// Create an error issue for a block that could not be fetched. Category is the RPC method the block
+// is stored under, e.g. getBlockByNumber or traceBlockByNumber.
+func (c *DbClient) NewFailedBlockIssue(category string, blockNumber uint64, errorClass string, err error) *Issue {
+	issue := c.NewErrorIssue("", blockNumber, "", err)
+	issue.Extras["category"] = category
+	issue.Extras["block_number"] = blockNumber
+	issue.Extras["error_class"] = errorClass
+	return issue
+}
+
 func NewReorgBlockIssue(blockNumber uint64, blockHash, prevBlockHash string) *Issue {
 	extras := map[string]interface{}{
 		"prev_block_hash": prevBlockHash,
@@ -68,6 +140,21 @@ func NewReorgBlockIssue(blockNumber uint64, blockHash, prevBlockHash string) *Is
 	return issue
 }
 
+func NewChainReorgIssue(blockNumber uint64, blockHash, prevBlockHash string, depth uint64) *Issue {
+	extras := map[string]interface{}{
+		"prev_block_hash": prevBlockHash,
+		"depth":           depth,
+	}
+	issue := &Issue{
+		Type:        REORG_BLOCK_ISSUE,
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		TxHash:      "",
+		Extras:      extras,
+	}
+	return issue
+}
+
 func NewDuplicatedTxHashIssue(txHash string, blockNumber uint64, blockHash string, prevBlockNumber uint64, prevBlockHash string) *Issue {
 	extras := map[string]interface{}{
 		"prev_block_number": prevBlockNumber,
@@ -83,39 +170,241 @@ func NewDuplicatedTxHashIssue(txHash string, blockNumber uint64, blockHash strin
 	return issue
 }
 
+// Create an issue for a block whose parent hash does not match hash of the previous block.
+func NewBrokenParentLinkIssue(blockNumber uint64, blockHash, parentHash, prevBlockHash string) *Issue {
+	extras := map[string]interface{}{
+		"parent_hash":     parentHash,
+		"prev_block_hash": prevBlockHash,
+	}
+	issue := &Issue{
+		Type:        BROKEN_PARENT_LINK_ISSUE,
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		TxHash:      "",
+		Extras:      extras,
+	}
+	return issue
+}
+
+// Create an issue for a block whose stored hash differs from the hash computed from its header fields.
+func NewInvalidBlockHashIssue(blockNumber uint64, blockHash, computedHash string) *Issue {
+	extras := map[string]interface{}{
+		"computed_hash": computedHash,
+	}
+	issue := &Issue{
+		Type:        INVALID_BLOCK_HASH_ISSUE,
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		TxHash:      "",
+		Extras:      extras,
+	}
+	return issue
+}
+
+// Create an issue for a block whose creator cannot be recovered, differs from the stored one
+// or is not in the signer list of the epoch.
+func NewInvalidCreatorIssue(blockNumber uint64, blockHash, creator, recoveredCreator, reason string) *Issue {
+	extras := map[string]interface{}{
+		"creator":           creator,
+		"recovered_creator": recoveredCreator,
+		"reason":            reason,
+	}
+	issue := &Issue{
+		Type:        INVALID_CREATOR_ISSUE,
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		TxHash:      "",
+		Extras:      extras,
+	}
+	return issue
+}
+
+// Create an issue for a block whose number of stored transactions differs from its transaction count.
+func NewTxCountMismatchIssue(blockNumber uint64, blockHash string, expected, actual uint64) *Issue {
+	extras := map[string]interface{}{
+		"expected": expected,
+		"actual":   actual,
+	}
+	issue := &Issue{
+		Type:        TX_COUNT_MISMATCH_ISSUE,
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		TxHash:      "",
+		Extras:      extras,
+	}
+	return issue
+}
+
 func (c *DbClient) SaveErrorIssue(txHash string, blockNumber uint64, blockHash string, err error) error {
 	issue := c.NewErrorIssue(txHash, blockNumber, blockHash, err)
-	return c.insertIssue(issue)
+	return c.upsertIssues([]*Issue{issue})
 }
 
 func (c *DbClient) SaveReorgBlockIssue(blockNumber uint64, blockHash, prevBlockHash string) error {
 	issue := NewReorgBlockIssue(blockNumber, blockHash, prevBlockHash)
-	return c.insertIssue(issue)
+	return c.upsertIssues([]*Issue{issue})
 }
 
 func (c *DbClient) SaveDuplicatedTxHashIssue(txHash string, blockNumber uint64, blockHash string, prevBlockNumber uint64, prevBlockHash string) error {
 	issue := NewDuplicatedTxHashIssue(txHash, blockNumber, blockHash, prevBlockNumber, prevBlockHash)
-	return c.insertIssue(issue)
+	return c.upsertIssues([]*Issue{issue})
 }
 
+// Save issues. An issue found again is reopened instead of duplicated.
 func (c *DbClient) SaveIssues(issues []*Issue) error {
-	return c.writeIssues(issues)
+	if len(issues) == 0 {
+		return nil
+	}
+	return c.upsertIssues(issues)
 }
 
-func (c *DbClient) insertIssue(issue *Issue) error {
-	now := time.Now().UnixMicro()
-	issue.Checksum()
-	issue.Timestamp = now
-	result := c.d.Create(issue)
+// Save issues of failed blocks. An issue of the same block and category is reopened with the latest error.
+func (c *DbClient) SaveFailedBlockIssues(issues []*Issue) error {
+	return c.upsertIssues(issues)
+}
+
+// Save issues found by chain verification. An issue found again is reopened instead of duplicated.
+func (c *DbClient) SaveVerificationIssues(issues []*Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return c.upsertIssues(issues)
+}
+
+// Return unresolved issues of failed blocks, optionally filtered by category.
+func (c *DbClient) GetFailedBlockIssues(category string) ([]*Issue, error) {
+	issues, err := c.findIssuesByStatus(ERROR_ISSUE, false)
+	if err != nil {
+		return nil, err
+	}
+	failedIssues := []*Issue{}
+	for _, issue := range issues {
+		issueCategory, ok := issue.Extras["category"].(string)
+		if !ok || (category != "" && issueCategory != category) {
+			continue
+		}
+		failedIssues = append(failedIssues, issue)
+	}
+	return failedIssues, nil
+}
+
+// Mark issues as resolved by setting their status.
+func (c *DbClient) ResolveIssues(ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return c.updateIssuesStatus(ids, true)
+}
+
+// Return issues matching filter ordered by block number.
+func (c *DbClient) GetIssues(filter *IssueFilter) ([]*Issue, error) {
+	return c.findIssues(filter)
+}
+
+// Return issue of the hash. Return nil if not found.
+func (c *DbClient) GetIssueByHash(hash string) (*Issue, error) {
+	return c.findIssueByHash(hash)
+}
+
+// Return issues of blocks between from and to inclusively ordered by block number.
+func (c *DbClient) GetIssuesInRange(from, to uint64) ([]*Issue, error) {
+	return c.findIssuesInRange(from, to)
+}
+
+func (c *DbClient) findIssues(filter *IssueFilter) ([]*Issue, error) {
+	var docs []*Issue
+	query := c.d.Model(&Issue{}).
+		Where("block_number >= ?", filter.FromBlock)
+	if filter.ToBlock > 0 {
+		query = query.Where("block_number <= ?", filter.ToBlock)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	result := query.Order("block_number ASC, id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) findIssueByHash(hash string) (*Issue, error) {
+	var doc *Issue
+	result := c.d.Model(&Issue{}).
+		Where("hash = ?", hash).
+		First(&doc)
+	if c.isEmptyResultError(result.Error) {
+		return nil, nil
+	}
+	return doc, result.Error
+}
+
+func (c *DbClient) findIssuesInRange(from, to uint64) ([]*Issue, error) {
+	var docs []*Issue
+	result := c.d.Model(&Issue{}).
+		Where("block_number BETWEEN ? AND ?", from, to).
+		Order("block_number ASC, id ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countIssuesAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Issue{}).
+		Where("block_number > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) findIssuesByStatus(issueType uint16, status bool) ([]*Issue, error) {
+	var docs []*Issue
+	result := c.d.Model(&Issue{}).
+		Where("type = ? AND status = ?", issueType, status).
+		Order("block_number ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) updateIssuesStatus(ids []uint64, status bool) error {
+	result := c.d.Model(&Issue{}).
+		Where("id IN ?", ids).
+		Update("status", status)
 	return result.Error
 }
 
-func (c *DbClient) writeIssues(newIssues []*Issue) error {
-	now := time.Now().UnixMicro()
+// Issues with the same hash in one batch are saved once, keeping the last one.
+func (c *DbClient) upsertIssues(newIssues []*Issue) error {
+	return upsertIssues(c.d, newIssues)
+}
+
+func upsertIssues(tx *gorm.DB, newIssues []*Issue) error {
+	stampIssues(newIssues)
+	positions := map[string]int{}
+	uniqueIssues := []*Issue{}
 	for _, issue := range newIssues {
+		if i, ok := positions[issue.Hash]; ok {
+			uniqueIssues[i] = issue
+			continue
+		}
+		positions[issue.Hash] = len(uniqueIssues)
+		uniqueIssues = append(uniqueIssues, issue)
+	}
+	newIssues = uniqueIssues
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "timestamp", "extras"}),
+	}).CreateInBatches(newIssues, len(newIssues))
+	return result.Error
+}
+
+func stampIssues(issues []*Issue) {
+	now := time.Now().UnixMicro()
+	for _, issue := range issues {
 		issue.Checksum()
 		issue.Timestamp = now
 	}
-	result := c.d.CreateInBatches(newIssues, len(newIssues))
-	return result.Error
 }
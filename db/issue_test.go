@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/tforce-io/tf-golib/random/pseudorng"
@@ -43,6 +44,33 @@ func TestInsertIssue(t *testing.T) {
 	})
 }
 
+func TestIssueChecksum(t *testing.T) {
+	var c *DbClient
+	issue := c.NewFailedBlockIssue("getBlockByNumber", 100, "timeout", errors.New("context deadline exceeded"))
+	issue.Checksum()
+	sameIssue := c.NewFailedBlockIssue("getBlockByNumber", 100, "rate_limited", errors.New("429 Too Many Requests"))
+	sameIssue.Checksum()
+	if issue.Hash != sameIssue.Hash {
+		t.Fatalf("Error message must not change checksum.")
+	}
+	otherCategory := c.NewFailedBlockIssue("traceBlockByNumber", 100, "timeout", errors.New("context deadline exceeded"))
+	otherCategory.Checksum()
+	otherBlock := c.NewFailedBlockIssue("getBlockByNumber", 101, "timeout", errors.New("context deadline exceeded"))
+	otherBlock.Checksum()
+	if issue.Hash == otherCategory.Hash || issue.Hash == otherBlock.Hash {
+		t.Fatalf("Checksum must differ by category and block number.")
+	}
+	reorg := NewChainReorgIssue(100, "aa", "bb", 1)
+	reorg.Checksum()
+	otherFork := NewChainReorgIssue(100, "aa", "cc", 1)
+	otherFork.Checksum()
+	otherDepth := NewChainReorgIssue(100, "aa", "bb", 2)
+	otherDepth.Checksum()
+	if reorg.Hash == otherFork.Hash || reorg.Hash == otherDepth.Hash {
+		t.Fatalf("Checksum of reorg issues must differ by previous block hash and depth.")
+	}
+}
+
 func prepareDatabaseForIssues() *DbClient {
 	db, err := Connect(TEST_CONNECTION, "")
 	if err != nil {
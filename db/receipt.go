@@ -0,0 +1,143 @@
+package db
+
+import (
+	"github.com/gurukami/typ"
+	"gorm.io/gorm"
+)
+
+// Receipt is keyed by block and transaction index. Transaction hash is not unique as Viction
+// has transactions included in more than one block.
+type Receipt struct {
+	ID                uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	TransactionHash   string `gorm:"column:transaction_hash;length:32;index:idx_receipt_transaction_hash"`
+	BlockID           uint64 `gorm:"column:block_id;uniqueIndex:idx_receipt_block_transaction"`
+	BlockHash         string `gorm:"column:block_hash;length:32"`
+	TransactionIndex  uint16 `gorm:"column:transaction_index;uniqueIndex:idx_receipt_block_transaction"`
+	CumulativeGasUsed uint64 `gorm:"column:cumulative_gas_used"`
+	GasUsed           uint64 `gorm:"column:gas_used"`
+	// 1 for success, 0 for failure. Null for receipts created before Byzantium.
+	Status          typ.NullUint16 `gorm:"column:status"`
+	ContractAddress string         `gorm:"column:contract_address;length:20"`
+	LogCount        uint16         `gorm:"column:log_count"`
+}
+
+// Log is an event emitted by a transaction. Unused topics are empty.
+type Log struct {
+	ID               uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	BlockID          uint64 `gorm:"column:block_id;index"`
+	BlockHash        string `gorm:"column:block_hash;length:32"`
+	TransactionHash  string `gorm:"column:transaction_hash;length:32;index"`
+	TransactionIndex uint16 `gorm:"column:transaction_index"`
+	LogIndex         uint16 `gorm:"column:log_index"`
+	Address          string `gorm:"column:address;length:20;index"`
+	Topic0           string `gorm:"column:topic0;length:32;index"`
+	Topic1           string `gorm:"column:topic1;length:32"`
+	Topic2           string `gorm:"column:topic2;length:32"`
+	Topic3           string `gorm:"column:topic3;length:32"`
+	Data             []byte `gorm:"column:data"`
+}
+
+// Replace receipts and logs of blocks in `blockIDs` and save issues found while fetching them.
+// Status of block signatures included in the blocks is updated from their receipts.
+// An issue found again is reopened instead of duplicated.
+func (c *DbClient) SaveReceipts(blockIDs []uint64, receipts []*Receipt, logs []*Log, issues []*Issue) error {
+	return c.writeReceipts(blockIDs, receipts, logs, issues)
+}
+
+// Return receipts of blocks between from and to inclusively ordered by block number and index.
+func (c *DbClient) GetReceiptsInRange(from, to uint64) ([]*Receipt, error) {
+	return c.findReceiptsInRange(from, to)
+}
+
+// Return logs of blocks between from and to inclusively ordered by block number and index.
+func (c *DbClient) GetLogsInRange(from, to uint64) ([]*Log, error) {
+	return c.findLogsInRange(from, to)
+}
+
+func (c *DbClient) findReceiptsInRange(from, to uint64) ([]*Receipt, error) {
+	var docs []*Receipt
+	result := c.d.Model(&Receipt{}).
+		Where("block_id BETWEEN ? AND ?", from, to).
+		Order("block_id ASC, transaction_index ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) findLogsInRange(from, to uint64) ([]*Log, error) {
+	var docs []*Log
+	result := c.d.Model(&Log{}).
+		Where("block_id BETWEEN ? AND ?", from, to).
+		Order("block_id ASC, log_index ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countReceiptsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Receipt{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) countLogsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Log{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeReceipts(blockIDs []uint64, receipts []*Receipt, logs []*Log, issues []*Issue) error {
+	tx := c.d.Begin()
+	result := tx.Where("block_id IN ?", blockIDs).
+		Delete(&Log{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	result = tx.Where("block_id IN ?", blockIDs).
+		Delete(&Receipt{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if len(receipts) > 0 {
+		result = tx.CreateInBatches(receipts, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	if len(logs) > 0 {
+		result = tx.CreateInBatches(logs, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	if len(issues) > 0 {
+		err := upsertIssues(tx, issues)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err := updateBlockSignatureStatus(tx, blockIDs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func deleteReceiptsAbove(tx *gorm.DB, number uint64) error {
+	result := tx.Where("block_id > ?", number).
+		Delete(&Log{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("block_id > ?", number).
+		Delete(&Receipt{})
+	return result.Error
+}
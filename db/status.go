@@ -0,0 +1,116 @@
+package db
+
+// Tables reported by database status, in report order.
+var statusTables = []struct {
+	Name  string
+	Model interface{}
+}{
+	{"blocks", &Block{}},
+	{"transactions", &Transaction{}},
+	{"internal_transactions", &InternalTransaction{}},
+	{"receipts", &Receipt{}},
+	{"logs", &Log{}},
+	{"token_transfers", &TokenTransfer{}},
+	{"tokens", &Token{}},
+	{"block_signatures", &BlockSignature{}},
+	{"epochs", &Epoch{}},
+	{"epoch_validators", &EpochValidator{}},
+	{"epoch_penalties", &EpochPenalty{}},
+	{"validator_stats", &ValidatorStat{}},
+	{"missed_slots", &MissedSlot{}},
+	{"issues", &Issue{}},
+	{"checkpoints", &Checkpoint{}},
+}
+
+// Return lowest and highest block numbers stored in blocks table. Return nil if the table is empty.
+func (c *DbClient) GetBlockBounds() (*BlockRange, error) {
+	var bounds struct {
+		MinID *uint64 `gorm:"column:min_id"`
+		MaxID *uint64 `gorm:"column:max_id"`
+	}
+	result := c.d.Model(&Block{}).
+		Select("MIN(id) AS min_id, MAX(id) AS max_id").
+		Scan(&bounds)
+	if result.Error != nil || bounds.MinID == nil {
+		return nil, result.Error
+	}
+	return &BlockRange{From: *bounds.MinID, To: *bounds.MaxID}, nil
+}
+
+// Return number of rows of every table managed by this tool, keyed by table name.
+// Unless `exact` is set, counts are planner estimates from pg_class which avoid scanning large tables.
+// Tables which have never been analyzed have no estimate and are counted exactly.
+func (c *DbClient) GetRowCounts(exact bool) (map[string]int64, error) {
+	counts := map[string]int64{}
+	if !exact {
+		estimates, err := c.getRowEstimates()
+		if err != nil {
+			return nil, err
+		}
+		counts = estimates
+	}
+	for _, table := range statusTables {
+		if _, ok := counts[table.Name]; ok {
+			continue
+		}
+		var count int64
+		result := c.d.Model(table.Model).Count(&count)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		counts[table.Name] = count
+	}
+	return counts, nil
+}
+
+// Return estimated number of rows of analyzed tables in current schema, keyed by table name.
+func (c *DbClient) getRowEstimates() (map[string]int64, error) {
+	var rows []struct {
+		Name     string  `gorm:"column:name"`
+		Estimate float64 `gorm:"column:estimate"`
+	}
+	result := c.d.Raw(`SELECT relname AS name, reltuples AS estimate FROM pg_class
+		WHERE relkind = 'r' AND relnamespace = current_schema()::regnamespace AND relname IN ?`, StatusTableNames()).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	estimates := map[string]int64{}
+	for _, row := range rows {
+		if row.Estimate < 0 {
+			continue
+		}
+		estimates[row.Name] = int64(row.Estimate)
+	}
+	return estimates, nil
+}
+
+// Return names of tables reported by GetRowCounts in report order.
+func StatusTableNames() []string {
+	names := make([]string, len(statusTables))
+	for i, table := range statusTables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// Return number of unresolved issues keyed by issue type.
+func (c *DbClient) GetOpenIssueCounts() (map[uint16]int64, error) {
+	var rows []struct {
+		Type  uint16 `gorm:"column:type"`
+		Count int64  `gorm:"column:count"`
+	}
+	result := c.d.Model(&Issue{}).
+		Select("type, COUNT(*) AS count").
+		Where("status = ?", false).
+		Group("type").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := map[uint16]int64{}
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"github.com/gurukami/typ"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TOKEN_STANDARD_ERC20   = "ERC20"
+	TOKEN_STANDARD_ERC721  = "ERC721"
+	TOKEN_STANDARD_ERC1155 = "ERC1155"
+)
+
+// Token is a contract emitting transfer events. Metadata is empty if the contract does not implement it.
+type Token struct {
+	Address      string         `gorm:"column:address;length:20;primaryKey"`
+	Standard     string         `gorm:"column:standard"`
+	Name         string         `gorm:"column:name"`
+	Symbol       string         `gorm:"column:symbol"`
+	Decimals     typ.NullUint16 `gorm:"column:decimals"`
+	FirstBlockID uint64         `gorm:"column:first_block_id"`
+}
+
+// TokenTransfer is a single token movement decoded from a log. Items of ERC1155 batch transfers share the log
+// and are distinguished by BatchIndex. TokenID is zero for ERC20 transfers.
+type TokenTransfer struct {
+	ID              uint64          `gorm:"column:id;primaryKey;autoIncrement"`
+	BlockID         uint64          `gorm:"column:block_id;index"`
+	TransactionHash string          `gorm:"column:transaction_hash;length:32;index"`
+	LogIndex        uint16          `gorm:"column:log_index"`
+	BatchIndex      uint16          `gorm:"column:batch_index"`
+	TokenAddress    string          `gorm:"column:token_address;length:20;index"`
+	Standard        string          `gorm:"column:standard"`
+	From            string          `gorm:"column:from;length:20;index"`
+	To              string          `gorm:"column:to;length:20;index"`
+	TokenID         decimal.Decimal `gorm:"column:token_id;type:decimal(78,0)"`
+	Value           decimal.Decimal `gorm:"column:value;type:decimal(78,0)"`
+}
+
+// Return logs of blocks between from and to inclusively whose first topic is one of `topic0s`,
+// ordered by block number and index.
+func (c *DbClient) GetLogsByTopicsInRange(from, to uint64, topic0s []string) ([]*Log, error) {
+	return c.findLogsByTopicsInRange(from, to, topic0s)
+}
+
+// Return stored tokens among `addresses`.
+func (c *DbClient) GetTokens(addresses []string) ([]*Token, error) {
+	return c.findTokensByAddresses(addresses)
+}
+
+// Replace token transfers of blocks between from and to inclusively and insert new tokens.
+// Tokens already stored are left unchanged.
+func (c *DbClient) SaveTokenTransfers(from, to uint64, transfers []*TokenTransfer, tokens []*Token) error {
+	return c.writeTokenTransfers(from, to, transfers, tokens)
+}
+
+func (c *DbClient) findLogsByTopicsInRange(from, to uint64, topic0s []string) ([]*Log, error) {
+	var docs []*Log
+	result := c.d.Model(&Log{}).
+		Where("block_id BETWEEN ? AND ?", from, to).
+		Where("topic0 IN ?", topic0s).
+		Order("block_id ASC, log_index ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) findTokensByAddresses(addresses []string) ([]*Token, error) {
+	var docs []*Token
+	result := c.d.Model(&Token{}).
+		Where("address IN ?", addresses).
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countTokenTransfersAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&TokenTransfer{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeTokenTransfers(from, to uint64, transfers []*TokenTransfer, tokens []*Token) error {
+	tx := c.d.Begin()
+	result := tx.Where("block_id BETWEEN ? AND ?", from, to).
+		Delete(&TokenTransfer{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if len(transfers) > 0 {
+		result = tx.CreateInBatches(transfers, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	if len(tokens) > 0 {
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(tokens, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+func deleteTokenTransfersAbove(tx *gorm.DB, number uint64) error {
+	result := tx.Where("block_id > ?", number).
+		Delete(&TokenTransfer{})
+	return result.Error
+}
@@ -1,11 +1,42 @@
 package db
 
 import (
+	"fmt"
 	"math/big"
 
+	"github.com/gurukami/typ"
 	"github.com/shopspring/decimal"
 )
 
+// Categories of transactions by the system contract they call.
+const (
+	USER_TX uint16 = iota
+	SIGN_TX
+	RANDOMIZE_TX
+	TOMOX_TX
+	TOMOX_TRADING_STATE_TX
+	LENDING_TX
+	FINAL_LENDING_TX
+)
+
+var txCategoryNames = map[uint16]string{
+	USER_TX:                "user",
+	SIGN_TX:                "sign",
+	RANDOMIZE_TX:           "randomize",
+	TOMOX_TX:               "tomox",
+	TOMOX_TRADING_STATE_TX: "tomox_trading_state",
+	LENDING_TX:             "lending",
+	FINAL_LENDING_TX:       "final_lending",
+}
+
+// Return readable name of a transaction category.
+func TransactionCategoryName(category uint16) string {
+	if name, ok := txCategoryNames[category]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown_%d", category)
+}
+
 type Transaction struct {
 	ID               uint64          `gorm:"column:id;primaryKey;autoIncrement"`
 	Hash             string          `gorm:"column:hash;length:32;uniqueIndex"`
@@ -18,6 +49,7 @@ type Transaction struct {
 	Nonce            uint64          `gorm:"column:nonce"`
 	Gas              uint64          `gorm:"column:gas"`
 	GasPrice         decimal.Decimal `gorm:"column:gas_price;type:decimal(78,0)"`
+	Category         typ.NullUint16  `gorm:"column:category;index"`
 }
 
 func NewTransaction(hash string, blockNumber *big.Int, blockHash string, transactionIndex uint16, from, to string, value *big.Int, nonce uint64, gas uint64, gasPrice *big.Int) *Transaction {
@@ -43,6 +75,11 @@ func (c *DbClient) GetTransactions(hashes []string) ([]*Transaction, error) {
 	return c.findTransactonsByHashes(hashes)
 }
 
+// Return transactions of blocks between from and to inclusively ordered by block number and index.
+func (c *DbClient) GetTransactionsInRange(from, to uint64) ([]*Transaction, error) {
+	return c.findTransactionsInRange(from, to)
+}
+
 func (c *DbClient) SaveTransaction(hash string, newTransaction *Transaction) error {
 	transaction, err := c.GetTransaction(hash)
 	if err != nil {
@@ -65,6 +102,23 @@ func (c *DbClient) SaveTransactions(newTransactions []*Transaction, changedTrans
 	return c.writeTransactions(newTransactions, changedTransactions)
 }
 
+func (c *DbClient) findTransactionsInRange(from, to uint64) ([]*Transaction, error) {
+	var docs []*Transaction
+	result := c.d.Model(&Transaction{}).
+		Where("block_id BETWEEN ? AND ?", from, to).
+		Order("block_id ASC, transaction_index ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countTransactionsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&Transaction{}).
+		Where("block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
 func (c *DbClient) findTransactonByHash(hash string) (*Transaction, error) {
 	var doc *Transaction
 	result := c.d.Model(&Transaction{}).
@@ -102,6 +156,7 @@ func (c *DbClient) updateTransactionByHash(hash string, transaction *Transaction
 			"nonce":             transaction.Nonce,
 			"gas":               transaction.Gas,
 			"gas_price":         transaction.GasPrice,
+			"category":          transaction.Category,
 		})
 	return result.Error
 }
@@ -128,6 +183,7 @@ func (c *DbClient) writeTransactions(newTransactions []*Transaction, changedTran
 				"nonce":             transaction.Nonce,
 				"gas":               transaction.Gas,
 				"gas_price":         transaction.GasPrice,
+				"category":          transaction.Category,
 			})
 		if result.Error != nil {
 			tx.Rollback()
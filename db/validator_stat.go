@@ -0,0 +1,110 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// ValidatorStat is block production of a masternode in an epoch. Turns follow the round-robin order
+// of masternodes listed in the checkpoint block opening the epoch. Masternodes creating or attesting blocks
+// without being listed are kept with Listed unset and no expected turns.
+// ToBlockID is the last block of the epoch covered by the statistics.
+type ValidatorStat struct {
+	ID             uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	EpochID        uint64 `gorm:"column:epoch_id;uniqueIndex:idx_validator_stat_epoch_address"`
+	Address        string `gorm:"column:address;length:20;uniqueIndex:idx_validator_stat_epoch_address;index"`
+	Listed         bool   `gorm:"column:listed"`
+	BlocksCreated  uint32 `gorm:"column:blocks_created"`
+	BlocksAttested uint32 `gorm:"column:blocks_attested"`
+	ExpectedTurns  uint32 `gorm:"column:expected_turns"`
+	MissedSlots    uint32 `gorm:"column:missed_slots"`
+	ToBlockID      uint64 `gorm:"column:to_block_id;index"`
+}
+
+// MissedSlot is a turn skipped by a masternode before BlockID was created by a later masternode in the rotation.
+// Delay is number of seconds BlockID was created later than the block period.
+type MissedSlot struct {
+	ID      uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	BlockID uint64 `gorm:"column:block_id;index"`
+	EpochID uint64 `gorm:"column:epoch_id;index"`
+	Address string `gorm:"column:address;length:20;index"`
+	Delay   int64  `gorm:"column:delay"`
+}
+
+// Replace validator statistics and missed slots of epochs.
+func (c *DbClient) SaveValidatorStats(epochIDs []uint64, stats []*ValidatorStat, missedSlots []*MissedSlot) error {
+	return c.writeValidatorStats(epochIDs, stats, missedSlots)
+}
+
+// Return validator statistics of epochs between from and to inclusively ordered by epoch and address.
+func (c *DbClient) GetValidatorStatsInRange(from, to uint64) ([]*ValidatorStat, error) {
+	return c.findValidatorStatsInRange(from, to)
+}
+
+func (c *DbClient) findValidatorStatsInRange(from, to uint64) ([]*ValidatorStat, error) {
+	var docs []*ValidatorStat
+	result := c.d.Model(&ValidatorStat{}).
+		Where("epoch_id BETWEEN ? AND ?", from, to).
+		Order("epoch_id ASC, address ASC").
+		Find(&docs)
+	return docs, result.Error
+}
+
+func (c *DbClient) countValidatorStatsAbove(number uint64) (int64, error) {
+	var count int64
+	result := c.d.Model(&ValidatorStat{}).
+		Where("to_block_id > ?", number).
+		Count(&count)
+	return count, result.Error
+}
+
+func (c *DbClient) writeValidatorStats(epochIDs []uint64, stats []*ValidatorStat, missedSlots []*MissedSlot) error {
+	tx := c.d.Begin()
+	result := tx.Where("epoch_id IN ?", epochIDs).
+		Delete(&ValidatorStat{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	result = tx.Where("epoch_id IN ?", epochIDs).
+		Delete(&MissedSlot{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if len(stats) > 0 {
+		result = tx.CreateInBatches(stats, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	if len(missedSlots) > 0 {
+		result = tx.CreateInBatches(missedSlots, 1000)
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+// Statistics of an epoch partially above number are deleted as a whole, they are rebuilt
+// from the first block of the epoch when validators are indexed again.
+func deleteValidatorStatsAbove(tx *gorm.DB, number uint64) error {
+	epochIDs := tx.Model(&ValidatorStat{}).
+		Distinct("epoch_id").
+		Where("to_block_id > ?", number)
+	result := tx.Where("epoch_id IN (?)", epochIDs).
+		Delete(&MissedSlot{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("block_id > ?", number).
+		Delete(&MissedSlot{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Where("to_block_id > ?", number).
+		Delete(&ValidatorStat{})
+	return result.Error
+}
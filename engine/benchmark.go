@@ -1,16 +1,29 @@
 package engine
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/filesystem"
 	"viction-rpc-crawler-go/rpc"
 	"viction-rpc-crawler-go/svc"
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/tforce-io/tf-golib/multiplex"
+	"github.com/tforce-io/tf-golib/opx"
 )
 
+// Maximum number of mismatched blocks printed to stdout. All mismatches are saved in report file.
+const benchmarkMismatchPrintLimit = 20
+
 type BenchmarkModule struct {
 	config *config.RootConfig
 	logger zerolog.Logger
@@ -23,35 +36,81 @@ func NewBenchmarkModule(c *Controller, cmdName string) *BenchmarkModule {
 	}
 }
 
-func (m *BenchmarkModule) GetBlocks(from, to *big.Int, batchSize int) error {
+func (m *BenchmarkModule) GetBlocks(from, to *big.Int, batchSize int, reportPath string) error {
 	m.logger.Info().Msg("Start eth_getBlockByNumber benchmark.")
-	rpcClient, err := rpc.Connect(m.config.Blockchain.RpcUrl)
-	if err != nil {
-		return err
-	}
-	c := svc.NewController(m.config, nil, rpcClient, config.NewZerologLogger(m.logger))
-	go c.DispatchOnce("GetBlocks", "get_blocks_range", multiplex.ExecParams{
-		"from_block_number": from,
-		"to_block_number":   to,
-		"batch_size":        batchSize,
+	return m.benchmark("eth_getBlockByNumber", from, to, reportPath, func(c *svc.Controller) ([]*svc.BenchmarkSample, time.Duration) {
+		params := multiplex.ExecParams{
+			"from_block_number": from,
+			"to_block_number":   to,
+			"batch_size":        batchSize,
+		}
+		go c.DispatchOnce("GetBlocks", "get_blocks_range", params)
+		c.Run()
+		result := params.ReturnResult().(*svc.GetBlocksResult)
+		return svc.NewGetBlockSamples(result.Data), result.Elapsed
 	})
-	c.Run()
-	return nil
 }
 
-func (m *BenchmarkModule) TraceBlocks(from, to *big.Int, batchSize int) error {
+func (m *BenchmarkModule) TraceBlocks(from, to *big.Int, batchSize int, reportPath string) error {
 	m.logger.Info().Msg("Start debug_traceBlockByNumber benchmark.")
-	rpcClient, err := rpc.Connect(m.config.Blockchain.RpcUrl)
+	return m.benchmark("debug_traceBlockByNumber", from, to, reportPath, func(c *svc.Controller) ([]*svc.BenchmarkSample, time.Duration) {
+		params := multiplex.ExecParams{
+			"from_block_number": from,
+			"to_block_number":   to,
+			"batch_size":        batchSize,
+		}
+		go c.DispatchOnce("TraceBlocks", "trace_blocks_range", params)
+		c.Run()
+		result := params.ReturnResult().(*svc.TraceBlocksResult)
+		return svc.NewTraceBlockSamples(result.Data), result.Elapsed
+	})
+}
+
+// Run the same benchmark against each configured endpoint sequentially with identical settings.
+// A comparative report is written if there are more than one endpoint.
+func (m *BenchmarkModule) benchmark(method string, from, to *big.Int, reportPath string, run func(c *svc.Controller) ([]*svc.BenchmarkSample, time.Duration)) error {
+	endpoints := m.config.Blockchain.Rpc
+	if len(endpoints) == 0 {
+		return rpc.ErrNoEndpoint
+	}
+	reports := make([]*svc.BenchmarkReport, len(endpoints))
+	samples := make([][]*svc.BenchmarkSample, len(endpoints))
+	for i, endpoint := range endpoints {
+		m.logger.Info().Msgf("Benchmark endpoint %d of %d: %s.", i+1, len(endpoints), endpoint.Url)
+		rpcClient, err := rpc.NewEthPool(config.RpcEndpoints{endpoint}, m.config.Blockchain.RpcPool)
+		if err != nil {
+			return err
+		}
+		c := svc.NewController(m.config, nil, rpcClient, config.NewZerologLogger(m.logger))
+		endpointSamples, elapsed := run(c)
+		rpcClient.Close()
+		reports[i] = svc.NewBenchmarkReport(method, endpoint.Url, from.Uint64(), to.Uint64(), endpointSamples, elapsed)
+		samples[i] = endpointSamples
+	}
+	if len(reports) == 1 {
+		printBenchmarkReport(reports[0])
+		return m.saveReport(method, reports[0], reportPath)
+	}
+	comparison := svc.NewBenchmarkComparison(reports, samples)
+	printBenchmarkComparison(comparison)
+	return m.saveReport(method, comparison, reportPath)
+}
+
+// Save report as JSON to `reportPath`. Default path is used if `reportPath` is empty.
+func (m *BenchmarkModule) saveReport(method string, report interface{}, reportPath string) error {
+	if reportPath == "" {
+		fileName := fmt.Sprintf("%s_%s.json", method, time.Now().UTC().Format("20060102T150405Z"))
+		reportPath = filepath.Join(m.config.FileSystem.RootPath, "benchmark", fileName)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return err
 	}
-	c := svc.NewController(m.config, nil, rpcClient, config.NewZerologLogger(m.logger))
-	go c.DispatchOnce("TraceBlocks", "trace_blocks_range", multiplex.ExecParams{
-		"from_block_number": from,
-		"to_block_number":   to,
-		"batch_size":        batchSize,
-	})
-	c.Run()
+	err = filesystem.WriteFile(reportPath, data)
+	if err != nil {
+		return err
+	}
+	m.logger.Info().Msgf("Benchmark report saved to %s.", reportPath)
 	return nil
 }
 
@@ -61,6 +120,62 @@ func (m *BenchmarkModule) logError(err error) {
 	}
 }
 
+func printBenchmarkReport(report *svc.BenchmarkReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Method\t%s\n", report.Method)
+	fmt.Fprintf(w, "Endpoint\t%s\n", report.Endpoint)
+	fmt.Fprintf(w, "Blocks\t#%d - #%d (%d)\n", report.FromBlock, report.ToBlock, report.BlockCount)
+	fmt.Fprintf(w, "Elapsed\t%.0f ms\n", report.ElapsedMs)
+	fmt.Fprintf(w, "Latency p50\t%.1f ms\n", report.LatencyP50Ms)
+	fmt.Fprintf(w, "Latency p90\t%.1f ms\n", report.LatencyP90Ms)
+	fmt.Fprintf(w, "Latency p99\t%.1f ms\n", report.LatencyP99Ms)
+	fmt.Fprintf(w, "Latency max\t%.1f ms\n", report.LatencyMaxMs)
+	fmt.Fprintf(w, "Blocks/s\t%.2f\n", report.BlocksPerSecond)
+	fmt.Fprintf(w, "Bytes/s\t%.0f\n", report.BytesPerSecond)
+	fmt.Fprintf(w, "Total bytes\t%d\n", report.TotalBytes)
+	fmt.Fprintf(w, "Retries\t%d\n", report.RetryCount)
+	fmt.Fprintf(w, "Errors\t%d\n", report.ErrorCount)
+	for _, errorClass := range sortedErrorClasses(report.Errors) {
+		fmt.Fprintf(w, "  %s\t%d\n", errorClass, report.Errors[errorClass])
+	}
+	w.Flush()
+}
+
+func printBenchmarkComparison(comparison *svc.BenchmarkComparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ENDPOINT\tBLOCKS\tERRORS\tRETRIES\tP50 MS\tP90 MS\tP99 MS\tMAX MS\tBLOCKS/S\tBYTES/S\n")
+	for _, report := range comparison.Reports {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.2f\t%.0f\n", report.Endpoint, report.BlockCount, report.ErrorCount, report.RetryCount,
+			report.LatencyP50Ms, report.LatencyP90Ms, report.LatencyP99Ms, report.LatencyMaxMs, report.BlocksPerSecond, report.BytesPerSecond)
+	}
+	w.Flush()
+	for _, report := range comparison.Reports {
+		for _, errorClass := range sortedErrorClasses(report.Errors) {
+			fmt.Printf("%s: %d %s errors.\n", report.Endpoint, report.Errors[errorClass], errorClass)
+		}
+	}
+	fmt.Printf("%d blocks of #%d - #%d mismatched between endpoints.\n", len(comparison.Mismatches), comparison.FromBlock, comparison.ToBlock)
+	for i, mismatch := range comparison.Mismatches {
+		if i == benchmarkMismatchPrintLimit {
+			fmt.Printf("... %d more in report file.\n", len(comparison.Mismatches)-i)
+			break
+		}
+		fmt.Printf("#%d\n", mismatch.BlockNumber)
+		for j, report := range comparison.Reports {
+			fmt.Printf("  %s\t%s\n", report.Endpoint, opx.Ternary(mismatch.Digests[j] == "", "FAILED", mismatch.Digests[j]))
+		}
+	}
+}
+
+func sortedErrorClasses(errors map[rpc.ErrorClass]int) []rpc.ErrorClass {
+	errorClasses := make([]rpc.ErrorClass, 0, len(errors))
+	for errorClass := range errors {
+		errorClasses = append(errorClasses, errorClass)
+	}
+	slices.Sort(errorClasses)
+	return errorClasses
+}
+
 func BenchmarkCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "benchmark",
@@ -76,12 +191,14 @@ func BenchmarkCmd() *cobra.Command {
 			flags := ParseBenchmarkFlags(cmd)
 			c.ConfigFromCli(flags.Configs)
 			m := NewBenchmarkModule(c, "getBlock")
-			m.logError(m.GetBlocks(flags.From, flags.To, flags.Batch))
+			m.logError(m.GetBlocks(flags.From, flags.To, flags.Batch, flags.Report))
 		},
 	}
 	getBlocksCmd.Flags().Int("batch", 900, "Batch size.")
 	getBlocksCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
-	getBlocksCmd.Flags().String("rpc", "", "RPC URL.")
+	getBlocksCmd.Flags().String("report", "", "Path to JSON report. Default to benchmark directory under root path.")
+	getBlocksCmd.Flags().StringSlice("rpc", []string{}, "RPC URL. Repeat or separate by comma to compare multiple endpoints.")
+	getBlocksCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
 	getBlocksCmd.Flags().Uint64("thread", 0, "Number of concurrent requests.")
 	getBlocksCmd.Flags().Uint64P("to", "t", 1000, "To block number.")
 	rootCmd.AddCommand(getBlocksCmd)
@@ -95,12 +212,14 @@ func BenchmarkCmd() *cobra.Command {
 			flags := ParseBenchmarkFlags(cmd)
 			c.ConfigFromCli(flags.Configs)
 			m := NewBenchmarkModule(c, "traceBlock")
-			m.logError(m.TraceBlocks(flags.From, flags.To, flags.Batch))
+			m.logError(m.TraceBlocks(flags.From, flags.To, flags.Batch, flags.Report))
 		},
 	}
 	traceBlocksCmd.Flags().Int("batch", 900, "Batch size.")
 	traceBlocksCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
-	traceBlocksCmd.Flags().String("rpc", "", "RPC URL.")
+	traceBlocksCmd.Flags().String("report", "", "Path to JSON report. Default to benchmark directory under root path.")
+	traceBlocksCmd.Flags().StringSlice("rpc", []string{}, "RPC URL. Repeat or separate by comma to compare multiple endpoints.")
+	traceBlocksCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
 	traceBlocksCmd.Flags().Uint64("thread", 0, "Number of concurrent requests.")
 	traceBlocksCmd.Flags().Uint64P("to", "t", 1000, "To block number.")
 	rootCmd.AddCommand(traceBlocksCmd)
@@ -109,9 +228,10 @@ func BenchmarkCmd() *cobra.Command {
 }
 
 type BenchmarkFlags struct {
-	Batch int
-	From  *big.Int
-	To    *big.Int
+	Batch  int
+	From   *big.Int
+	Report string
+	To     *big.Int
 
 	Configs map[string]interface{}
 }
@@ -119,13 +239,19 @@ type BenchmarkFlags struct {
 func ParseBenchmarkFlags(cmd *cobra.Command) *BenchmarkFlags {
 	batch, _ := cmd.Flags().GetInt("batch")
 	from, _ := cmd.Flags().GetUint64("from")
-	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	report, _ := cmd.Flags().GetString("report")
+	rpcUrls, _ := cmd.Flags().GetStringSlice("rpc")
+	rpcBatch, _ := cmd.Flags().GetInt("rpc-batch")
 	thread, _ := cmd.Flags().GetUint64("thread")
 	to, _ := cmd.Flags().GetUint64("to")
 
 	configs := make(map[string]interface{})
-	if rpcUrl != "" {
-		configs[config.BlockchainRpcUrlKey] = rpcUrl
+	if len(rpcUrls) > 0 {
+		configs[config.BlockchainRpcUrlKey] = strings.Join(rpcUrls, ",")
+	}
+	if rpcBatch > 0 {
+		configs[config.ServiceRpcBatchGetBlockKey] = rpcBatch
+		configs[config.ServiceRpcBatchTraceBlockKey] = rpcBatch
 	}
 	if thread > 0 {
 		configs[config.ServiceWorkerGetBlockKey] = thread
@@ -135,6 +261,7 @@ func ParseBenchmarkFlags(cmd *cobra.Command) *BenchmarkFlags {
 	return &BenchmarkFlags{
 		Batch:   batch,
 		From:    new(big.Int).SetUint64(from),
+		Report:  report,
 		To:      new(big.Int).SetUint64(to),
 		Configs: configs,
 	}
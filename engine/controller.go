@@ -55,8 +55,8 @@ func (c *Controller) DbClient() (*db.DbClient, error) {
 	return db.Connect(c.Root.Database.PostgreSQL, "")
 }
 
-func (c *Controller) RpcClient() (*rpc.EthClient, error) {
-	return rpc.Connect(c.Root.Blockchain.RpcUrl)
+func (c *Controller) RpcClient() (*rpc.EthPool, error) {
+	return rpc.NewEthPool(c.Root.Blockchain.Rpc, c.Root.Blockchain.RpcPool)
 }
 
 func (c *Controller) CommandLogger(module, command string) zerolog.Logger {
@@ -1,28 +1,42 @@
 package engine
 
 import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
 	"viction-rpc-crawler-go/config"
 	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/filesystem"
+	"viction-rpc-crawler-go/rpc"
+	"viction-rpc-crawler-go/svc"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+	"github.com/tforce-io/tf-golib/opx"
 )
 
 type DatabaseModule struct {
-	config *config.RootConfig
-	logger zerolog.Logger
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
 }
 
 func NewDatabaseModule(c *Controller, cmdName string) *DatabaseModule {
 	return &DatabaseModule{
-		config: c.Root,
-		logger: c.CommandLogger("database", cmdName),
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("database", cmdName),
 	}
 }
 
 func (m *DatabaseModule) Migrate() error {
-	c, err := db.Connect(m.config.Database.PostgreSQL, "")
+	c, err := m.controller.DbClient()
 	if err != nil {
 		return err
 	}
@@ -35,6 +49,192 @@ func (m *DatabaseModule) Migrate() error {
 	return nil
 }
 
+func (m *DatabaseModule) Import(from, to *big.Int, batchSize int, root string, forced, includeTxs bool) error {
+	m.logger.Info().Msg("Start importing blocks from filesystem.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+		"include_txs":       includeTxs,
+		"root":              opx.Ternary(root == "", m.config.FileSystem.RootPath, root),
+	}
+	go c.DispatchOnce("IndexBlock", "import_blocks", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	return result.Error
+}
+
+func (m *DatabaseModule) Gaps(from, to *big.Int, batchSize int, checkTxs, fill bool) error {
+	m.logger.Info().Msg("Start looking for gaps in database.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	var rpcClient *rpc.EthPool
+	if fill {
+		rpcClient, err = m.controller.RpcClient()
+		if err != nil {
+			return err
+		}
+		defer rpcClient.Close()
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"check_txs":         checkTxs,
+		"fill":              fill,
+	}
+	go c.DispatchOnce("IndexBlock", "find_gaps", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.GapsResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	m.logger.Info().Msgf("Checked block #%d to #%d.", result.From.Uint64(), result.To.Uint64())
+	if len(result.Gaps) == 0 {
+		m.logger.Info().Msg("No missing blocks.")
+	} else {
+		missingCount := uint64(0)
+		for _, gap := range result.Gaps {
+			missingCount += gap.Count()
+		}
+		m.logger.Warn().Msgf("%d missing blocks: %s", missingCount, db.FormatBlockRanges(result.Gaps))
+	}
+	if checkTxs {
+		if len(result.Mismatches) == 0 {
+			m.logger.Info().Msg("No transaction count mismatches.")
+		} else {
+			mismatchNumbers := make([]uint64, len(result.Mismatches))
+			for i, mismatch := range result.Mismatches {
+				mismatchNumbers[i] = mismatch.BlockNumber
+				m.logger.Debug().Msgf("Block #%d has %d of %d transactions.", mismatch.BlockNumber, mismatch.Actual, mismatch.Expected)
+			}
+			m.logger.Warn().Msgf("%d blocks with transaction count mismatch: %s", len(result.Mismatches), db.FormatBlockRanges(db.CompactBlockNumbers(mismatchNumbers)))
+		}
+	}
+	if fill {
+		m.logger.Info().Msgf("%d blocks filled. Error count = %d.", result.FilledCount, result.ErrorCount)
+	}
+	return nil
+}
+
+func (m *DatabaseModule) Export(from, to *big.Int, tables []string, format, outDir string, chunkSize int, splitSize uint64) error {
+	m.logger.Info().Msg("Start exporting tables from database.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"tables":            tables,
+		"format":            format,
+		"root":              opx.Ternary(outDir == "", filepath.Join(m.config.FileSystem.RootPath, "export"), outDir),
+		"chunk_size":        chunkSize,
+		"split_size":        splitSize,
+	}
+	go c.DispatchOnce("ExportDatabase", "export_tables", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.ExportResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	for _, table := range tables {
+		m.logger.Info().Msgf("Exported %d rows of %s from block #%d to #%d.", result.RowCounts[table], table, result.From.Uint64(), result.To.Uint64())
+	}
+	m.logger.Info().Msgf("%d files written.", len(result.Files))
+	return nil
+}
+
+func (m *DatabaseModule) Rollback(to *big.Int, removeIssues, confirmed bool) error {
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"block_number":  to,
+		"remove_issues": removeIssues,
+		"dry_run":       !confirmed,
+	}
+	go c.DispatchOnce("IndexBlock", "rollback_blocks", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.RollbackResult)
+	if result.Summary != nil {
+		issueAction := "kept"
+		if removeIssues {
+			issueAction = "removed"
+		}
+		m.logger.Info().Msgf("Rollback to block #%d: %d blocks, %d transactions, %d internal transactions, %d receipts, %d logs, %d token transfers, %d block signatures, %d epochs and %d validator statistics will be removed. %d issues will be %s.",
+			to.Uint64(), result.Summary.BlockCount, result.Summary.TransactionCount, result.Summary.InternalTransactionCount,
+			result.Summary.ReceiptCount, result.Summary.LogCount, result.Summary.TokenTransferCount, result.Summary.BlockSignatureCount,
+			result.Summary.EpochCount, result.Summary.ValidatorStatCount, result.Summary.IssueCount, issueAction)
+		m.logger.Info().Msgf("Index checkpoint %s will be set to %s. Trace checkpoint %s will be set to %s. Receipt checkpoint %s will be set to %s. Token checkpoint %s will be set to %s. Validator checkpoint %s will be set to %s.",
+			formatCheckpoint(result.IndexCheckpoint), formatCheckpoint(rolledBackCheckpoint(result.IndexCheckpoint, to)),
+			formatCheckpoint(result.TraceCheckpoint), formatCheckpoint(rolledBackCheckpoint(result.TraceCheckpoint, to)),
+			formatCheckpoint(result.ReceiptCheckpoint), formatCheckpoint(rolledBackCheckpoint(result.ReceiptCheckpoint, to)),
+			formatCheckpoint(result.TokenCheckpoint), formatCheckpoint(rolledBackCheckpoint(result.TokenCheckpoint, to)),
+			formatCheckpoint(result.ValidatorCheckpoint), formatCheckpoint(rolledBackCheckpoint(result.ValidatorCheckpoint, to)))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.DryRun {
+		m.logger.Warn().Msg("Dry run. Nothing has been changed. Run again with --yes to proceed.")
+		return nil
+	}
+	m.logger.Info().Msg("Rollback successful!")
+	return nil
+}
+
+func (m *DatabaseModule) Status(exact, asJson bool) error {
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		m.logger.Warn().Err(err).Msg("RPC is not available. Lag will not be reported.")
+		rpcClient = nil
+	} else {
+		defer rpcClient.Close()
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"check_head": rpcClient != nil,
+		"exact":      exact,
+	}
+	go c.DispatchOnce("IndexBlock", "get_status", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.StatusResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.HeadError != nil {
+		m.logger.Warn().Err(result.HeadError).Msg("Failed to get node head. Lag will not be reported.")
+	}
+	status := NewDatabaseStatus(result)
+	if asJson {
+		return printJson(status)
+	}
+	status.Print(os.Stdout)
+	return nil
+}
+
 func (m *DatabaseModule) logError(err error) {
 	if err != nil {
 		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
@@ -59,19 +259,313 @@ func DatabaseCmd() *cobra.Command {
 			m.logError(m.Migrate())
 		},
 	}
+	migrateCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
 	rootCmd.AddCommand(migrateCmd)
 
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import downloaded eth_getBlockByNumber data from filesystem.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDatabaseFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDatabaseModule(c, "import")
+			m.logError(m.Import(flags.From, flags.To, flags.Batch, flags.Root, flags.Forced, flags.IncludeTxs))
+		},
+	}
+	importCmd.Flags().Int("batch", 900, "Number of blocks to persist in one write operation.")
+	importCmd.Flags().Bool("force", false, "Ignore the import checkpoint stored in database.")
+	importCmd.Flags().Uint64P("from", "f", 1, "Start block number.")
+	importCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	importCmd.Flags().String("root", "", "Root input dir.")
+	importCmd.Flags().Uint64P("to", "t", 1, "To block number.")
+	importCmd.Flags().Bool("txs", true, "Save transaction data to database.")
+	rootCmd.AddCommand(importCmd)
+
+	gapsCmd := &cobra.Command{
+		Use:   "gaps",
+		Short: "Find missing blocks and transactions in database.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDatabaseFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDatabaseModule(c, "gaps")
+			m.logError(m.Gaps(flags.From, flags.To, flags.Batch, flags.IncludeTxs, flags.Fill))
+		},
+	}
+	gapsCmd.Flags().Int("batch", 900, "Number of blocks to fill in one batch.")
+	gapsCmd.Flags().Bool("fill", false, "Fetch missing blocks from RPC and write them to database.")
+	gapsCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	gapsCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	gapsCmd.Flags().String("rpc", "", "RPC URL.")
+	gapsCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
+	gapsCmd.Flags().Uint64P("to", "t", 0, "To block number. Use 0 for the index checkpoint.")
+	gapsCmd.Flags().Bool("txs", true, "Compare stored transactions with transaction count of blocks.")
+	gapsCmd.Flags().Uint64("worker", 0, "Number of concurrent requests.")
+	rootCmd.AddCommand(gapsCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export tables to CSV, JSONL or Parquet files split by block range.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDatabaseFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDatabaseModule(c, "export")
+			m.logError(m.Export(flags.From, flags.To, flags.Tables, flags.Format, flags.Out, flags.Batch, flags.Split))
+		},
+	}
+	exportCmd.Flags().Int("batch", 1000, "Number of blocks to read from database in one query.")
+	exportCmd.Flags().String("format", filesystem.ExportFormatCsv, "Output format. Supported values: csv, jsonl, parquet.")
+	exportCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	exportCmd.Flags().String("out", "", "Output dir. Default to export dir under root path.")
+	exportCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	exportCmd.Flags().Uint64("split", 100000, "Number of blocks in one output file. Use 0 for a single file per table.")
+	exportCmd.Flags().StringSlice("tables", []string{svc.BlocksTable, svc.TransactionsTable}, "Tables to export. Supported values: blocks, transactions, issues.")
+	exportCmd.Flags().Uint64P("to", "t", 0, "To block number. Use 0 for the index checkpoint.")
+	rootCmd.AddCommand(exportCmd)
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Remove blocks and transactions above a block number and reset checkpoints.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDatabaseFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDatabaseModule(c, "rollback")
+			m.logError(m.Rollback(flags.To, flags.RemoveIssues, flags.Yes))
+		},
+	}
+	rollbackCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	rollbackCmd.Flags().Bool("remove-issues", false, "Also remove issues of blocks above the target block number.")
+	rollbackCmd.Flags().Uint64P("to", "t", 0, "Highest block number to keep.")
+	rollbackCmd.Flags().Bool("yes", false, "Perform the rollback. Without this flag only a summary is shown.")
+	rollbackCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(rollbackCmd)
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Report checkpoints, stored block range, row counts and open issues.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDatabaseFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDatabaseModule(c, "status")
+			m.logError(m.Status(flags.Exact, flags.Json))
+		},
+	}
+	statusCmd.Flags().Bool("exact", false, "Count rows exactly instead of using planner estimates. Slow on large tables.")
+	statusCmd.Flags().Bool("json", false, "Print status as JSON.")
+	statusCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	statusCmd.Flags().String("rpc", "", "RPC URL.")
+	rootCmd.AddCommand(statusCmd)
+
 	return rootCmd
 }
 
+// DatabaseStatus is the printable form of database status. Missing values are nil.
+type DatabaseStatus struct {
+	IndexCheckpoint     *uint64          `json:"index_checkpoint"`
+	TraceCheckpoint     *uint64          `json:"trace_checkpoint"`
+	ReceiptCheckpoint   *uint64          `json:"receipt_checkpoint"`
+	TokenCheckpoint     *uint64          `json:"token_checkpoint"`
+	ValidatorCheckpoint *uint64          `json:"validator_checkpoint"`
+	ImportCheckpoint    *uint64          `json:"import_checkpoint"`
+	MinBlockNumber      *uint64          `json:"min_block_number"`
+	MaxBlockNumber      *uint64          `json:"max_block_number"`
+	Head                *uint64          `json:"head"`
+	IndexLag            *int64           `json:"index_lag"`
+	TraceLag            *int64           `json:"trace_lag"`
+	ReceiptLag          *int64           `json:"receipt_lag"`
+	TokenLag            *int64           `json:"token_lag"`
+	ValidatorLag        *int64           `json:"validator_lag"`
+	RowCounts           map[string]int64 `json:"row_counts"`
+	ExactRowCounts      bool             `json:"exact_row_counts"`
+	OpenIssueCounts     map[string]int64 `json:"open_issue_counts"`
+}
+
+func NewDatabaseStatus(result *svc.StatusResult) *DatabaseStatus {
+	status := &DatabaseStatus{
+		IndexCheckpoint:     bigIntToUint64Ptr(result.IndexCheckpoint),
+		TraceCheckpoint:     bigIntToUint64Ptr(result.TraceCheckpoint),
+		ReceiptCheckpoint:   bigIntToUint64Ptr(result.ReceiptCheckpoint),
+		TokenCheckpoint:     bigIntToUint64Ptr(result.TokenCheckpoint),
+		ValidatorCheckpoint: bigIntToUint64Ptr(result.ValidatorCheckpoint),
+		ImportCheckpoint:    bigIntToUint64Ptr(result.ImportCheckpoint),
+		Head:                bigIntToUint64Ptr(result.Head),
+		IndexLag:            bigIntToInt64Ptr(result.IndexLag),
+		TraceLag:            bigIntToInt64Ptr(result.TraceLag),
+		ReceiptLag:          bigIntToInt64Ptr(result.ReceiptLag),
+		TokenLag:            bigIntToInt64Ptr(result.TokenLag),
+		ValidatorLag:        bigIntToInt64Ptr(result.ValidatorLag),
+		RowCounts:           result.RowCounts,
+		ExactRowCounts:      result.ExactRowCounts,
+		OpenIssueCounts:     map[string]int64{},
+	}
+	if result.Bounds != nil {
+		status.MinBlockNumber = &result.Bounds.From
+		status.MaxBlockNumber = &result.Bounds.To
+	}
+	for issueType, count := range result.OpenIssueCounts {
+		status.OpenIssueCounts[db.IssueTypeName(issueType)] = count
+	}
+	return status
+}
+
+func (s *DatabaseStatus) Print(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Index checkpoint:\t%s\n", formatBlockNumber(s.IndexCheckpoint, s.IndexLag))
+	fmt.Fprintf(w, "Trace checkpoint:\t%s\n", formatBlockNumber(s.TraceCheckpoint, s.TraceLag))
+	fmt.Fprintf(w, "Receipt checkpoint:\t%s\n", formatBlockNumber(s.ReceiptCheckpoint, s.ReceiptLag))
+	fmt.Fprintf(w, "Token checkpoint:\t%s\n", formatBlockNumber(s.TokenCheckpoint, s.TokenLag))
+	fmt.Fprintf(w, "Validator checkpoint:\t%s\n", formatBlockNumber(s.ValidatorCheckpoint, s.ValidatorLag))
+	fmt.Fprintf(w, "Import checkpoint:\t%s\n", formatBlockNumber(s.ImportCheckpoint, nil))
+	if s.MinBlockNumber == nil {
+		fmt.Fprintf(w, "Stored blocks:\tnone\n")
+	} else {
+		fmt.Fprintf(w, "Stored blocks:\t#%d to #%d\n", *s.MinBlockNumber, *s.MaxBlockNumber)
+	}
+	fmt.Fprintf(w, "Node head:\t%s\n", formatBlockNumber(s.Head, nil))
+	if s.ExactRowCounts {
+		fmt.Fprintf(w, "Row counts:\t\n")
+	} else {
+		fmt.Fprintf(w, "Row counts (estimated):\t\n")
+	}
+	for _, table := range db.StatusTableNames() {
+		fmt.Fprintf(w, "  %s\t%d\n", table, s.RowCounts[table])
+	}
+	fmt.Fprintf(w, "Open issues:\t\n")
+	if len(s.OpenIssueCounts) == 0 {
+		fmt.Fprintf(w, "  none\t\n")
+	}
+	issueTypes := make([]string, 0, len(s.OpenIssueCounts))
+	for issueType := range s.OpenIssueCounts {
+		issueTypes = append(issueTypes, issueType)
+	}
+	slices.Sort(issueTypes)
+	for _, issueType := range issueTypes {
+		fmt.Fprintf(w, "  %s\t%d\n", issueType, s.OpenIssueCounts[issueType])
+	}
+	w.Flush()
+}
+
+// Return checkpoint after rollback to number. Checkpoints are only lowered.
+func rolledBackCheckpoint(checkpoint, number *big.Int) *big.Int {
+	if checkpoint == nil || checkpoint.Cmp(number) <= 0 {
+		return checkpoint
+	}
+	return number
+}
+
+func formatCheckpoint(checkpoint *big.Int) string {
+	if checkpoint == nil {
+		return "none"
+	}
+	return fmt.Sprintf("#%d", checkpoint.Uint64())
+}
+
+func formatBlockNumber(number *uint64, lag *int64) string {
+	if number == nil {
+		return "none"
+	}
+	if lag == nil {
+		return fmt.Sprintf("#%d", *number)
+	}
+	return fmt.Sprintf("#%d (lag %d)", *number, *lag)
+}
+
+func bigIntToUint64Ptr(n *big.Int) *uint64 {
+	if n == nil {
+		return nil
+	}
+	v := n.Uint64()
+	return &v
+}
+
+func bigIntToInt64Ptr(n *big.Int) *int64 {
+	if n == nil {
+		return nil
+	}
+	v := n.Int64()
+	return &v
+}
+
 type DatabaseFlags struct {
+	Batch        int
+	Exact        bool
+	Fill         bool
+	Forced       bool
+	Format       string
+	From         *big.Int
+	IncludeTxs   bool
+	Json         bool
+	Out          string
+	RemoveIssues bool
+	Root         string
+	Split        uint64
+	Tables       []string
+	To           *big.Int
+	Yes          bool
+
 	Configs map[string]interface{}
 }
 
 func ParseDatabaseFlags(cmd *cobra.Command) *DatabaseFlags {
+	batch, _ := cmd.Flags().GetInt("batch")
+	exact, _ := cmd.Flags().GetBool("exact")
+	fill, _ := cmd.Flags().GetBool("fill")
+	forced, _ := cmd.Flags().GetBool("force")
+	format, _ := cmd.Flags().GetString("format")
+	from, _ := cmd.Flags().GetUint64("from")
+	asJson, _ := cmd.Flags().GetBool("json")
+	outDir, _ := cmd.Flags().GetString("out")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	removeIssues, _ := cmd.Flags().GetBool("remove-issues")
+	rootDir, _ := cmd.Flags().GetString("root")
+	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	rpcBatch, _ := cmd.Flags().GetInt("rpc-batch")
+	split, _ := cmd.Flags().GetUint64("split")
+	tables, _ := cmd.Flags().GetStringSlice("tables")
+	to, _ := cmd.Flags().GetUint64("to")
+	includeTxs, _ := cmd.Flags().GetBool("txs")
+	worker, _ := cmd.Flags().GetUint64("worker")
+	yes, _ := cmd.Flags().GetBool("yes")
+
 	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+	if rpcUrl != "" {
+		configs[config.BlockchainRpcUrlKey] = rpcUrl
+	}
+	if rpcBatch > 0 {
+		configs[config.ServiceRpcBatchGetBlockKey] = rpcBatch
+	}
+	if worker > 0 {
+		configs[config.ServiceWorkerGetBlockKey] = worker
+	}
 
 	return &DatabaseFlags{
-		Configs: configs,
+		Batch:        batch,
+		Exact:        exact,
+		Fill:         fill,
+		Forced:       forced,
+		Format:       format,
+		From:         new(big.Int).SetUint64(from),
+		IncludeTxs:   includeTxs,
+		Json:         asJson,
+		Out:          outDir,
+		RemoveIssues: removeIssues,
+		Root:         rootDir,
+		Split:        split,
+		Tables:       tables,
+		To:           new(big.Int).SetUint64(to),
+		Yes:          yes,
+		Configs:      configs,
 	}
 }
@@ -3,7 +3,7 @@ package engine
 import (
 	"math/big"
 	"viction-rpc-crawler-go/config"
-	"viction-rpc-crawler-go/rpc"
+	"viction-rpc-crawler-go/db"
 	"viction-rpc-crawler-go/svc"
 
 	"github.com/rs/zerolog"
@@ -13,24 +13,34 @@ import (
 )
 
 type DownloadModule struct {
-	config *config.RootConfig
-	logger zerolog.Logger
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
 }
 
 func NewDownloadModule(c *Controller, cmdName string) *DownloadModule {
 	return &DownloadModule{
-		config: c.Root,
-		logger: c.CommandLogger("download", cmdName),
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("download", cmdName),
 	}
 }
 
 func (m *DownloadModule) GetBlocks(from, to *big.Int, batchSize int, root string) error {
 	m.logger.Info().Msg("Start eth_getBlockByNumber download.")
-	rpcClient, err := rpc.Connect(m.config.Blockchain.RpcUrl)
+	rpcClient, err := m.controller.RpcClient()
 	if err != nil {
 		return err
 	}
-	c := svc.NewController(m.config, nil, rpcClient, config.NewZerologLogger(m.logger))
+	defer rpcClient.Close()
+	dbClient, err := m.connectDatabase()
+	if err != nil {
+		return err
+	}
+	if dbClient != nil {
+		defer dbClient.Disconnect()
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
 	go c.DispatchOnce("DownloadBlock", "download_blocks", multiplex.ExecParams{
 		"from_block_number": from,
 		"to_block_number":   to,
@@ -43,11 +53,19 @@ func (m *DownloadModule) GetBlocks(from, to *big.Int, batchSize int, root string
 
 func (m *DownloadModule) GetBlockTraces(from, to *big.Int, batchSize int, root string) error {
 	m.logger.Info().Msg("Start debug_traceBlockByNumber download.")
-	rpcClient, err := rpc.Connect(m.config.Blockchain.RpcUrl)
+	rpcClient, err := m.controller.RpcClient()
 	if err != nil {
 		return err
 	}
-	c := svc.NewController(m.config, nil, rpcClient, config.NewZerologLogger(m.logger))
+	defer rpcClient.Close()
+	dbClient, err := m.connectDatabase()
+	if err != nil {
+		return err
+	}
+	if dbClient != nil {
+		defer dbClient.Disconnect()
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
 	go c.DispatchOnce("DownloadBlock", "download_block_traces", multiplex.ExecParams{
 		"from_block_number": from,
 		"to_block_number":   to,
@@ -58,6 +76,43 @@ func (m *DownloadModule) GetBlockTraces(from, to *big.Int, batchSize int, root s
 	return nil
 }
 
+func (m *DownloadModule) RetryFailed(batchSize int, root string) error {
+	m.logger.Info().Msg("Start retrying failed blocks.")
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	dbClient, err := m.connectDatabase()
+	if err != nil {
+		return err
+	}
+	if dbClient != nil {
+		defer dbClient.Disconnect()
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"batch_size": batchSize,
+		"root":       opx.Ternary(root == "", m.config.FileSystem.RootPath, root),
+	}
+	go c.DispatchOnce("DownloadBlock", "retry_failed", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.RetryFailedResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	m.logger.Info().Msgf("%d failed blocks retried. %d blocks resolved.", result.RetriedCount, result.ResolvedCount)
+	return nil
+}
+
+// Failed blocks are recorded in database if it is configured, otherwise in the failure ledger under root dir.
+func (m *DownloadModule) connectDatabase() (*db.DbClient, error) {
+	if m.config.Database.PostgreSQL == "" {
+		return nil, nil
+	}
+	return m.controller.DbClient()
+}
+
 func (m *DownloadModule) logError(err error) {
 	if err != nil {
 		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
@@ -84,7 +139,9 @@ func DownloadCmd() *cobra.Command {
 	}
 	getBlocksCmd.Flags().Int("batch", 1, "Batch size.")
 	getBlocksCmd.Flags().Uint64P("from", "f", 1, "Start block number.")
+	getBlocksCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL. Failed blocks are recorded as issues if set.")
 	getBlocksCmd.Flags().String("rpc", "", "RPC URL.")
+	getBlocksCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
 	getBlocksCmd.Flags().String("root", "", "Root output dir.")
 	getBlocksCmd.Flags().Uint64("thread", 0, "Number of concurrent requests.")
 	getBlocksCmd.Flags().Uint64P("to", "t", 1, "To block number.")
@@ -104,12 +161,34 @@ func DownloadCmd() *cobra.Command {
 	}
 	traceBlocksCmd.Flags().Int("batch", 1, "Batch size.")
 	traceBlocksCmd.Flags().Uint64P("from", "f", 1, "Start block number.")
+	traceBlocksCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL. Failed blocks are recorded as issues if set.")
 	traceBlocksCmd.Flags().String("rpc", "", "RPC URL.")
+	traceBlocksCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
 	traceBlocksCmd.Flags().String("root", "", "Root output dir.")
 	traceBlocksCmd.Flags().Uint64("thread", 0, "Number of concurrent requests.")
 	traceBlocksCmd.Flags().Uint64P("to", "t", 1, "To block number.")
 	rootCmd.AddCommand(traceBlocksCmd)
 
+	retryFailedCmd := &cobra.Command{
+		Use:   "retry-failed",
+		Short: "Download recorded failed blocks again and resolve them.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseDownloadFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewDownloadModule(c, "retryFailed")
+			m.logError(m.RetryFailed(flags.Batch, flags.Root))
+		},
+	}
+	retryFailedCmd.Flags().Int("batch", 100, "Batch size.")
+	retryFailedCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	retryFailedCmd.Flags().String("rpc", "", "RPC URL.")
+	retryFailedCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
+	retryFailedCmd.Flags().String("root", "", "Root output dir.")
+	retryFailedCmd.Flags().Uint64("thread", 0, "Number of concurrent requests.")
+	rootCmd.AddCommand(retryFailedCmd)
+
 	return rootCmd
 }
 
@@ -125,15 +204,24 @@ type DownloadFlags struct {
 func ParseDownloadFlags(cmd *cobra.Command) *DownloadFlags {
 	batch, _ := cmd.Flags().GetInt("batch")
 	from, _ := cmd.Flags().GetUint64("from")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
 	rootDir, _ := cmd.Flags().GetString("root")
 	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	rpcBatch, _ := cmd.Flags().GetInt("rpc-batch")
 	thread, _ := cmd.Flags().GetUint64("thread")
 	to, _ := cmd.Flags().GetUint64("to")
 
 	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
 	if rpcUrl != "" {
 		configs[config.BlockchainRpcUrlKey] = rpcUrl
 	}
+	if rpcBatch > 0 {
+		configs[config.ServiceRpcBatchGetBlockKey] = rpcBatch
+		configs[config.ServiceRpcBatchTraceBlockKey] = rpcBatch
+	}
 	if thread > 0 {
 		configs[config.ServiceWorkerGetBlockKey] = thread
 		configs[config.ServiceWorkerTraceBlockKey] = thread
@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/svc"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+const (
+	IndexModeBlocks     = "blocks"
+	IndexModeReceipts   = "receipts"
+	IndexModeTokens     = "tokens"
+	IndexModeTrace      = "trace"
+	IndexModeValidators = "validators"
+)
+
+type IndexModule struct {
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
+}
+
+func NewIndexModule(c *Controller, cmdName string) *IndexModule {
+	return &IndexModule{
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("index", cmdName),
+	}
+}
+
+func (m *IndexModule) IndexBlocks(from, to *big.Int, batchSize int, forced, includeTxs, includeReceipts bool) error {
+	m.logger.Info().Msg("Start indexing blocks.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+		"include_txs":       includeTxs,
+		"include_receipts":  includeReceipts,
+	}
+	go c.DispatchOnce("IndexBlock", "index_blocks", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	return result.Error
+}
+
+func (m *IndexModule) IndexTraces(from, to *big.Int, batchSize int, forced bool) error {
+	m.logger.Info().Msg("Start indexing traces.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+	}
+	go c.DispatchOnce("IndexBlock", "index_traces", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	if result.Error == nil {
+		m.logger.Info().Msgf("%d blocks traced. %d internal transactions saved.", result.BlockCount, result.InternalTxCount)
+	}
+	return result.Error
+}
+
+func (m *IndexModule) IndexReceipts(from, to *big.Int, batchSize int, forced bool) error {
+	m.logger.Info().Msg("Start indexing receipts.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+	}
+	go c.DispatchOnce("IndexBlock", "index_receipts", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	if result.Error == nil {
+		m.logger.Info().Msgf("Receipts of %d blocks indexed. %d receipts saved.", result.BlockCount, result.ReceiptCount)
+	}
+	return result.Error
+}
+
+func (m *IndexModule) IndexTokens(from, to *big.Int, batchSize int, forced bool) error {
+	m.logger.Info().Msg("Start indexing token transfers.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+	}
+	go c.DispatchOnce("IndexBlock", "index_tokens", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	if result.Error == nil {
+		m.logger.Info().Msgf("Token transfers of %d blocks indexed. %d transfers and %d new tokens saved.", result.BlockCount, result.TransferCount, result.TokenCount)
+	}
+	return result.Error
+}
+
+func (m *IndexModule) IndexValidators(from, to *big.Int, batchSize int, forced bool) error {
+	m.logger.Info().Msg("Start indexing validator statistics.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+		"forced":            forced,
+	}
+	go c.DispatchOnce("IndexBlock", "index_validators", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IndexBlocksResult)
+	if result.Error == nil {
+		m.logger.Info().Msgf("Validator statistics of %d blocks indexed. %d missed slots found.", result.BlockCount, result.MissedSlotCount)
+	}
+	return result.Error
+}
+
+func (m *IndexModule) logError(err error) {
+	if err != nil {
+		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
+	}
+}
+
+func IndexCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "index",
+		Short: "Index blocks and transactions from RPC to PostgreSQL.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseIndexFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewIndexModule(c, "index")
+			switch flags.Mode {
+			case IndexModeBlocks:
+				m.logError(m.IndexBlocks(flags.From, flags.To, flags.Batch, flags.Forced, flags.IncludeTxs, flags.IncludeReceipts))
+			case IndexModeReceipts:
+				m.logError(m.IndexReceipts(flags.From, flags.To, flags.Batch, flags.Forced))
+			case IndexModeTokens:
+				m.logError(m.IndexTokens(flags.From, flags.To, flags.Batch, flags.Forced))
+			case IndexModeTrace:
+				m.logError(m.IndexTraces(flags.From, flags.To, flags.Batch, flags.Forced))
+			case IndexModeValidators:
+				m.logError(m.IndexValidators(flags.From, flags.To, flags.Batch, flags.Forced))
+			default:
+				m.logError(fmt.Errorf("unknown index mode %s", flags.Mode))
+			}
+		},
+	}
+	rootCmd.Flags().Int("batch", 900, "Number of blocks to persist in one write operation.")
+	rootCmd.Flags().Bool("force", false, "Ignore the checkpoint number stored in database.")
+	rootCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	rootCmd.Flags().String("mode", IndexModeBlocks, "Indexing mode. Supported values: blocks, receipts, tokens, trace, validators. Receipts and trace modes backfill indexed blocks, tokens mode decodes indexed logs, validators mode summarizes block production of indexed blocks.")
+	rootCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	rootCmd.Flags().String("rpc", "", "RPC URL.")
+	rootCmd.Flags().Bool("receipts", false, "Save receipts and logs along with blocks.")
+	rootCmd.Flags().Int("rpc-batch", 0, "Number of requests in one JSON-RPC batch.")
+	rootCmd.Flags().Uint64P("to", "t", 0, "To block number. Zero means current head of the chain, or index checkpoint in receipts, trace and validators modes.")
+	rootCmd.Flags().Bool("txs", true, "Save transaction data to database.")
+	rootCmd.Flags().Uint64("worker", 0, "Number of concurrent requests.")
+
+	return rootCmd
+}
+
+type IndexFlags struct {
+	Batch           int
+	Forced          bool
+	From            *big.Int
+	IncludeReceipts bool
+	IncludeTxs      bool
+	Mode            string
+	To              *big.Int
+
+	Configs map[string]interface{}
+}
+
+func ParseIndexFlags(cmd *cobra.Command) *IndexFlags {
+	batch, _ := cmd.Flags().GetInt("batch")
+	forced, _ := cmd.Flags().GetBool("force")
+	from, _ := cmd.Flags().GetUint64("from")
+	mode, _ := cmd.Flags().GetString("mode")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	includeReceipts, _ := cmd.Flags().GetBool("receipts")
+	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	rpcBatch, _ := cmd.Flags().GetInt("rpc-batch")
+	to, _ := cmd.Flags().GetUint64("to")
+	includeTxs, _ := cmd.Flags().GetBool("txs")
+	worker, _ := cmd.Flags().GetUint64("worker")
+
+	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+	if rpcUrl != "" {
+		configs[config.BlockchainRpcUrlKey] = rpcUrl
+	}
+	if rpcBatch > 0 {
+		configs[config.ServiceRpcBatchGetBlockKey] = rpcBatch
+		configs[config.ServiceRpcBatchTraceBlockKey] = rpcBatch
+	}
+	if worker > 0 {
+		configs[config.ServiceWorkerGetBlockKey] = worker
+		configs[config.ServiceWorkerGetReceiptKey] = worker
+		configs[config.ServiceWorkerGetTokenKey] = worker
+		configs[config.ServiceWorkerTraceBlockKey] = worker
+	}
+
+	return &IndexFlags{
+		Batch:           batch,
+		Forced:          forced,
+		From:            new(big.Int).SetUint64(from),
+		IncludeReceipts: includeReceipts,
+		IncludeTxs:      includeTxs,
+		Mode:            mode,
+		To:              new(big.Int).SetUint64(to),
+		Configs:         configs,
+	}
+}
@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+	"viction-rpc-crawler-go/svc"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+const (
+	IssueStatusOpen     = "open"
+	IssueStatusResolved = "resolved"
+	IssueStatusAll      = "all"
+)
+
+type IssuesModule struct {
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
+}
+
+func NewIssuesModule(c *Controller, cmdName string) *IssuesModule {
+	return &IssuesModule{
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("issues", cmdName),
+	}
+}
+
+func (m *IssuesModule) List(filter *db.IssueFilter, asJson bool) error {
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"filter": filter,
+	}
+	go c.DispatchOnce("ReadDatabase", "get_issues", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IssuesResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	views := make([]*IssueView, len(result.Data))
+	for i, issue := range result.Data {
+		views[i] = NewIssueView(issue)
+	}
+	if asJson {
+		return printJson(views)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HASH\tTYPE\tBLOCK\tSTATUS\tTIME\n")
+	for _, view := range views {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", view.Hash, view.Type, view.BlockNumber, view.Status, view.Time)
+	}
+	w.Flush()
+	m.logger.Info().Msgf("%d issues found.", len(views))
+	return nil
+}
+
+func (m *IssuesModule) Show(hash string) error {
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"hash": normalizeIssueHash(hash),
+	}
+	go c.DispatchOnce("ReadDatabase", "get_issue", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.IssuesResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(result.Data) == 0 {
+		return fmt.Errorf("issue %s not found", hash)
+	}
+	return printJson(NewIssueView(result.Data[0]))
+}
+
+func (m *IssuesModule) Resolve(hashes []string, filter *db.IssueFilter, auto bool) error {
+	if len(hashes) == 0 && !auto {
+		return errors.New("issue hashes are required unless --auto is set")
+	}
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	var rpcClient *rpc.EthPool
+	if auto {
+		rpcClient, err = m.controller.RpcClient()
+		if err != nil {
+			return err
+		}
+		defer rpcClient.Close()
+	}
+	for i, hash := range hashes {
+		hashes[i] = normalizeIssueHash(hash)
+	}
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"hashes": hashes,
+		"filter": filter,
+		"auto":   auto,
+	}
+	go c.DispatchOnce("ResolveIssue", "resolve_issues", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.ResolveIssuesResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	resolvedCount := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HASH\tTYPE\tBLOCK\tRESOLVED\tREASON\n")
+	for _, check := range result.Checks {
+		if check.Resolved {
+			resolvedCount++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%s\n", check.Issue.Hash, db.IssueTypeName(check.Issue.Type), check.Issue.BlockNumber, check.Resolved, check.Reason)
+	}
+	w.Flush()
+	m.logger.Info().Msgf("%d of %d issues resolved.", resolvedCount, len(result.Checks))
+	return nil
+}
+
+func (m *IssuesModule) logError(err error) {
+	if err != nil {
+		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
+	}
+}
+
+// IssueView is the printable form of an issue.
+type IssueView struct {
+	ID          uint64                 `json:"id"`
+	Hash        string                 `json:"hash"`
+	Type        string                 `json:"type"`
+	BlockNumber uint64                 `json:"block_number"`
+	BlockHash   string                 `json:"block_hash"`
+	TxHash      string                 `json:"tx_hash"`
+	Status      string                 `json:"status"`
+	Time        string                 `json:"time"`
+	Extras      map[string]interface{} `json:"extras"`
+}
+
+func NewIssueView(issue *db.Issue) *IssueView {
+	status := IssueStatusOpen
+	if issue.Status {
+		status = IssueStatusResolved
+	}
+	return &IssueView{
+		ID:          issue.ID,
+		Hash:        issue.Hash,
+		Type:        db.IssueTypeName(issue.Type),
+		BlockNumber: issue.BlockNumber,
+		BlockHash:   issue.BlockHash,
+		TxHash:      issue.TxHash,
+		Status:      status,
+		Time:        time.UnixMicro(issue.Timestamp).UTC().Format(time.RFC3339),
+		Extras:      issue.Extras,
+	}
+}
+
+func printJson(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func normalizeIssueHash(hash string) string {
+	return strings.ToLower(strings.TrimPrefix(hash, "0x"))
+}
+
+func IssuesCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "issues",
+		Short: "Review and resolve issues found while indexing.",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List issues filtered by type, block range and status.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseIssuesFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewIssuesModule(c, "list")
+			if flags.Error != nil {
+				m.logError(flags.Error)
+				return
+			}
+			m.logError(m.List(flags.Filter, flags.Json))
+		},
+	}
+	listCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	listCmd.Flags().Bool("json", false, "Print issues as JSON.")
+	listCmd.Flags().Int("limit", 100, "Maximum number of issues. Use 0 for no limit.")
+	listCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	listCmd.Flags().String("status", IssueStatusOpen, "Issue status. Supported values: open, resolved, all.")
+	listCmd.Flags().Uint64P("to", "t", 0, "To block number. Use 0 for no upper bound.")
+	listCmd.Flags().StringSlice("type", []string{}, "Issue types, e.g. reorg_block, duplicated_tx_hash.")
+	rootCmd.AddCommand(listCmd)
+
+	showCmd := &cobra.Command{
+		Use:   "show <hash>",
+		Short: "Show a single issue.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseIssuesFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewIssuesModule(c, "show")
+			m.logError(m.Show(args[0]))
+		},
+	}
+	showCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	rootCmd.AddCommand(showCmd)
+
+	resolveCmd := &cobra.Command{
+		Use:   "resolve [hash...]",
+		Short: "Mark issues as resolved by hand or after re-checking the chain.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseIssuesFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewIssuesModule(c, "resolve")
+			if flags.Error != nil {
+				m.logError(flags.Error)
+				return
+			}
+			m.logError(m.Resolve(args, flags.Filter, flags.Auto))
+		},
+	}
+	resolveCmd.Flags().Bool("auto", false, "Only resolve issues that no longer hold against the chain and database. Without hashes, all open issues matching filters are checked.")
+	resolveCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	resolveCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	resolveCmd.Flags().String("rpc", "", "RPC URL.")
+	resolveCmd.Flags().Uint64P("to", "t", 0, "To block number. Use 0 for no upper bound.")
+	resolveCmd.Flags().StringSlice("type", []string{}, "Issue types, e.g. reorg_block, duplicated_tx_hash.")
+	rootCmd.AddCommand(resolveCmd)
+
+	return rootCmd
+}
+
+type IssuesFlags struct {
+	Auto   bool
+	Filter *db.IssueFilter
+	Json   bool
+
+	Configs map[string]interface{}
+	// Invalid flag values.
+	Error error
+}
+
+func ParseIssuesFlags(cmd *cobra.Command) *IssuesFlags {
+	auto, _ := cmd.Flags().GetBool("auto")
+	from, _ := cmd.Flags().GetUint64("from")
+	asJson, _ := cmd.Flags().GetBool("json")
+	limit, _ := cmd.Flags().GetInt("limit")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	status, _ := cmd.Flags().GetString("status")
+	to, _ := cmd.Flags().GetUint64("to")
+	typeNames, _ := cmd.Flags().GetStringSlice("type")
+
+	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+	if rpcUrl != "" {
+		configs[config.BlockchainRpcUrlKey] = rpcUrl
+	}
+
+	flags := &IssuesFlags{
+		Auto: auto,
+		Filter: &db.IssueFilter{
+			Types:     []uint16{},
+			FromBlock: from,
+			ToBlock:   to,
+			Limit:     limit,
+		},
+		Json:    asJson,
+		Configs: configs,
+	}
+	for _, typeName := range typeNames {
+		issueType, ok := db.ParseIssueType(typeName)
+		if !ok {
+			flags.Error = fmt.Errorf("unknown issue type %s", typeName)
+			return flags
+		}
+		flags.Filter.Types = append(flags.Filter.Types, issueType)
+	}
+	switch status {
+	case "", IssueStatusAll:
+	case IssueStatusOpen, IssueStatusResolved:
+		resolved := status == IssueStatusResolved
+		flags.Filter.Status = &resolved
+	default:
+		flags.Error = fmt.Errorf("unknown issue status %s", status)
+	}
+	return flags
+}
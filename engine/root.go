@@ -16,6 +16,11 @@ func Execute() {
 	rootCmd.AddCommand(BenchmarkCmd())
 	rootCmd.AddCommand(DatabaseCmd())
 	rootCmd.AddCommand(DownloadCmd())
+	rootCmd.AddCommand(IndexCmd())
+	rootCmd.AddCommand(IssuesCmd())
+	rootCmd.AddCommand(ServiceCmd())
+	rootCmd.AddCommand(StatsCmd())
+	rootCmd.AddCommand(VerifyCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
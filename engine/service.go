@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/svc"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+type ServiceModule struct {
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
+}
+
+func NewServiceModule(c *Controller, cmdName string) *ServiceModule {
+	return &ServiceModule{
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("service", cmdName),
+	}
+}
+
+func (m *ServiceModule) Run() error {
+	m.logger.Info().Msg("Start service.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	rpcClient, err := m.controller.RpcClient()
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+	c := svc.NewController(m.config, dbClient, rpcClient, config.NewZerologLogger(m.logger))
+	schedule := m.config.Service.Schedule
+	interval := time.Duration(schedule.IndexBlockInterval) * time.Second
+	if rpcClient.CanSubscribe() {
+		m.logger.Info().Msg("Follow new heads over WebSocket subscription.")
+		c.FollowHead(interval, schedule.IndexBlockBatch)
+	} else {
+		c.Schedule("index_blocks", interval, "IndexBlock", "index_blocks", multiplex.ExecParams{
+			"from_block_number": new(big.Int),
+			"to_block_number":   new(big.Int),
+			"batch_size":        schedule.IndexBlockBatch,
+			"forced":            false,
+			"include_txs":       true,
+		})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		m.logger.Info().Msgf("Received signal %s. Waiting for running jobs to finish.", sig)
+		c.Stop()
+	}()
+	c.Run()
+	m.logger.Info().Msg("Service stopped.")
+	return nil
+}
+
+func (m *ServiceModule) logError(err error) {
+	if err != nil {
+		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
+	}
+}
+
+func ServiceCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "service",
+		Short: "Run in service mode to follow the head of the chain.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseServiceFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewServiceModule(c, "service")
+			m.logError(m.Run())
+		},
+	}
+	rootCmd.Flags().Int("batch", 0, "Number of blocks to index in one batch.")
+	rootCmd.Flags().Int64("interval", 0, "Interval in seconds between checking for new blocks. Used as fallback when RPC is a WebSocket URL.")
+	rootCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	rootCmd.Flags().String("rpc", "", "RPC URL. Use ws:// or wss:// to subscribe to new heads.")
+	rootCmd.Flags().Uint64("worker", 0, "Number of concurrent requests.")
+
+	return rootCmd
+}
+
+type ServiceFlags struct {
+	Configs map[string]interface{}
+}
+
+func ParseServiceFlags(cmd *cobra.Command) *ServiceFlags {
+	batch, _ := cmd.Flags().GetInt("batch")
+	interval, _ := cmd.Flags().GetInt64("interval")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	rpcUrl, _ := cmd.Flags().GetString("rpc")
+	worker, _ := cmd.Flags().GetUint64("worker")
+
+	configs := make(map[string]interface{})
+	if batch > 0 {
+		configs[config.ServiceScheduleIndexBlockBatchKey] = batch
+	}
+	if interval > 0 {
+		configs[config.ServiceScheduleIndexBlockIntervalKey] = interval
+	}
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+	if rpcUrl != "" {
+		configs[config.BlockchainRpcUrlKey] = rpcUrl
+	}
+	if worker > 0 {
+		configs[config.ServiceWorkerGetBlockKey] = worker
+	}
+
+	return &ServiceFlags{
+		Configs: configs,
+	}
+}
@@ -0,0 +1,403 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/svc"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Maximum number of missed block ranges printed per masternode. All ranges are included in JSON output.
+const missedRangePrintLimit = 5
+
+type StatsModule struct {
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
+}
+
+func NewStatsModule(c *Controller, cmdName string) *StatsModule {
+	return &StatsModule{
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("stats", cmdName),
+	}
+}
+
+func (m *StatsModule) Signing(fromEpoch, toEpoch uint64, asJson bool) error {
+	if toEpoch == 0 {
+		toEpoch = fromEpoch
+	}
+	if toEpoch < fromEpoch {
+		return errors.New("to epoch must not be lower than from epoch")
+	}
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_epoch": fromEpoch,
+		"to_epoch":   toEpoch,
+	}
+	go c.DispatchOnce("ReportSigning", "get_signing_report", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.SigningReportResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	if asJson {
+		return printJson(result.Epochs)
+	}
+	for _, epoch := range result.Epochs {
+		fmt.Printf("Epoch %d (block #%d to #%d): %d signed blocks.\n", epoch.Epoch, epoch.FromBlock, epoch.ToBlock, epoch.SignableBlockCount)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "MASTERNODE\tLISTED\tSIGNED\tMISSED\tRATE\tMISSED BLOCKS\n")
+		for _, masternode := range epoch.Masternodes {
+			missedBlocks := masternode.MissedBlocks
+			suffix := ""
+			if len(missedBlocks) > missedRangePrintLimit {
+				suffix = fmt.Sprintf(" and %d more", len(missedBlocks)-missedRangePrintLimit)
+				missedBlocks = missedBlocks[:missedRangePrintLimit]
+			}
+			fmt.Fprintf(w, "0x%s\t%t\t%d\t%d\t%.2f%%\t%s%s\n", masternode.Address, masternode.Listed,
+				masternode.SignedCount, masternode.MissedCount, masternode.Rate*100, db.FormatBlockRanges(missedBlocks), suffix)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+	return nil
+}
+
+func (m *StatsModule) Epochs(fromEpoch, toEpoch uint64, asJson bool) error {
+	if toEpoch == 0 {
+		toEpoch = fromEpoch
+	}
+	if toEpoch < fromEpoch {
+		return errors.New("to epoch must not be lower than from epoch")
+	}
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_epoch": fromEpoch,
+		"to_epoch":   toEpoch,
+	}
+	go c.DispatchOnce("ReadDatabase", "get_epochs_range", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.DbEpochsResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	views := NewEpochViews(result)
+	if asJson {
+		return printJson(views)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "EPOCH\tBLOCK\tTIME\tMASTERNODES\tJOINED\tLEFT\tPENALTIES\n")
+	for _, view := range views {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%s\t%s\t%s\n", view.Epoch, view.CheckpointBlock, view.Time, len(view.Masternodes),
+			formatAddresses(view.Joined), formatAddresses(view.Left), formatAddresses(view.Penalties))
+	}
+	w.Flush()
+	m.logger.Info().Msgf("%d epochs found.", len(views))
+	return nil
+}
+
+func (m *StatsModule) Validators(fromEpoch, toEpoch uint64, asJson bool) error {
+	if toEpoch == 0 {
+		toEpoch = fromEpoch
+	}
+	if toEpoch < fromEpoch {
+		return errors.New("to epoch must not be lower than from epoch")
+	}
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_epoch": fromEpoch,
+		"to_epoch":   toEpoch,
+	}
+	go c.DispatchOnce("ReadDatabase", "get_validator_stats_range", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.DbValidatorStatsResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	views := NewEpochValidatorViews(result.Data)
+	if asJson {
+		return printJson(views)
+	}
+	for _, view := range views {
+		fmt.Printf("Epoch %d (block #%d to #%d): %d missed slots.\n", view.Epoch, view.FromBlock, view.ToBlock, view.MissedSlots)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "VALIDATOR\tLISTED\tCREATED\tATTESTED\tEXPECTED\tMISSED\tRATE\n")
+		for _, validator := range view.Validators {
+			fmt.Fprintf(w, "0x%s\t%t\t%d\t%d\t%d\t%d\t%.2f%%\n", validator.Address, validator.Listed, validator.BlocksCreated,
+				validator.BlocksAttested, validator.ExpectedTurns, validator.MissedSlots, validator.Rate*100)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+	if len(views) == 0 {
+		m.logger.Info().Msg("No validator statistics found. Run index with --mode validators first.")
+	}
+	return nil
+}
+
+func (m *StatsModule) logError(err error) {
+	if err != nil {
+		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
+	}
+}
+
+func StatsCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Report statistics of indexed data.",
+	}
+
+	signingCmd := &cobra.Command{
+		Use:   "signing",
+		Short: "Report block signing rate of masternodes per epoch.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseStatsFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewStatsModule(c, "signing")
+			m.logError(m.Signing(flags.FromEpoch, flags.ToEpoch, flags.Json))
+		},
+	}
+	signingCmd.Flags().Uint64P("from", "f", 0, "Start epoch number.")
+	signingCmd.Flags().Bool("json", false, "Print report as JSON.")
+	signingCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	signingCmd.Flags().Uint64P("to", "t", 0, "To epoch number. Use 0 to report the start epoch only.")
+	rootCmd.AddCommand(signingCmd)
+
+	epochsCmd := &cobra.Command{
+		Use:   "epochs",
+		Short: "Show masternodes and penalties of epochs decoded from checkpoint blocks.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseStatsFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewStatsModule(c, "epochs")
+			m.logError(m.Epochs(flags.FromEpoch, flags.ToEpoch, flags.Json))
+		},
+	}
+	epochsCmd.Flags().Uint64P("from", "f", 0, "Start epoch number.")
+	epochsCmd.Flags().Bool("json", false, "Print epochs as JSON.")
+	epochsCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	epochsCmd.Flags().Uint64P("to", "t", 0, "To epoch number. Use 0 to show the start epoch only.")
+	rootCmd.AddCommand(epochsCmd)
+
+	validatorsCmd := &cobra.Command{
+		Use:   "validators",
+		Short: "Report blocks created, attested and missed by masternodes per epoch.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseStatsFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewStatsModule(c, "validators")
+			m.logError(m.Validators(flags.FromEpoch, flags.ToEpoch, flags.Json))
+		},
+	}
+	validatorsCmd.Flags().Uint64P("from", "f", 0, "Start epoch number.")
+	validatorsCmd.Flags().Bool("json", false, "Print report as JSON.")
+	validatorsCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	validatorsCmd.Flags().Uint64P("to", "t", 0, "To epoch number. Use 0 to report the start epoch only.")
+	rootCmd.AddCommand(validatorsCmd)
+
+	return rootCmd
+}
+
+type StatsFlags struct {
+	FromEpoch uint64
+	Json      bool
+	ToEpoch   uint64
+
+	Configs map[string]interface{}
+}
+
+func ParseStatsFlags(cmd *cobra.Command) *StatsFlags {
+	from, _ := cmd.Flags().GetUint64("from")
+	asJson, _ := cmd.Flags().GetBool("json")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	to, _ := cmd.Flags().GetUint64("to")
+
+	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+
+	return &StatsFlags{
+		FromEpoch: from,
+		Json:      asJson,
+		ToEpoch:   to,
+		Configs:   configs,
+	}
+}
+
+// EpochView is the printable form of an epoch. Joined and Left compare masternodes with the previous epoch
+// in the result and are empty for the first one.
+type EpochView struct {
+	Epoch           uint64             `json:"epoch"`
+	CheckpointBlock uint64             `json:"checkpoint_block"`
+	Time            string             `json:"time"`
+	Masternodes     []*EpochMasternode `json:"masternodes"`
+	Penalties       []string           `json:"penalties"`
+	Joined          []string           `json:"joined"`
+	Left            []string           `json:"left"`
+}
+
+type EpochMasternode struct {
+	Address string `json:"address"`
+	// Double validator before rotation. Empty if not assigned.
+	M2 string `json:"m2"`
+}
+
+func NewEpochViews(result *svc.DbEpochsResult) []*EpochView {
+	views := make([]*EpochView, len(result.Epochs))
+	viewMap := map[uint64]*EpochView{}
+	for i, epoch := range result.Epochs {
+		views[i] = &EpochView{
+			Epoch:           epoch.ID,
+			CheckpointBlock: epoch.CheckpointBlockID,
+			Time:            time.Unix(epoch.Timestamp, 0).UTC().Format(time.RFC3339),
+			Masternodes:     []*EpochMasternode{},
+			Penalties:       []string{},
+			Joined:          []string{},
+			Left:            []string{},
+		}
+		viewMap[epoch.ID] = views[i]
+	}
+	validators := map[uint64][]*db.EpochValidator{}
+	for _, validator := range result.Validators {
+		validators[validator.EpochID] = append(validators[validator.EpochID], validator)
+	}
+	for epochID, epochValidators := range validators {
+		view, ok := viewMap[epochID]
+		if !ok {
+			continue
+		}
+		for _, validator := range epochValidators {
+			masternode := &EpochMasternode{Address: "0x" + validator.Address}
+			if validator.M2Index.Present() && int(validator.M2Index.V()) < len(epochValidators) {
+				masternode.M2 = "0x" + epochValidators[validator.M2Index.V()].Address
+			}
+			view.Masternodes = append(view.Masternodes, masternode)
+		}
+	}
+	for _, penalty := range result.Penalties {
+		if view, ok := viewMap[penalty.EpochID]; ok {
+			view.Penalties = append(view.Penalties, "0x"+penalty.Address)
+		}
+	}
+	for i := 1; i < len(views); i++ {
+		previous := map[string]bool{}
+		for _, masternode := range views[i-1].Masternodes {
+			previous[masternode.Address] = true
+		}
+		for _, masternode := range views[i].Masternodes {
+			if !previous[masternode.Address] {
+				views[i].Joined = append(views[i].Joined, masternode.Address)
+			}
+			delete(previous, masternode.Address)
+		}
+		for _, masternode := range views[i-1].Masternodes {
+			if previous[masternode.Address] {
+				views[i].Left = append(views[i].Left, masternode.Address)
+			}
+		}
+	}
+	return views
+}
+
+func formatAddresses(addresses []string) string {
+	if len(addresses) == 0 {
+		return "-"
+	}
+	return strings.Join(addresses, ",")
+}
+
+// EpochValidatorView is the printable form of validator statistics of an epoch.
+// ToBlock is the last indexed block of the epoch.
+type EpochValidatorView struct {
+	Epoch       uint64           `json:"epoch"`
+	FromBlock   uint64           `json:"from_block"`
+	ToBlock     uint64           `json:"to_block"`
+	MissedSlots uint32           `json:"missed_slots"`
+	Validators  []*ValidatorView `json:"validators"`
+}
+
+type ValidatorView struct {
+	Address        string `json:"address"`
+	Listed         bool   `json:"listed"`
+	BlocksCreated  uint32 `json:"blocks_created"`
+	BlocksAttested uint32 `json:"blocks_attested"`
+	ExpectedTurns  uint32 `json:"expected_turns"`
+	MissedSlots    uint32 `json:"missed_slots"`
+	// Ratio of expected turns not missed. Zero if no turns are expected.
+	Rate float64 `json:"rate"`
+}
+
+// Group validator statistics ordered by epoch into one view per epoch. Listed masternodes come first.
+func NewEpochValidatorViews(stats []*db.ValidatorStat) []*EpochValidatorView {
+	views := []*EpochValidatorView{}
+	for _, stat := range stats {
+		if len(views) == 0 || views[len(views)-1].Epoch != stat.EpochID {
+			views = append(views, &EpochValidatorView{
+				Epoch:      stat.EpochID,
+				FromBlock:  stat.EpochID*svc.EpochLength + 1,
+				ToBlock:    stat.ToBlockID,
+				Validators: []*ValidatorView{},
+			})
+		}
+		view := views[len(views)-1]
+		validator := &ValidatorView{
+			Address:        stat.Address,
+			Listed:         stat.Listed,
+			BlocksCreated:  stat.BlocksCreated,
+			BlocksAttested: stat.BlocksAttested,
+			ExpectedTurns:  stat.ExpectedTurns,
+			MissedSlots:    stat.MissedSlots,
+		}
+		if stat.ExpectedTurns > 0 {
+			validator.Rate = float64(stat.ExpectedTurns-stat.MissedSlots) / float64(stat.ExpectedTurns)
+		}
+		view.MissedSlots += stat.MissedSlots
+		view.Validators = append(view.Validators, validator)
+	}
+	for _, view := range views {
+		slices.SortStableFunc(view.Validators, func(a, b *ValidatorView) int {
+			if a.Listed == b.Listed {
+				return 0
+			}
+			if a.Listed {
+				return -1
+			}
+			return 1
+		})
+	}
+	return views
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"math/big"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/svc"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+type VerifyModule struct {
+	controller *Controller
+	config     *config.RootConfig
+	logger     zerolog.Logger
+}
+
+func NewVerifyModule(c *Controller, cmdName string) *VerifyModule {
+	return &VerifyModule{
+		controller: c,
+		config:     c.Root,
+		logger:     c.CommandLogger("verify", cmdName),
+	}
+}
+
+func (m *VerifyModule) Verify(from, to *big.Int, batchSize int) error {
+	m.logger.Info().Msg("Start verifying stored blocks.")
+	dbClient, err := m.controller.DbClient()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Disconnect()
+	c := svc.NewController(m.config, dbClient, nil, config.NewZerologLogger(m.logger))
+	params := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"batch_size":        batchSize,
+	}
+	go c.DispatchOnce("VerifyBlock", "verify_blocks", params)
+	c.Run()
+	result := params.ReturnResult().(*svc.VerifyBlocksResult)
+	if result.Error != nil {
+		return result.Error
+	}
+	m.logger.Info().Msgf("Verified %d blocks from #%d to #%d.", result.CheckedCount, result.From.Uint64(), result.To.Uint64())
+	if result.IssueCount() == 0 {
+		m.logger.Info().Msg("No integrity issues.")
+	} else {
+		m.logger.Warn().Msgf("%d issues found. Broken parent link = %d. Invalid hash = %d. Invalid creator = %d. Transaction count mismatch = %d.",
+			result.IssueCount(), result.BrokenParentLinkCount, result.InvalidBlockHashCount, result.InvalidCreatorCount, result.TxCountMismatchCount)
+	}
+	if result.UnverifiableHashCount > 0 {
+		m.logger.Warn().Msgf("Hash of %d checkpoint blocks cannot be verified. Re-import them to store validators and penalties.", result.UnverifiableHashCount)
+	}
+	return nil
+}
+
+func (m *VerifyModule) logError(err error) {
+	if err != nil {
+		m.logger.Err(err).Msg("Unexpected error has occurred. Program will exit.")
+	}
+}
+
+func VerifyCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify chain integrity of blocks stored in database.",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := InitApp()
+			defer c.Close()
+			flags := ParseVerifyFlags(cmd)
+			c.ConfigFromCli(flags.Configs)
+			m := NewVerifyModule(c, "verify")
+			m.logError(m.Verify(flags.From, flags.To, flags.Batch))
+		},
+	}
+	rootCmd.Flags().Int("batch", 900, "Number of blocks to verify in one batch.")
+	rootCmd.Flags().Uint64P("from", "f", 0, "Start block number.")
+	rootCmd.Flags().String("pgsql", "", "Connection string to PostgreSQL.")
+	rootCmd.Flags().Uint64P("to", "t", 0, "To block number. Use 0 for the index checkpoint.")
+
+	return rootCmd
+}
+
+type VerifyFlags struct {
+	Batch int
+	From  *big.Int
+	To    *big.Int
+
+	Configs map[string]interface{}
+}
+
+func ParseVerifyFlags(cmd *cobra.Command) *VerifyFlags {
+	batch, _ := cmd.Flags().GetInt("batch")
+	from, _ := cmd.Flags().GetUint64("from")
+	pgsql, _ := cmd.Flags().GetString("pgsql")
+	to, _ := cmd.Flags().GetUint64("to")
+
+	configs := make(map[string]interface{})
+	if pgsql != "" {
+		configs[config.DatabasePostgreSQLKey] = pgsql
+	}
+
+	return &VerifyFlags{
+		Batch:   batch,
+		From:    new(big.Int).SetUint64(from),
+		To:      new(big.Int).SetUint64(to),
+		Configs: configs,
+	}
+}
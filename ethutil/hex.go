@@ -12,6 +12,15 @@ func BigIntToHex(i *big.Int) string {
 	return "0x" + i.Text(16)
 }
 
+// Parse hex string with or without 0x prefix. Return zero if the string is empty or invalid.
+func HexToBigInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return i
+}
+
 func BytesEqual(x, y []byte) bool {
 	if x == nil && y == nil {
 		return true
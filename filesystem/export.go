@@ -0,0 +1,206 @@
+package filesystem
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	ExportFormatCsv     = "csv"
+	ExportFormatJsonl   = "jsonl"
+	ExportFormatParquet = "parquet"
+)
+
+// Types of exported values. Values of a column must be int64, string, bool or nil respectively.
+const (
+	ColumnTypeInt64  = "INT64"
+	ColumnTypeString = "STRING"
+	ColumnTypeBool   = "BOOL"
+)
+
+type ExportColumn struct {
+	Name string
+	Type string
+}
+
+// RecordWriter writes rows of values in the order of its columns to a file.
+// File is only moved to its final path after Close succeeds. Abort discards the file.
+type RecordWriter interface {
+	Write(record []interface{}) error
+	Close() error
+	Abort()
+}
+
+func IsExportFormat(format string) bool {
+	return format == ExportFormatCsv || format == ExportFormatJsonl || format == ExportFormatParquet
+}
+
+func NewRecordWriter(format, filePath string, columns []*ExportColumn) (RecordWriter, error) {
+	if !IsExportFormat(format) {
+		return nil, fmt.Errorf("unsupported export format %s", format)
+	}
+	err := CreateDirectoryRecursive(filepath.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filePath + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	base := &fileRecordWriter{
+		file:     file,
+		buffer:   bufio.NewWriter(file),
+		filePath: filePath,
+	}
+	switch format {
+	case ExportFormatCsv:
+		w := &csvRecordWriter{fileRecordWriter: base, csv: csv.NewWriter(base.buffer)}
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		err = w.csv.Write(header)
+		if err != nil {
+			base.abort()
+			return nil, err
+		}
+		return w, nil
+	case ExportFormatJsonl:
+		return &jsonlRecordWriter{fileRecordWriter: base, columns: columns}, nil
+	}
+	metadata := make([]string, len(columns))
+	for i, column := range columns {
+		metadata[i] = parquetMetadata(column)
+	}
+	pw, err := writer.NewCSVWriterFromWriter(metadata, base.buffer, 1)
+	if err != nil {
+		base.abort()
+		return nil, err
+	}
+	return &parquetRecordWriter{fileRecordWriter: base, parquet: pw}, nil
+}
+
+type fileRecordWriter struct {
+	file     *os.File
+	buffer   *bufio.Writer
+	filePath string
+}
+
+func (w *fileRecordWriter) close() error {
+	err := w.buffer.Flush()
+	if err != nil {
+		w.abort()
+		return err
+	}
+	err = w.file.Close()
+	if err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	return os.Rename(w.file.Name(), w.filePath)
+}
+
+func (w *fileRecordWriter) Abort() {
+	w.abort()
+}
+
+func (w *fileRecordWriter) abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+type csvRecordWriter struct {
+	*fileRecordWriter
+	csv *csv.Writer
+}
+
+func (w *csvRecordWriter) Write(record []interface{}) error {
+	strs := make([]string, len(record))
+	for i, value := range record {
+		switch v := value.(type) {
+		case nil:
+			strs[i] = ""
+		case int64:
+			strs[i] = strconv.FormatInt(v, 10)
+		case bool:
+			strs[i] = strconv.FormatBool(v)
+		default:
+			strs[i] = fmt.Sprint(v)
+		}
+	}
+	return w.csv.Write(strs)
+}
+
+func (w *csvRecordWriter) Close() error {
+	w.csv.Flush()
+	err := w.csv.Error()
+	if err != nil {
+		w.abort()
+		return err
+	}
+	return w.close()
+}
+
+type jsonlRecordWriter struct {
+	*fileRecordWriter
+	columns []*ExportColumn
+}
+
+// Write a JSON object per line with keys in column order.
+func (w *jsonlRecordWriter) Write(record []interface{}) error {
+	w.buffer.WriteByte('{')
+	for i, column := range w.columns {
+		if i > 0 {
+			w.buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(column.Name)
+		value, err := json.Marshal(record[i])
+		if err != nil {
+			return err
+		}
+		w.buffer.Write(key)
+		w.buffer.WriteByte(':')
+		w.buffer.Write(value)
+	}
+	_, err := w.buffer.WriteString("}\n")
+	return err
+}
+
+func (w *jsonlRecordWriter) Close() error {
+	return w.close()
+}
+
+type parquetRecordWriter struct {
+	*fileRecordWriter
+	parquet *writer.CSVWriter
+}
+
+func (w *parquetRecordWriter) Write(record []interface{}) error {
+	return w.parquet.Write(record)
+}
+
+func (w *parquetRecordWriter) Close() error {
+	err := w.parquet.WriteStop()
+	if err != nil {
+		w.abort()
+		return err
+	}
+	return w.close()
+}
+
+func parquetMetadata(column *ExportColumn) string {
+	switch column.Type {
+	case ColumnTypeInt64:
+		return fmt.Sprintf("name=%s, type=INT64, repetitiontype=OPTIONAL", column.Name)
+	case ColumnTypeBool:
+		return fmt.Sprintf("name=%s, type=BOOLEAN, repetitiontype=OPTIONAL", column.Name)
+	}
+	return fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", column.Name)
+}
@@ -0,0 +1,120 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var testColumns = []*ExportColumn{
+	{Name: "id", Type: ColumnTypeInt64},
+	{Name: "hash", Type: ColumnTypeString},
+	{Name: "status", Type: ColumnTypeBool},
+}
+
+var testRecords = [][]interface{}{
+	{int64(1), "aa", true},
+	{int64(2), nil, nil},
+	{nil, "cc", false},
+}
+
+func TestRecordWriter(t *testing.T) {
+	t.Run(ExportFormatCsv, func(t *testing.T) {
+		data := writeTestRecords(t, ExportFormatCsv)
+		expected := "id,hash,status\n1,aa,true\n2,,\n,cc,false\n"
+		if string(data) != expected {
+			t.Fatalf("Content mismatch. Expected '%s' Actual '%s'", expected, data)
+		}
+	})
+	t.Run(ExportFormatJsonl, func(t *testing.T) {
+		data := writeTestRecords(t, ExportFormatJsonl)
+		expected := `{"id":1,"hash":"aa","status":true}` + "\n" +
+			`{"id":2,"hash":null,"status":null}` + "\n" +
+			`{"id":null,"hash":"cc","status":false}` + "\n"
+		if string(data) != expected {
+			t.Fatalf("Content mismatch. Expected '%s' Actual '%s'", expected, data)
+		}
+	})
+	t.Run(ExportFormatParquet, func(t *testing.T) {
+		data := writeTestRecords(t, ExportFormatParquet)
+		file, err := buffer.NewBufferFile(data)
+		if err != nil {
+			t.Fatalf("Error while opening parquet file. %v", err)
+		}
+		pr, err := reader.NewParquetReader(file, nil, 1)
+		if err != nil {
+			t.Fatalf("Error while reading parquet file. %v", err)
+		}
+		defer pr.ReadStop()
+		rows, err := pr.ReadByNumber(int(pr.GetNumRows()))
+		if err != nil {
+			t.Fatalf("Error while reading parquet rows. %v", err)
+		}
+		actual, _ := json.Marshal(rows)
+		expected := `[{"Id":1,"Hash":"aa","Status":true},{"Id":2,"Hash":null,"Status":null},{"Id":null,"Hash":"cc","Status":false}]`
+		if string(actual) != expected {
+			t.Fatalf("Content mismatch. Expected '%s' Actual '%s'", expected, actual)
+		}
+	})
+}
+
+func TestRecordWriterAbort(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "blocks.csv")
+	w, err := NewRecordWriter(ExportFormatCsv, filePath, testColumns)
+	if err != nil {
+		t.Fatalf("Error while creating writer. %v", err)
+	}
+	if _, err := os.Stat(filePath + ".tmp"); err != nil {
+		t.Fatalf("Records must be written to temporary file. %v", err)
+	}
+	w.Write(testRecords[0])
+	w.Abort()
+	assertNoFiles(t, filepath.Dir(filePath))
+}
+
+// Write test records and return content of the final file. Temporary file must be gone after Close.
+func writeTestRecords(t *testing.T, format string) []byte {
+	filePath := filepath.Join(t.TempDir(), "export", "blocks."+format)
+	w, err := NewRecordWriter(format, filePath, testColumns)
+	if err != nil {
+		t.Fatalf("Error while creating writer. %v", err)
+	}
+	for _, record := range testRecords {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Error while writing record. %v", err)
+		}
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Fatalf("Final file must not exist before Close. %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error while closing writer. %v", err)
+	}
+	if _, err := os.Stat(filePath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Temporary file must be renamed on Close. %v", err)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Error while reading exported file. %v", err)
+	}
+	return data
+}
+
+func assertNoFiles(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Error while listing directory. %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) > 0 {
+		t.Fatalf("Aborted writer must leave no file. Found %s", strings.Join(names, ", "))
+	}
+}
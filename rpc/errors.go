@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type ErrorClass string
+
+const (
+	ErrorClassNone              ErrorClass = ""
+	ErrorClassRateLimited       ErrorClass = "RATE_LIMITED"
+	ErrorClassTimeout           ErrorClass = "TIMEOUT"
+	ErrorClassJsonRpc           ErrorClass = "JSON_RPC"
+	ErrorClassNotFound          ErrorClass = "NOT_FOUND"
+	ErrorClassDecode            ErrorClass = "DECODE"
+	ErrorClassConnectionRefused ErrorClass = "CONNECTION_REFUSED"
+	ErrorClassUnknown           ErrorClass = "UNKNOWN"
+)
+
+// Return true if the error is caused by the endpoint rather than the request,
+// so the same request may succeed later or on another endpoint.
+func (c ErrorClass) IsTransient() bool {
+	switch c {
+	case ErrorClassRateLimited, ErrorClassTimeout, ErrorClassConnectionRefused, ErrorClassUnknown:
+		return true
+	}
+	return false
+}
+
+var ErrBlockNotFound = &RpcError{Class: ErrorClassNotFound, Err: errors.New("block not found")}
+
+var ErrReceiptNotFound = &RpcError{Class: ErrorClassNotFound, Err: errors.New("receipt not found")}
+
+// RpcError wraps a failed RPC call with its class. Code is the HTTP status or JSON-RPC error code if available.
+type RpcError struct {
+	Class ErrorClass
+	Code  int
+	Err   error
+}
+
+func (e *RpcError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RpcError) Unwrap() error {
+	return e.Err
+}
+
+// Return class of the error. Errors not returned by this package are classified on the fly.
+func ErrorClassOf(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+	var rpcErr *RpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Class
+	}
+	return classifyError(err).(*RpcError).Class
+}
+
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var rpcErr *RpcError
+	if errors.As(err, &rpcErr) {
+		return err
+	}
+	rpcErr = &RpcError{Class: ErrorClassUnknown, Err: err}
+	var httpErr rpc.HTTPError
+	var jsonErr rpc.Error
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &httpErr):
+		rpcErr.Code = httpErr.StatusCode
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			rpcErr.Class = ErrorClassRateLimited
+		case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+			rpcErr.Class = ErrorClassTimeout
+		}
+	case errors.As(err, &jsonErr):
+		rpcErr.Code = jsonErr.ErrorCode()
+		rpcErr.Class = classifyJsonRpcError(jsonErr)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		rpcErr.Class = ErrorClassConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		rpcErr.Class = ErrorClassTimeout
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, rpc.ErrNoResult):
+		rpcErr.Class = ErrorClassDecode
+	}
+	return rpcErr
+}
+
+func decodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RpcError{Class: ErrorClassDecode, Err: err}
+}
+
+// Classify by error code first. Only server-defined codes fall back to matching the message,
+// so a missing method or an invalid parameter is never mistaken for missing data.
+func classifyJsonRpcError(err rpc.Error) ErrorClass {
+	switch err.ErrorCode() {
+	case -32005:
+		return ErrorClassRateLimited
+	case -32700, -32600, -32601, -32602:
+		return ErrorClassJsonRpc
+	}
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "rate limit"), strings.Contains(message, "too many requests"):
+		return ErrorClassRateLimited
+	case strings.Contains(message, "not found"):
+		return ErrorClassNotFound
+	case strings.Contains(message, "timeout"), strings.Contains(message, "deadline exceeded"):
+		return ErrorClassTimeout
+	}
+	return ErrorClassJsonRpc
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+)
+
+func TestErrorClassOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		class  ErrorClass
+	}{
+		{"rate_limited", http.StatusTooManyRequests, "", ErrorClassRateLimited},
+		{"service_unavailable", http.StatusServiceUnavailable, "<html><body><h1>503 Service Unavailable</h1></body></html>", ErrorClassRateLimited},
+		{"gateway_timeout", http.StatusGatewayTimeout, "", ErrorClassTimeout},
+		{"json_rpc", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument 0"}}`, ErrorClassJsonRpc},
+		{"method_not_found", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`, ErrorClassJsonRpc},
+		{"invalid_params_not_found", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"block not found in params"}}`, ErrorClassJsonRpc},
+		{"json_rpc_not_found", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`, ErrorClassNotFound},
+		{"null_result", http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`, ErrorClassNotFound},
+		{"decode", http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":{"number":1}}`, ErrorClassDecode},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestRpcServer(test.status, test.body)
+			defer server.Close()
+			client, err := Connect(server.URL)
+			if err != nil {
+				t.Fatalf("Error while connecting. %v", err)
+			}
+			_, _, err = client.GetBlockByNumber2(big.NewInt(1))
+			class := ErrorClassOf(err)
+			if class != test.class {
+				t.Fatalf("Class mismatch. Expected '%s' Actual '%s'. %v", test.class, class, err)
+			}
+		})
+	}
+}
+
+func TestErrorClassOfConnectionRefused(t *testing.T) {
+	server := newTestRpcServer(http.StatusOK, "")
+	url := server.URL
+	server.Close()
+	client, err := Connect(url)
+	if err != nil {
+		t.Fatalf("Error while connecting. %v", err)
+	}
+	_, err = client.GetBlockNumber()
+	class := ErrorClassOf(err)
+	if class != ErrorClassConnectionRefused {
+		t.Fatalf("Class mismatch. Expected '%s' Actual '%s'. %v", ErrorClassConnectionRefused, class, err)
+	}
+}
@@ -2,7 +2,9 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -12,9 +14,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+var ErrSubscriptionNotSupported = errors.New("subscription requires a websocket rpc url")
+
 type EthClient struct {
-	e *ethclient.Client
-	r *rpc.Client
+	e   *ethclient.Client
+	r   *rpc.Client
+	url string
 }
 
 func Connect(rpcUrl string) (*EthClient, error) {
@@ -24,11 +29,36 @@ func Connect(rpcUrl string) (*EthClient, error) {
 	}
 	e := ethclient.NewClient(r)
 
-	return &EthClient{e, r}, nil
+	return &EthClient{e, r, rpcUrl}, nil
 }
 
 func (client *EthClient) GetBlockNumber() (uint64, error) {
-	return client.e.BlockNumber(context.TODO())
+	return client.GetBlockNumberContext(context.TODO())
+}
+
+// Same as GetBlockNumber but the request is aborted when ctx is done.
+func (client *EthClient) GetBlockNumberContext(ctx context.Context) (uint64, error) {
+	number, err := client.e.BlockNumber(ctx)
+	return number, classifyError(err)
+}
+
+// Close the underlying connection. Pending requests fail and subscriptions end.
+func (client *EthClient) Close() {
+	client.r.Close()
+}
+
+// Return true if the client is connected over WebSocket and can receive notifications.
+func (client *EthClient) CanSubscribe() bool {
+	return IsWebSocketUrl(client.url)
+}
+
+// Subscribe to headers of new blocks using eth_subscribe("newHeads").
+// Headers are delivered as blocks without transactions.
+func (client *EthClient) SubscribeNewHeads(ch chan<- *Block) (*rpc.ClientSubscription, error) {
+	if !client.CanSubscribe() {
+		return nil, ErrSubscriptionNotSupported
+	}
+	return client.r.EthSubscribe(context.TODO(), ch, "newHeads")
 }
 
 func (client *EthClient) GetBlockByNumber(number *big.Int) (*types.Block, error) {
@@ -51,3 +81,8 @@ func (client *EthClient) StaticCall(to *common.Address, data []byte, gasLimit ui
 	}
 	return client.e.CallContract(context.Background(), msgData, nil)
 }
+
+func IsWebSocketUrl(rpcUrl string) bool {
+	rpcUrl = strings.ToLower(rpcUrl)
+	return strings.HasPrefix(rpcUrl, "ws://") || strings.HasPrefix(rpcUrl, "wss://")
+}
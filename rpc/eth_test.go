@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testNewHeadsApi struct {
+	count int
+}
+
+func (a *testNewHeadsApi) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	go func() {
+		for i := 1; i <= a.count; i++ {
+			notifier.Notify(sub.ID, map[string]string{"number": fmt.Sprintf("0x%x", i), "hash": "0x01"})
+		}
+	}()
+	return sub, nil
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	server := rpc.NewServer()
+	server.RegisterName("eth", &testNewHeadsApi{count: 3})
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpServer.Close()
+
+	client, err := Connect("ws" + strings.TrimPrefix(httpServer.URL, "http"))
+	if err != nil {
+		t.Fatalf("Error while connecting. %v", err)
+	}
+	heads := make(chan *Block, 8)
+	sub, err := client.SubscribeNewHeads(heads)
+	if err != nil {
+		t.Fatalf("Error while subscribing. %v", err)
+	}
+	for number := uint64(1); number <= 3; number++ {
+		head := <-heads
+		if head.Number.Int() != number {
+			t.Fatalf("Head number mismatch. Expected '%d' Actual '%d'", number, head.Number.Int())
+		}
+	}
+	server.Stop()
+	select {
+	case <-sub.Err():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Dropped subscription must be reported.")
+	}
+}
+
+func TestSubscribeNewHeadsHttp(t *testing.T) {
+	server := newTestRpcServer(http.StatusOK, "")
+	defer server.Close()
+	client, err := Connect(server.URL)
+	if err != nil {
+		t.Fatalf("Error while connecting. %v", err)
+	}
+	_, err = client.SubscribeNewHeads(make(chan *Block))
+	if err != ErrSubscriptionNotSupported {
+		t.Fatalf("Error mismatch. Expected '%v' Actual '%v'", ErrSubscriptionNotSupported, err)
+	}
+}
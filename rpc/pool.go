@@ -0,0 +1,341 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+	"viction-rpc-crawler-go/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/tforce-io/tf-golib/random/securerng"
+)
+
+// Number of recent blocks a non-archive node is expected to keep state for.
+const RecentStateBlocks = 128
+
+var ErrNoEndpoint = errors.New("no rpc endpoint available")
+
+type poolEndpoint struct {
+	client *EthClient
+	config *config.RpcEndpointConfig
+
+	failures     int
+	ejectedUntil time.Time
+	head         uint64
+}
+
+func (e *poolEndpoint) weight() uint64 {
+	if e.config.Weight <= 0 {
+		return 1
+	}
+	return uint64(e.config.Weight)
+}
+
+// EthPool routes requests to multiple RPC endpoints based on their health and capabilities.
+type EthPool struct {
+	endpoints []*poolEndpoint
+	options   *config.RpcPoolConfig
+	head      uint64
+	lock      sync.Mutex
+	exit      chan bool
+	closeOnce sync.Once
+}
+
+// Create a pool connecting to all endpoints. Nil options fall back to the default pool configuration.
+func NewEthPool(endpoints config.RpcEndpoints, options *config.RpcPoolConfig) (*EthPool, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if options == nil {
+		options = config.DefaultRootConfig().Blockchain.RpcPool
+	}
+	pool := &EthPool{
+		endpoints: make([]*poolEndpoint, len(endpoints)),
+		options:   options,
+		exit:      make(chan bool),
+	}
+	for i, endpoint := range endpoints {
+		client, err := Connect(endpoint.Url)
+		if err != nil {
+			for _, connected := range pool.endpoints[:i] {
+				connected.client.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", endpoint.Url, err)
+		}
+		pool.endpoints[i] = &poolEndpoint{
+			client: client,
+			config: endpoint,
+		}
+	}
+	if len(pool.endpoints) > 1 && options.HealthCheckInterval > 0 {
+		go pool.healthCheck()
+	}
+	return pool, nil
+}
+
+// Stop health checks and close connections of all endpoints. Calling Close more than once has no effect.
+func (p *EthPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.exit)
+		for _, endpoint := range p.endpoints {
+			endpoint.client.Close()
+		}
+	})
+}
+
+func (p *EthPool) GetBlockNumber() (uint64, error) {
+	endpoint, err := p.pick(false, nil)
+	if err != nil {
+		return 0, err
+	}
+	head, err := endpoint.client.GetBlockNumber()
+	p.report(endpoint, err)
+	if err == nil {
+		p.updateHead(endpoint, head)
+	}
+	return head, err
+}
+
+func (p *EthPool) GetBlockByNumber2(number *big.Int) (*Block, string, error) {
+	endpoint, err := p.pick(false, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	block, str, err := endpoint.client.GetBlockByNumber2(number)
+	p.report(endpoint, err)
+	return block, str, err
+}
+
+func (p *EthPool) TraceBlockByNumber(number *big.Int) (TraceBlockResult, string, error) {
+	endpoint, err := p.pick(true, number)
+	if err != nil {
+		return TraceBlockResult{}, "", err
+	}
+	result, str, err := endpoint.client.TraceBlockByNumber(number)
+	p.report(endpoint, err)
+	return result, str, err
+}
+
+func (p *EthPool) GetBlocksByNumber2(numbers []*big.Int) ([]*Block, []string, []error, error) {
+	endpoint, err := p.pick(false, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	blocks, strs, errs, err := endpoint.client.GetBlocksByNumber2(numbers)
+	p.report(endpoint, err)
+	return blocks, strs, errs, err
+}
+
+func (p *EthPool) GetTransactionReceipts(txHashes []string) ([]*Receipt, []string, []error, error) {
+	endpoint, err := p.pick(false, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	receipts, strs, errs, err := endpoint.client.GetTransactionReceipts(txHashes)
+	p.report(endpoint, err)
+	return receipts, strs, errs, err
+}
+
+func (p *EthPool) StaticCall(to *common.Address, data []byte, gasLimit uint64, from common.Address) ([]byte, error) {
+	endpoint, err := p.pick(false, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := endpoint.client.StaticCall(to, data, gasLimit, from)
+	err = classifyError(err)
+	p.report(endpoint, err)
+	return result, err
+}
+
+func (p *EthPool) TraceBlocksByNumber(numbers []*big.Int) ([]TraceBlockResult, []string, []error, error) {
+	endpoint, err := p.pick(true, numbers[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	results, strs, errs, err := endpoint.client.TraceBlocksByNumber(numbers)
+	p.report(endpoint, err)
+	return results, strs, errs, err
+}
+
+// Return true if at least one endpoint is connected over WebSocket.
+func (p *EthPool) CanSubscribe() bool {
+	for _, endpoint := range p.endpoints {
+		if endpoint.client.CanSubscribe() {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe to new heads on the first healthy WebSocket endpoint.
+// Ejected endpoints are only tried when no healthy one is left.
+func (p *EthPool) SubscribeNewHeads(ch chan<- *Block) (*rpc.ClientSubscription, error) {
+	p.lock.Lock()
+	now := time.Now()
+	candidates := []*poolEndpoint{}
+	ejectedCandidates := []*poolEndpoint{}
+	for _, endpoint := range p.endpoints {
+		if !endpoint.client.CanSubscribe() {
+			continue
+		}
+		if endpoint.ejectedUntil.After(now) {
+			ejectedCandidates = append(ejectedCandidates, endpoint)
+			continue
+		}
+		candidates = append(candidates, endpoint)
+	}
+	p.lock.Unlock()
+	candidates = append(candidates, ejectedCandidates...)
+	if len(candidates) == 0 {
+		return nil, ErrSubscriptionNotSupported
+	}
+	var err error
+	for _, endpoint := range candidates {
+		var sub *rpc.ClientSubscription
+		sub, err = endpoint.client.SubscribeNewHeads(ch)
+		p.report(endpoint, err)
+		if err == nil {
+			return sub, nil
+		}
+	}
+	return nil, err
+}
+
+// Select an endpoint by weight among healthy endpoints having required capabilities.
+// Blocks older than RecentStateBlocks prefer archive endpoints. If all candidates are ejected,
+// the one ejected earliest is probed.
+func (p *EthPool) pick(debug bool, blockNumber *big.Int) (*poolEndpoint, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	candidates := []*poolEndpoint{}
+	for _, endpoint := range p.endpoints {
+		if debug && !endpoint.config.Debug {
+			continue
+		}
+		candidates = append(candidates, endpoint)
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if blockNumber != nil && p.head > RecentStateBlocks && blockNumber.Uint64() < p.head-RecentStateBlocks {
+		archiveCandidates := []*poolEndpoint{}
+		for _, endpoint := range candidates {
+			if endpoint.config.Archive {
+				archiveCandidates = append(archiveCandidates, endpoint)
+			}
+		}
+		if len(archiveCandidates) > 0 {
+			candidates = archiveCandidates
+		}
+	}
+	now := time.Now()
+	healthyCandidates := []*poolEndpoint{}
+	totalWeight := uint64(0)
+	for _, endpoint := range candidates {
+		if endpoint.ejectedUntil.After(now) {
+			continue
+		}
+		healthyCandidates = append(healthyCandidates, endpoint)
+		totalWeight += endpoint.weight()
+	}
+	if len(healthyCandidates) == 0 {
+		probe := candidates[0]
+		for _, endpoint := range candidates {
+			if endpoint.ejectedUntil.Before(probe.ejectedUntil) {
+				probe = endpoint
+			}
+		}
+		return probe, nil
+	}
+	selected := securerng.Uint64r(0, totalWeight)
+	for _, endpoint := range healthyCandidates {
+		if selected < endpoint.weight() {
+			return endpoint, nil
+		}
+		selected -= endpoint.weight()
+	}
+	return healthyCandidates[len(healthyCandidates)-1], nil
+}
+
+// Track consecutive failures of the endpoint. Errors caused by the request itself,
+// such as a block not found, do not count against the endpoint.
+func (p *EthPool) report(endpoint *poolEndpoint, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err == nil || !ErrorClassOf(err).IsTransient() {
+		endpoint.failures = 0
+		return
+	}
+	endpoint.failures++
+	if endpoint.failures >= p.options.MaxFailures {
+		p.eject(endpoint)
+	}
+}
+
+func (p *EthPool) updateHead(endpoint *poolEndpoint, head uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	endpoint.head = head
+	if head > p.head {
+		p.head = head
+	}
+}
+
+func (p *EthPool) eject(endpoint *poolEndpoint) {
+	endpoint.ejectedUntil = time.Now().Add(time.Duration(p.options.EjectDuration) * time.Second)
+}
+
+// Periodically probe all endpoints for their head. Each probe must finish before the next tick.
+func (p *EthPool) healthCheck() {
+	interval := time.Duration(p.options.HealthCheckInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.exit:
+			return
+		case <-ticker.C:
+		}
+		p.probe(interval)
+	}
+}
+
+// Query head of all endpoints concurrently, each within timeout. Endpoints that fail or lag behind are ejected,
+// the others are put back into rotation.
+func (p *EthPool) probe(timeout time.Duration) {
+	heads := make([]uint64, len(p.endpoints))
+	errs := make([]error, len(p.endpoints))
+	wg := &sync.WaitGroup{}
+	for i, endpoint := range p.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *poolEndpoint) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			heads[i], errs[i] = endpoint.client.GetBlockNumberContext(ctx)
+		}(i, endpoint)
+	}
+	wg.Wait()
+	p.lock.Lock()
+	for i, endpoint := range p.endpoints {
+		if errs[i] == nil {
+			endpoint.head = heads[i]
+			if heads[i] > p.head {
+				p.head = heads[i]
+			}
+		}
+	}
+	for i, endpoint := range p.endpoints {
+		if errs[i] != nil || endpoint.head+p.options.MaxBlockLag < p.head {
+			p.eject(endpoint)
+			continue
+		}
+		endpoint.failures = 0
+		endpoint.ejectedUntil = time.Time{}
+	}
+	p.lock.Unlock()
+}
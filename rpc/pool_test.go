@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"viction-rpc-crawler-go/config"
+)
+
+func TestEthPoolRouting(t *testing.T) {
+	okServer := newTestRpcServer(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`)
+	defer okServer.Close()
+	failServer := newTestRpcServer(http.StatusServiceUnavailable, "")
+	defer failServer.Close()
+
+	t.Run("debug_only", func(t *testing.T) {
+		pool := newTestEthPool(t, config.RpcEndpoints{
+			{Url: failServer.URL, Weight: 100},
+			{Url: okServer.URL, Weight: 1, Debug: true},
+		})
+		defer pool.Close()
+		for i := 0; i < 10; i++ {
+			_, _, err := pool.TraceBlockByNumber(big.NewInt(1))
+			if err != nil {
+				t.Fatalf("Trace must be routed to debug endpoint. %v", err)
+			}
+		}
+	})
+	t.Run("no_debug", func(t *testing.T) {
+		pool := newTestEthPool(t, config.RpcEndpoints{
+			{Url: okServer.URL},
+		})
+		defer pool.Close()
+		_, _, err := pool.TraceBlockByNumber(big.NewInt(1))
+		if err != ErrNoEndpoint {
+			t.Fatalf("Error mismatch. Expected '%v' Actual '%v'", ErrNoEndpoint, err)
+		}
+	})
+	t.Run("eject", func(t *testing.T) {
+		pool := newTestEthPool(t, config.RpcEndpoints{
+			{Url: failServer.URL, Weight: 1},
+			{Url: okServer.URL, Weight: 1},
+		})
+		defer pool.Close()
+		for i := 0; i < 20; i++ {
+			pool.GetBlockByNumber2(big.NewInt(1))
+		}
+		if !pool.endpoints[0].ejectedUntil.After(pool.endpoints[1].ejectedUntil) {
+			t.Fatalf("Failing endpoint must be ejected.")
+		}
+		for i := 0; i < 10; i++ {
+			endpoint, _ := pool.pick(false, nil)
+			if endpoint != pool.endpoints[1] {
+				t.Fatalf("Ejected endpoint must not be selected.")
+			}
+		}
+	})
+}
+
+func TestEthPoolProbe(t *testing.T) {
+	headServer := newTestRpcServer(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x64"}`)
+	defer headServer.Close()
+	release := make(chan bool)
+	hangServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hangServer.Close()
+	defer close(release)
+
+	pool := newTestEthPool(t, config.RpcEndpoints{
+		{Url: hangServer.URL},
+		{Url: hangServer.URL},
+		{Url: headServer.URL},
+	})
+	defer pool.Close()
+	start := time.Now()
+	pool.probe(200 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Endpoints must be probed concurrently within timeout. Elapsed %v", elapsed)
+	}
+	if !pool.endpoints[0].ejectedUntil.After(time.Now()) || !pool.endpoints[1].ejectedUntil.After(time.Now()) {
+		t.Fatalf("Endpoints timing out must be ejected.")
+	}
+	if pool.endpoints[2].ejectedUntil.After(time.Now()) || pool.endpoints[2].head != 100 {
+		t.Fatalf("Healthy endpoint mismatch. Head %d", pool.endpoints[2].head)
+	}
+}
+
+func TestNewEthPoolDefaultOptions(t *testing.T) {
+	server := newTestRpcServer(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`)
+	defer server.Close()
+	pool, err := NewEthPool(config.RpcEndpoints{{Url: server.URL}}, nil)
+	if err != nil {
+		t.Fatalf("Error while creating pool. %v", err)
+	}
+	defer pool.Close()
+	if pool.options == nil || pool.options.MaxFailures == 0 {
+		t.Fatalf("Default options must be used.")
+	}
+}
+
+func TestEthPoolClose(t *testing.T) {
+	server := newTestRpcServer(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+	defer server.Close()
+	pool := newTestEthPool(t, config.RpcEndpoints{{Url: server.URL}})
+	pool.Close()
+	pool.Close()
+	select {
+	case <-pool.exit:
+	default:
+		t.Fatalf("Health check must be stopped after Close.")
+	}
+}
+
+func newTestEthPool(t *testing.T, endpoints config.RpcEndpoints) *EthPool {
+	pool, err := NewEthPool(endpoints, &config.RpcPoolConfig{
+		MaxBlockLag:   30,
+		MaxFailures:   2,
+		EjectDuration: 60,
+	})
+	if err != nil {
+		t.Fatalf("Error while creating pool. %v", err)
+	}
+	return pool
+}
+
+func newTestRpcServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
@@ -5,13 +5,62 @@ import (
 	"encoding/json"
 	"math/big"
 	"viction-rpc-crawler-go/ethutil"
+
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func (client *EthClient) GetBlockByNumber2(number *big.Int) (*Block, string, error) {
 	fn, str, err := rpcCall[Block](client, "eth_getBlockByNumber", ethutil.BigIntToHex(number), true)
+	if err == nil && fn == nil {
+		err = ErrBlockNotFound
+	}
 	return fn, str, err
 }
 
+// Fetch multiple blocks in one JSON-RPC batch request. Item errors are returned separately from transport error.
+func (client *EthClient) GetBlocksByNumber2(numbers []*big.Int) ([]*Block, []string, []error, error) {
+	args := make([][]interface{}, len(numbers))
+	for i, number := range numbers {
+		args[i] = []interface{}{ethutil.BigIntToHex(number), true}
+	}
+	blocks, strs, errs, err := rpcBatchCall[Block](client, "eth_getBlockByNumber", args)
+	if err != nil {
+		return blocks, strs, errs, err
+	}
+	for i := range blocks {
+		if errs[i] == nil && blocks[i] == nil {
+			errs[i] = ErrBlockNotFound
+		}
+	}
+	return blocks, strs, errs, nil
+}
+
+func (client *EthClient) GetTransactionReceipt(txHash string) (*Receipt, string, error) {
+	receipt, str, err := rpcCall[Receipt](client, "eth_getTransactionReceipt", txHash)
+	if err == nil && receipt == nil {
+		err = ErrReceiptNotFound
+	}
+	return receipt, str, err
+}
+
+// Fetch receipts of multiple transactions in one JSON-RPC batch request. Item errors are returned separately from transport error.
+func (client *EthClient) GetTransactionReceipts(txHashes []string) ([]*Receipt, []string, []error, error) {
+	args := make([][]interface{}, len(txHashes))
+	for i, txHash := range txHashes {
+		args[i] = []interface{}{txHash}
+	}
+	receipts, strs, errs, err := rpcBatchCall[Receipt](client, "eth_getTransactionReceipt", args)
+	if err != nil {
+		return receipts, strs, errs, err
+	}
+	for i := range receipts {
+		if errs[i] == nil && receipts[i] == nil {
+			errs[i] = ErrReceiptNotFound
+		}
+	}
+	return receipts, strs, errs, nil
+}
+
 func (client *EthClient) GetBlockFinalityByNumber(number *big.Int) (*uint, string, error) {
 	fn, str, err := rpcCall[uint](client, "eth_getBlockFinalityByNumber", ethutil.BigIntToHex(number))
 	return fn, str, err
@@ -29,11 +78,33 @@ func (client *EthClient) TraceBlockByNumber(number *big.Int) (TraceBlockResult,
 	if err != nil {
 		return TraceBlockResult{}, str, err
 	}
-	result := make(TraceBlockResult, len(*tempResult))
-	for i, r := range *tempResult {
-		result[i] = r.Result
+	return newTraceBlockResult(tempResult), str, err
+}
+
+// Trace multiple blocks in one JSON-RPC batch request. Item errors are returned separately from transport error.
+func (client *EthClient) TraceBlocksByNumber(numbers []*big.Int) ([]TraceBlockResult, []string, []error, error) {
+	tracerConfig := struct {
+		Tracer  string `json:"tracer"`
+		Timeout string `json:"timeout"`
+	}{
+		Tracer:  "callTracer",
+		Timeout: "300s",
 	}
-	return result, str, err
+	args := make([][]interface{}, len(numbers))
+	for i, number := range numbers {
+		args[i] = []interface{}{ethutil.BigIntToHex(number), tracerConfig}
+	}
+	tempResults, strs, errs, err := rpcBatchCall[[]TxTraceResult](client, "debug_traceBlockByNumber", args)
+	if err != nil {
+		return nil, strs, errs, err
+	}
+	results := make([]TraceBlockResult, len(tempResults))
+	for i, tempResult := range tempResults {
+		if errs[i] == nil {
+			results[i] = newTraceBlockResult(tempResult)
+		}
+	}
+	return results, strs, errs, err
 }
 
 func (client *EthClient) TraceTransaction(txHash string) (*TraceTransactionResult, string, error) {
@@ -48,12 +119,70 @@ func (client *EthClient) TraceTransaction(txHash string) (*TraceTransactionResul
 	return result, str, err
 }
 
+// Decode raw response of eth_getBlockByNumber. Null response returns nil block.
+func DecodeBlock(raw []byte) (*Block, error) {
+	var result *Block
+	err := json.Unmarshal(raw, &result)
+	return result, err
+}
+
+// Decode raw response of debug_traceBlockByNumber.
+func DecodeTraceBlock(raw []byte) (TraceBlockResult, error) {
+	var tempResult *[]TxTraceResult
+	err := json.Unmarshal(raw, &tempResult)
+	if err != nil {
+		return TraceBlockResult{}, err
+	}
+	return newTraceBlockResult(tempResult), nil
+}
+
+func newTraceBlockResult(txResults *[]TxTraceResult) TraceBlockResult {
+	if txResults == nil {
+		return TraceBlockResult{}
+	}
+	result := make(TraceBlockResult, len(*txResults))
+	for i, r := range *txResults {
+		result[i] = r.Result
+	}
+	return result
+}
+
 func rpcCall[T interface{}](client *EthClient, method string, args ...interface{}) (*T, string, error) {
 	var raw json.RawMessage
 	err := client.r.CallContext(context.Background(), &raw, method, args...)
 	var result *T
-	if err == nil && raw != nil {
-		err = json.Unmarshal([]byte(raw), &result)
+	if err != nil {
+		return result, string(raw), classifyError(err)
+	}
+	if raw != nil {
+		err = decodeError(json.Unmarshal([]byte(raw), &result))
 	}
 	return result, string(raw), err
 }
+
+func rpcBatchCall[T interface{}](client *EthClient, method string, args [][]interface{}) ([]*T, []string, []error, error) {
+	raws := make([]json.RawMessage, len(args))
+	batch := make([]rpc.BatchElem, len(args))
+	for i := range args {
+		batch[i] = rpc.BatchElem{
+			Method: method,
+			Args:   args[i],
+			Result: &raws[i],
+		}
+	}
+	results := make([]*T, len(args))
+	strs := make([]string, len(args))
+	errs := make([]error, len(args))
+	err := client.r.BatchCallContext(context.Background(), batch)
+	if err != nil {
+		return results, strs, errs, classifyError(err)
+	}
+	for i := range batch {
+		errs[i] = classifyError(batch[i].Error)
+		if errs[i] == nil && raws[i] != nil {
+			errs[i] = decodeError(json.Unmarshal([]byte(raws[i]), &results[i]))
+		}
+		strs[i] = string(raws[i])
+	}
+	return results, strs, errs, nil
+}
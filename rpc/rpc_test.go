@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetBlocksByNumber2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var requests []struct {
+			ID     json.RawMessage `json:"id"`
+			Params []interface{}   `json:"params"`
+		}
+		json.Unmarshal(body, &requests)
+		responses := []string{}
+		for _, request := range requests {
+			number := request.Params[0].(string)
+			if number == "0x2" {
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"header not found"}}`, request.ID))
+				continue
+			}
+			responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"number":"%s","hash":"0x01"}}`, request.ID, number))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+	defer server.Close()
+
+	client, err := Connect(server.URL)
+	if err != nil {
+		t.Fatalf("Error while connecting. %v", err)
+	}
+	blocks, strs, errs, err := client.GetBlocksByNumber2([]*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)})
+	if err != nil {
+		t.Fatalf("Error while sending batch. %v", err)
+	}
+	for i, number := range []uint64{1, 2, 3} {
+		if number == 2 {
+			if errs[i] == nil || blocks[i] != nil {
+				t.Fatalf("Block #%d must be failed.", number)
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("Block #%d failed. %v", number, errs[i])
+		}
+		if blocks[i].Number.Int() != number {
+			t.Fatalf("Block number mismatch. Expected '%d' Actual '%d'", number, blocks[i].Number.Int())
+		}
+		if !strings.Contains(strs[i], `"hash":"0x01"`) {
+			t.Fatalf("Raw data mismatch. Actual '%s'", strs[i])
+		}
+	}
+}
+
+func TestGetTransactionReceipts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var requests []struct {
+			ID     json.RawMessage `json:"id"`
+			Params []interface{}   `json:"params"`
+		}
+		json.Unmarshal(body, &requests)
+		responses := []string{}
+		for _, request := range requests {
+			txHash := request.Params[0].(string)
+			if txHash == "0x02" {
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":null}`, request.ID))
+				continue
+			}
+			responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"transactionHash":"%s","status":"0x1","gasUsed":"0x5208","contractAddress":null,`+
+				`"logs":[{"address":"0x03","topics":["0x04","0x05"],"data":"0x06","logIndex":"0x0"}]}}`, request.ID, txHash))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+	defer server.Close()
+
+	client, err := Connect(server.URL)
+	if err != nil {
+		t.Fatalf("Error while connecting. %v", err)
+	}
+	receipts, _, errs, err := client.GetTransactionReceipts([]string{"0x01", "0x02"})
+	if err != nil {
+		t.Fatalf("Error while sending batch. %v", err)
+	}
+	if errs[0] != nil {
+		t.Fatalf("Receipt 0x01 failed. %v", errs[0])
+	}
+	if receipts[0].Status.Int() != 1 || receipts[0].GasUsed.Int() != 21000 || receipts[0].ContractAddress != nil {
+		t.Fatalf("Receipt 0x01 mismatch. Actual '%+v'", receipts[0])
+	}
+	if len(receipts[0].Logs) != 1 || len(receipts[0].Logs[0].Topics) != 2 || receipts[0].Logs[0].Topics[1].Hex() != "05" {
+		t.Fatalf("Logs of receipt 0x01 mismatch. Actual '%+v'", receipts[0].Logs)
+	}
+	if ErrorClassOf(errs[1]) != ErrorClassNotFound {
+		t.Fatalf("Receipt 0x02 must not be found. Actual '%v'", errs[1])
+	}
+}
@@ -67,6 +67,35 @@ func (b *Block) SigHash() []byte {
 	return hash
 }
 
+// Hash of the full header. Viction headers also include validators, validator and penalties fields.
+func (b *Block) HeaderHash() []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hash := make([]byte, 32)
+
+	rlp.Encode(hasher, []interface{}{
+		b.ParentHash.Bytes(),
+		b.Sha3Uncles.Bytes(),
+		b.Miner.Bytes(),
+		b.StateRoot.Bytes(),
+		b.TransactionsRoot.Bytes(),
+		b.ReceiptsRoot.Bytes(),
+		b.LogsBloom.Bytes(),
+		b.Difficulty.BigInt(),
+		b.Number.BigInt(),
+		b.GasLimit.Int(),
+		b.GasUsed.Int(),
+		b.Timestamp.BigInt(),
+		b.ExtraData.Bytes(),
+		b.MixDigest.Bytes(),
+		b.Nonce.Bytes(),
+		b.Validators.Bytes(),
+		b.Validator.Bytes(),
+		b.Penalties.Bytes(),
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
 type Transaction struct {
 	Hash        *Hex     `json:"hash,omitempty"`
 	BlockNumber *Uint256 `json:"blockNumber,omitempty"`
@@ -84,6 +113,34 @@ type Transaction struct {
 	S           *Hex     `json:"s,omitempty"`
 }
 
+type Receipt struct {
+	TransactionHash   *Hex     `json:"transactionHash,omitempty"`
+	TransactionIndex  *Uint64  `json:"transactionIndex,omitempty"`
+	BlockHash         *Hex     `json:"blockHash,omitempty"`
+	BlockNumber       *Uint256 `json:"blockNumber,omitempty"`
+	CumulativeGasUsed *Uint64  `json:"cumulativeGasUsed,omitempty"`
+	GasUsed           *Uint64  `json:"gasUsed,omitempty"`
+	ContractAddress   *Hex     `json:"contractAddress,omitempty"`
+	LogsBloom         *Hex     `json:"logsBloom,omitempty"`
+	// Nil for receipts created before Byzantium which carry post-state root instead.
+	Status *Uint64 `json:"status,omitempty"`
+	Root   *Hex    `json:"root,omitempty"`
+
+	Logs []*Log `json:"logs,omitempty"`
+}
+
+type Log struct {
+	Address          *Hex     `json:"address,omitempty"`
+	Topics           []*Hex   `json:"topics,omitempty"`
+	Data             *Hex     `json:"data,omitempty"`
+	BlockNumber      *Uint256 `json:"blockNumber,omitempty"`
+	BlockHash        *Hex     `json:"blockHash,omitempty"`
+	TransactionHash  *Hex     `json:"transactionHash,omitempty"`
+	TransactionIndex *Uint64  `json:"transactionIndex,omitempty"`
+	LogIndex         *Uint64  `json:"logIndex,omitempty"`
+	Removed          bool     `json:"removed,omitempty"`
+}
+
 type TxTraceResult struct {
 	TxHash string                  `json:"txHash,omitempty"`
 	Result *TraceTransactionResult `json:"result,omitempty"`
@@ -101,6 +158,7 @@ type TraceTransactionResult struct {
 	GasUsed string `json:"gasUsed,omitempty"`
 	Input   string `json:"input,omitempty"`
 	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
 	Time    string `json:"time,omitempty"`
 
 	Calls []*TraceTransactionCall `json:"calls,omitempty"`
@@ -115,6 +173,7 @@ type TraceTransactionCall struct {
 	GasUsed string `json:"gasUsed,omitempty"`
 	Input   string `json:"input,omitempty"`
 	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
 
 	Calls []*TraceTransactionCall `json:"calls,omitempty"`
 }
@@ -123,7 +182,14 @@ type Hex struct {
 	i []byte
 }
 
+func NewHex(b []byte) *Hex {
+	return &Hex{b}
+}
+
 func (h *Hex) Bytes() []byte {
+	if h == nil {
+		return nil
+	}
 	return h.i
 }
 
@@ -161,6 +227,10 @@ type Uint64 struct {
 	i uint64
 }
 
+func NewUint64(i uint64) *Uint64 {
+	return &Uint64{i}
+}
+
 func (n *Uint64) Int() uint64 {
 	return n.i
 }
@@ -197,6 +267,10 @@ type Uint256 struct {
 	i *big.Int
 }
 
+func NewUint256(i *big.Int) *Uint256 {
+	return &Uint256{i}
+}
+
 func (n *Uint256) Int() uint64 {
 	return n.i.Uint64()
 }
@@ -0,0 +1,212 @@
+package svc
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"viction-rpc-crawler-go/rpc"
+)
+
+// BenchmarkSample is the measurement of a single block request.
+type BenchmarkSample struct {
+	Number     uint64
+	Latency    time.Duration
+	Size       int
+	RetryCount int
+	ErrorClass rpc.ErrorClass
+	// Digest of response data to compare responses of different endpoints. Empty if request failed.
+	Digest string
+}
+
+func NewGetBlockSamples(results []*GetBlockResult) []*BenchmarkSample {
+	samples := make([]*BenchmarkSample, len(results))
+	for i, result := range results {
+		samples[i] = &BenchmarkSample{
+			Latency:    result.Latency,
+			Size:       len(result.RawData),
+			RetryCount: result.RetryCount,
+			ErrorClass: result.ErrorClass,
+		}
+		if result.Error == nil {
+			samples[i].Digest = RawDataDigest(result.RawData)
+		}
+		if result.Number != nil {
+			samples[i].Number = result.Number.Uint64()
+		}
+	}
+	return samples
+}
+
+func NewTraceBlockSamples(results []*TraceBlockResult) []*BenchmarkSample {
+	samples := make([]*BenchmarkSample, len(results))
+	for i, result := range results {
+		samples[i] = &BenchmarkSample{
+			Latency:    result.Latency,
+			Size:       len(result.RawData),
+			RetryCount: result.RetryCount,
+			ErrorClass: result.ErrorClass,
+		}
+		if result.Error == nil {
+			samples[i].Digest = RawDataDigest(result.RawData)
+		}
+		if result.Number != nil {
+			samples[i].Number = result.Number.Uint64()
+		}
+	}
+	return samples
+}
+
+// BenchmarkReport summarizes samples of a benchmark run. Latencies are in milliseconds.
+type BenchmarkReport struct {
+	Method          string                 `json:"method"`
+	Endpoint        string                 `json:"endpoint"`
+	FromBlock       uint64                 `json:"from_block"`
+	ToBlock         uint64                 `json:"to_block"`
+	BlockCount      int                    `json:"block_count"`
+	ErrorCount      int                    `json:"error_count"`
+	RetryCount      int                    `json:"retry_count"`
+	ElapsedMs       float64                `json:"elapsed_ms"`
+	LatencyP50Ms    float64                `json:"latency_p50_ms"`
+	LatencyP90Ms    float64                `json:"latency_p90_ms"`
+	LatencyP99Ms    float64                `json:"latency_p99_ms"`
+	LatencyMaxMs    float64                `json:"latency_max_ms"`
+	BlocksPerSecond float64                `json:"blocks_per_second"`
+	TotalBytes      int64                  `json:"total_bytes"`
+	BytesPerSecond  float64                `json:"bytes_per_second"`
+	Errors          map[rpc.ErrorClass]int `json:"errors"`
+}
+
+func NewBenchmarkReport(method, endpoint string, from, to uint64, samples []*BenchmarkSample, elapsed time.Duration) *BenchmarkReport {
+	report := &BenchmarkReport{
+		Method:     method,
+		Endpoint:   endpoint,
+		FromBlock:  from,
+		ToBlock:    to,
+		BlockCount: len(samples),
+		ElapsedMs:  durationToMs(elapsed),
+		Errors:     map[rpc.ErrorClass]int{},
+	}
+	latencies := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		latencies[i] = sample.Latency
+		report.RetryCount += sample.RetryCount
+		if sample.ErrorClass != rpc.ErrorClassNone {
+			report.ErrorCount++
+			report.Errors[sample.ErrorClass]++
+			continue
+		}
+		report.TotalBytes += int64(sample.Size)
+	}
+	slices.Sort(latencies)
+	report.LatencyP50Ms = durationToMs(Percentile(latencies, 50))
+	report.LatencyP90Ms = durationToMs(Percentile(latencies, 90))
+	report.LatencyP99Ms = durationToMs(Percentile(latencies, 99))
+	report.LatencyMaxMs = durationToMs(Percentile(latencies, 100))
+	if elapsed > 0 {
+		report.BlocksPerSecond = float64(report.BlockCount-report.ErrorCount) / elapsed.Seconds()
+		report.BytesPerSecond = float64(report.TotalBytes) / elapsed.Seconds()
+	}
+	return report
+}
+
+// Return the p-th percentile of sorted values using nearest-rank method. Return 0 if there are no values.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// BenchmarkMismatch is a block for which endpoints returned different data.
+type BenchmarkMismatch struct {
+	BlockNumber uint64 `json:"block_number"`
+	// Digest of response data in the order of reports. Empty if the request failed.
+	// Endpoints are identified by position so the same URL can be benchmarked more than once.
+	Digests []string `json:"digests"`
+}
+
+// BenchmarkComparison compares benchmark runs of the same blocks against multiple endpoints.
+type BenchmarkComparison struct {
+	Method     string               `json:"method"`
+	FromBlock  uint64               `json:"from_block"`
+	ToBlock    uint64               `json:"to_block"`
+	Reports    []*BenchmarkReport   `json:"reports"`
+	Mismatches []*BenchmarkMismatch `json:"mismatches"`
+}
+
+// Build comparison from reports and samples of each endpoint. `samples[i]` must belong to `reports[i]`.
+// Failed requests are not considered as mismatches.
+func NewBenchmarkComparison(reports []*BenchmarkReport, samples [][]*BenchmarkSample) *BenchmarkComparison {
+	comparison := &BenchmarkComparison{
+		Reports:    reports,
+		Mismatches: []*BenchmarkMismatch{},
+	}
+	if len(reports) > 0 {
+		comparison.Method = reports[0].Method
+		comparison.FromBlock = reports[0].FromBlock
+		comparison.ToBlock = reports[0].ToBlock
+	}
+	digests := map[uint64][]string{}
+	for i, endpointSamples := range samples {
+		for _, sample := range endpointSamples {
+			if sample.Digest == "" {
+				continue
+			}
+			if _, ok := digests[sample.Number]; !ok {
+				digests[sample.Number] = make([]string, len(samples))
+			}
+			digests[sample.Number][i] = sample.Digest
+		}
+	}
+	for number, blockDigests := range digests {
+		var first string
+		for _, digest := range blockDigests {
+			if digest == "" {
+				continue
+			}
+			if first == "" {
+				first = digest
+			} else if digest != first {
+				comparison.Mismatches = append(comparison.Mismatches, &BenchmarkMismatch{
+					BlockNumber: number,
+					Digests:     blockDigests,
+				})
+				break
+			}
+		}
+	}
+	slices.SortFunc(comparison.Mismatches, func(a, b *BenchmarkMismatch) int {
+		return cmp.Compare(a.BlockNumber, b.BlockNumber)
+	})
+	return comparison
+}
+
+// Return SHA-256 of JSON data in canonical form, so responses only differing in key order or whitespace
+// have the same digest. Return empty string if there is no data.
+func RawDataDigest(rawData string) string {
+	if rawData == "" {
+		return ""
+	}
+	data := []byte(rawData)
+	decoder := json.NewDecoder(strings.NewReader(rawData))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			data = canonical
+		}
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
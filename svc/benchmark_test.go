@@ -0,0 +1,108 @@
+package svc
+
+import (
+	"testing"
+	"time"
+	"viction-rpc-crawler-go/rpc"
+)
+
+func TestPercentile(t *testing.T) {
+	values := make([]time.Duration, 100)
+	for i := range values {
+		values[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 1 * time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		actual := Percentile(values, test.p)
+		if actual != test.expected {
+			t.Errorf("Percentile %v: expected %v, got %v.", test.p, test.expected, actual)
+		}
+	}
+	if Percentile([]time.Duration{}, 50) != 0 {
+		t.Errorf("Percentile of empty values must be zero.")
+	}
+}
+
+func TestNewBenchmarkReport(t *testing.T) {
+	samples := []*BenchmarkSample{
+		{Latency: 30 * time.Millisecond, Size: 1000},
+		{Latency: 10 * time.Millisecond, Size: 3000, RetryCount: 1},
+		{Latency: 20 * time.Millisecond, Size: 0, RetryCount: 2, ErrorClass: rpc.ErrorClassTimeout},
+		{Latency: 40 * time.Millisecond, Size: 0, ErrorClass: rpc.ErrorClassTimeout},
+	}
+	report := NewBenchmarkReport("eth_getBlockByNumber", "http://localhost:8545", 1, 4, samples, 2*time.Second)
+	if report.BlockCount != 4 || report.ErrorCount != 2 || report.RetryCount != 3 {
+		t.Fatalf("Unexpected counts %d/%d/%d.", report.BlockCount, report.ErrorCount, report.RetryCount)
+	}
+	if report.Errors[rpc.ErrorClassTimeout] != 2 {
+		t.Errorf("Expected 2 timeout errors, got %d.", report.Errors[rpc.ErrorClassTimeout])
+	}
+	if report.LatencyP50Ms != 20 || report.LatencyMaxMs != 40 {
+		t.Errorf("Unexpected latency p50 = %v, max = %v.", report.LatencyP50Ms, report.LatencyMaxMs)
+	}
+	if report.TotalBytes != 4000 || report.BytesPerSecond != 2000 || report.BlocksPerSecond != 1 {
+		t.Errorf("Unexpected throughput %d bytes, %v bytes/s, %v blocks/s.", report.TotalBytes, report.BytesPerSecond, report.BlocksPerSecond)
+	}
+}
+
+func TestRawDataDigest(t *testing.T) {
+	a := RawDataDigest(`{"number":"0x1","hash":"0xab"}`)
+	b := RawDataDigest(`{ "hash": "0xab", "number": "0x1" }`)
+	c := RawDataDigest(`{"number":"0x1","hash":"0xac"}`)
+	if a != b {
+		t.Errorf("Digests of equivalent JSON must be equal.")
+	}
+	if a == c {
+		t.Errorf("Digests of different JSON must not be equal.")
+	}
+	if RawDataDigest("") != "" {
+		t.Errorf("Digest of empty data must be empty.")
+	}
+}
+
+func TestNewBenchmarkComparison(t *testing.T) {
+	reports := []*BenchmarkReport{
+		{Method: "eth_getBlockByNumber", Endpoint: "http://a", FromBlock: 1, ToBlock: 3},
+		{Method: "eth_getBlockByNumber", Endpoint: "http://b", FromBlock: 1, ToBlock: 3},
+	}
+	samples := [][]*BenchmarkSample{
+		{{Number: 1, Digest: "x"}, {Number: 2, Digest: "y"}, {Number: 3, Digest: "z"}},
+		{{Number: 1, Digest: "x"}, {Number: 2, Digest: "w"}, {Number: 3, ErrorClass: rpc.ErrorClassTimeout}},
+	}
+	comparison := NewBenchmarkComparison(reports, samples)
+	if len(comparison.Mismatches) != 1 {
+		t.Fatalf("Expected 1 mismatch, got %d.", len(comparison.Mismatches))
+	}
+	mismatch := comparison.Mismatches[0]
+	if mismatch.BlockNumber != 2 || mismatch.Digests[0] != "y" || mismatch.Digests[1] != "w" {
+		t.Errorf("Unexpected mismatch %+v.", mismatch)
+	}
+}
+
+func TestNewBenchmarkComparisonSameEndpoint(t *testing.T) {
+	reports := []*BenchmarkReport{
+		{Method: "eth_getBlockByNumber", Endpoint: "http://a", FromBlock: 1, ToBlock: 2},
+		{Method: "eth_getBlockByNumber", Endpoint: "http://a", FromBlock: 1, ToBlock: 2},
+	}
+	samples := [][]*BenchmarkSample{
+		{{Number: 1, Digest: "x"}, {Number: 2, Digest: "y"}},
+		{{Number: 1, Digest: "x"}, {Number: 2, Digest: "w"}},
+	}
+	comparison := NewBenchmarkComparison(reports, samples)
+	if len(comparison.Mismatches) != 1 {
+		t.Fatalf("Expected 1 mismatch, got %d.", len(comparison.Mismatches))
+	}
+	mismatch := comparison.Mismatches[0]
+	if mismatch.BlockNumber != 2 || mismatch.Digests[0] != "y" || mismatch.Digests[1] != "w" {
+		t.Errorf("Unexpected mismatch %+v.", mismatch)
+	}
+}
@@ -1,6 +1,7 @@
 package svc
 
 import (
+	"time"
 	"viction-rpc-crawler-go/config"
 	"viction-rpc-crawler-go/db"
 	"viction-rpc-crawler-go/rpc"
@@ -12,25 +13,35 @@ import (
 type Controller struct {
 	cfg    *config.RootConfig
 	db     *db.DbClient
-	rpc    *rpc.EthClient
+	rpc    *rpc.EthPool
 	svc    *multiplex.ServiceController
 	logger diag.Logger
 }
 
-func NewController(cfg *config.RootConfig, db *db.DbClient, rpc *rpc.EthClient, logger diag.Logger) *Controller {
+func NewController(cfg *config.RootConfig, db *db.DbClient, rpc *rpc.EthPool, logger diag.Logger) *Controller {
 	router := multiplex.NewServiceController(logger)
 
-	getBlocks := NewGetBlocks(logger)
+	scheduler := NewScheduleSvc(logger, 1000)
+	scheduler.SetRouter(router)
+	scheduler.SetWorker(1)
+	router.Register(scheduler)
+
+	getBlocks := NewGetBlocks(logger, cfg.Service.RpcBatch.GetBlock)
 	getBlocks.SetRouter(router)
 	getBlocks.SetWorker(1)
 	router.Register(getBlocks)
 
-	traceBlocks := NewTraceBlocks(logger)
+	traceBlocks := NewTraceBlocks(logger, cfg.Service.RpcBatch.TraceBlock)
 	traceBlocks.SetRouter(router)
 	traceBlocks.SetWorker(1)
 	router.Register(traceBlocks)
 
-	downloadBlock := NewDownloadBlock(logger)
+	indexBlock := NewIndexBlock(logger)
+	indexBlock.SetRouter(router)
+	indexBlock.SetWorker(1)
+	router.Register(indexBlock)
+
+	downloadBlock := NewDownloadBlock(logger, db != nil)
 	downloadBlock.SetRouter(router)
 	downloadBlock.SetWorker(4)
 	router.Register(downloadBlock)
@@ -57,20 +68,55 @@ func NewController(cfg *config.RootConfig, db *db.DbClient, rpc *rpc.EthClient,
 		writeDatabase.SetRouter(router)
 		writeDatabase.SetWorker(1)
 		router.Register(writeDatabase)
+
+		verifyBlock := NewVerifyBlock(logger)
+		verifyBlock.SetRouter(router)
+		verifyBlock.SetWorker(1)
+		router.Register(verifyBlock)
+
+		exportDatabase := NewExportDatabase(logger)
+		exportDatabase.SetRouter(router)
+		exportDatabase.SetWorker(1)
+		router.Register(exportDatabase)
+
+		reportSigning := NewReportSigning(logger)
+		reportSigning.SetRouter(router)
+		reportSigning.SetWorker(1)
+		router.Register(reportSigning)
+
+		resolveIssue := NewResolveIssue(logger)
+		resolveIssue.SetRouter(router)
+		resolveIssue.SetWorker(1)
+		router.Register(resolveIssue)
 	}
 
 	if rpc == nil {
 		logger.Warn("RPC services are not available.")
 	} else {
-		getBlock := NewGetBlock(logger, rpc)
+		getBlock := NewGetBlock(logger, rpc, cfg.Service.Retry.GetBlock)
 		getBlock.SetRouter(router)
 		getBlock.SetWorker(cfg.Service.Worker.GetBlock)
 		router.Register(getBlock)
 
-		traceBlock := NewTraceBlock(logger, rpc)
+		getReceipt := NewGetReceipt(logger, rpc, cfg.Service.Retry.GetReceipt)
+		getReceipt.SetRouter(router)
+		getReceipt.SetWorker(cfg.Service.Worker.GetReceipt)
+		router.Register(getReceipt)
+
+		getToken := NewGetToken(logger, rpc, cfg.Service.Retry.GetToken)
+		getToken.SetRouter(router)
+		getToken.SetWorker(cfg.Service.Worker.GetToken)
+		router.Register(getToken)
+
+		traceBlock := NewTraceBlock(logger, rpc, cfg.Service.Retry.TraceBlock)
 		traceBlock.SetRouter(router)
-		traceBlock.SetWorker(cfg.Service.Worker.GetBlock)
+		traceBlock.SetWorker(cfg.Service.Worker.TraceBlock)
 		router.Register(traceBlock)
+
+		followHead := NewFollowHead(logger, rpc)
+		followHead.SetRouter(router)
+		followHead.SetWorker(1)
+		router.Register(followHead)
 	}
 
 	return &Controller{
@@ -96,3 +142,36 @@ func (c *Controller) DispatchOnce(serviceID string, command string, params multi
 	params.Wait()
 	c.svc.Exec("exit", multiplex.ExecParams{})
 }
+
+func (c *Controller) Schedule(jobID string, interval time.Duration, serviceID string, command string, params multiplex.ExecParams) {
+	c.svc.Dispatch("Scheduler", "add_job", multiplex.ExecParams{
+		"job_id":      jobID,
+		"interval_ms": interval.Milliseconds(),
+		"service_id":  serviceID,
+		"command":     command,
+		"params":      params,
+	})
+}
+
+// Index new blocks as soon as they are notified by a WebSocket endpoint.
+func (c *Controller) FollowHead(pollInterval time.Duration, batchSize int) {
+	c.svc.Dispatch("FollowHead", "start", multiplex.ExecParams{
+		"batch_size":       batchSize,
+		"poll_interval_ms": pollInterval.Milliseconds(),
+	})
+}
+
+// Stop scheduling new jobs, wait for running jobs to finish then exit the controller.
+func (c *Controller) Stop() {
+	params := multiplex.ExecParams{}
+	params.ExpectReturn()
+	c.svc.Dispatch("Scheduler", "stop", params)
+	params.Wait()
+	if c.rpc != nil {
+		params = multiplex.ExecParams{}
+		params.ExpectReturn()
+		c.svc.Dispatch("FollowHead", "stop", params)
+		params.Wait()
+	}
+	c.svc.Exec("exit", multiplex.ExecParams{})
+}
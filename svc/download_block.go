@@ -1,19 +1,26 @@
 package svc
 
 import (
+	"errors"
 	"math/big"
+	"slices"
 
 	"github.com/tforce-io/tf-golib/diag"
 	"github.com/tforce-io/tf-golib/multiplex"
+	"github.com/tforce-io/tf-golib/opx"
 )
 
 type DownloadBlock struct {
 	multiplex.ServiceCore
-	i *multiplex.ServiceCoreInternal
+	i           *multiplex.ServiceCoreInternal
+	useDatabase bool
 }
 
-func NewDownloadBlock(logger diag.Logger) *DownloadBlock {
-	svc := &DownloadBlock{}
+// Failed blocks are recorded as issues if useDatabase is true, otherwise in the failure ledger under root dir.
+func NewDownloadBlock(logger diag.Logger, useDatabase bool) *DownloadBlock {
+	svc := &DownloadBlock{
+		useDatabase: useDatabase,
+	}
 	svc.i = svc.InitServiceCore("DownloadBlock", logger, svc.coreProcessHook)
 	return svc
 }
@@ -34,6 +41,14 @@ func (s *DownloadBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceM
 		root := msg.GetParam("root", "").(string)
 		s.downloadBlockTraces(workerID, fromBlockNumber, toBlockNumber, batchSize, root)
 		msg.Return(true)
+	case "retry_failed":
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		root := msg.GetParam("root", "").(string)
+		result := s.retryFailedBlocks(workerID, batchSize, root)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Failed blocks retry stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
 	default:
 		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
 		msg.Return(nil)
@@ -58,12 +73,15 @@ func (s *DownloadBlock) downloadBlocks(workerID uint64, from, to *big.Int, batch
 		s.Dispatch("GetBlocks", "get_blocks_range", getBlocksRequest)
 		getBlocksResponse := getBlocksRequest.WaitForReturn().(*GetBlocksResult)
 		getBlockResults := []*GetBlockResult{}
+		failedBlocks := []*FailedBlock{}
 		for _, blockResult := range getBlocksResponse.Data {
 			if blockResult.Error != nil {
+				failedBlocks = append(failedBlocks, NewFailedBlock(BlockCategory, blockResult.Number, blockResult.ErrorClass, blockResult.Error))
 				continue
 			}
 			getBlockResults = append(getBlockResults, blockResult)
 		}
+		s.recordFailedBlocks(workerID, failedBlocks, root)
 		writeBlockRequest := multiplex.ExecParams{
 			"blocks": getBlockResults,
 			"root":   root,
@@ -92,12 +110,15 @@ func (s *DownloadBlock) downloadBlockTraces(workerID uint64, from, to *big.Int,
 		s.Dispatch("TraceBlocks", "trace_blocks_range", traceBlocksRequest)
 		traceBlocksResponse := traceBlocksRequest.WaitForReturn().(*TraceBlocksResult)
 		traceBlockResults := []*TraceBlockResult{}
+		failedBlocks := []*FailedBlock{}
 		for _, traceBlockResult := range traceBlocksResponse.Data {
 			if traceBlockResult.Error != nil {
+				failedBlocks = append(failedBlocks, NewFailedBlock(TraceBlockCategory, traceBlockResult.Number, traceBlockResult.ErrorClass, traceBlockResult.Error))
 				continue
 			}
 			traceBlockResults = append(traceBlockResults, traceBlockResult)
 		}
+		s.recordFailedBlocks(workerID, failedBlocks, root)
 		writeBlockTracesRequest := multiplex.ExecParams{
 			"block_traces": traceBlockResults,
 			"root":         root,
@@ -108,3 +129,156 @@ func (s *DownloadBlock) downloadBlockTraces(workerID uint64, from, to *big.Int,
 		batchStartBlockNumber = new(big.Int).Add(batchEndBlockNumber, big.NewInt(1))
 	}
 }
+
+// Fetch recorded failed blocks again. Blocks fetched successfully are written to root dir and resolved,
+// the others stay recorded with their latest error.
+func (s *DownloadBlock) retryFailedBlocks(workerID uint64, batch int, root string) *RetryFailedResult {
+	result := &RetryFailedResult{}
+	if root == "" {
+		result.Error = errors.New("root dir is empty")
+		return result
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	loadResponse := s.loadFailedBlocks(root)
+	if loadResponse.Error != nil {
+		result.Error = loadResponse.Error
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: %d failed blocks found.", s.ServiceID(), workerID, len(loadResponse.Data))
+	for _, category := range []string{BlockCategory, TraceBlockCategory} {
+		// Database may hold more than one open issue for the same block, all of them are resolved together.
+		failedBlocksByNumber := map[uint64][]*FailedBlock{}
+		blockNumbers := []*big.Int{}
+		for _, failedBlock := range loadResponse.Data {
+			if failedBlock.Category != category {
+				continue
+			}
+			number := failedBlock.Number.Uint64()
+			if _, ok := failedBlocksByNumber[number]; !ok {
+				blockNumbers = append(blockNumbers, failedBlock.Number)
+			}
+			failedBlocksByNumber[number] = append(failedBlocksByNumber[number], failedBlock)
+		}
+		slices.SortFunc(blockNumbers, func(a, b *big.Int) int {
+			return a.Cmp(b)
+		})
+		for batchStart := 0; batchStart < len(blockNumbers); batchStart += batch {
+			batchNumbers := blockNumbers[batchStart:min(batchStart+batch, len(blockNumbers))]
+			stillFailedBlocks := []*FailedBlock{}
+			succeededNumbers := []*big.Int{}
+			switch category {
+			case BlockCategory:
+				getBlocksRequest := multiplex.ExecParams{
+					"block_numbers": batchNumbers,
+				}
+				getBlocksRequest.ExpectReturn()
+				s.Dispatch("GetBlocks", "get_blocks", getBlocksRequest)
+				getBlocksResponse := getBlocksRequest.WaitForReturn().(*GetBlocksResult)
+				getBlockResults := []*GetBlockResult{}
+				for _, blockResult := range getBlocksResponse.Data {
+					if blockResult.Error != nil {
+						stillFailedBlocks = append(stillFailedBlocks, NewFailedBlock(category, blockResult.Number, blockResult.ErrorClass, blockResult.Error))
+						continue
+					}
+					getBlockResults = append(getBlockResults, blockResult)
+					succeededNumbers = append(succeededNumbers, blockResult.Number)
+				}
+				writeBlockRequest := multiplex.ExecParams{
+					"blocks": getBlockResults,
+					"root":   root,
+				}
+				writeBlockRequest.ExpectReturn()
+				s.Dispatch("WriteFileSystem", "eth_getBlockByNumber", writeBlockRequest)
+				writeBlockRequest.Wait()
+			case TraceBlockCategory:
+				traceBlocksRequest := multiplex.ExecParams{
+					"block_numbers": batchNumbers,
+				}
+				traceBlocksRequest.ExpectReturn()
+				s.Dispatch("TraceBlocks", "trace_blocks", traceBlocksRequest)
+				traceBlocksResponse := traceBlocksRequest.WaitForReturn().(*TraceBlocksResult)
+				traceBlockResults := []*TraceBlockResult{}
+				for _, traceBlockResult := range traceBlocksResponse.Data {
+					if traceBlockResult.Error != nil {
+						stillFailedBlocks = append(stillFailedBlocks, NewFailedBlock(category, traceBlockResult.Number, traceBlockResult.ErrorClass, traceBlockResult.Error))
+						continue
+					}
+					traceBlockResults = append(traceBlockResults, traceBlockResult)
+					succeededNumbers = append(succeededNumbers, traceBlockResult.Number)
+				}
+				writeBlockTracesRequest := multiplex.ExecParams{
+					"block_traces": traceBlockResults,
+					"root":         root,
+				}
+				writeBlockTracesRequest.ExpectReturn()
+				s.Dispatch("WriteFileSystem", "debug_traceBlockByNumber", writeBlockTracesRequest)
+				writeBlockTracesRequest.Wait()
+			}
+			resolvedBlocks := []*FailedBlock{}
+			for _, number := range succeededNumbers {
+				resolvedBlocks = append(resolvedBlocks, failedBlocksByNumber[number.Uint64()]...)
+			}
+			s.recordFailedBlocks(workerID, stillFailedBlocks, root)
+			resolveResponse := s.resolveFailedBlocks(resolvedBlocks, root)
+			if resolveResponse.Error != nil {
+				result.Error = resolveResponse.Error
+				return result
+			}
+			result.RetriedCount += len(batchNumbers)
+			result.ResolvedCount += len(succeededNumbers)
+		}
+	}
+	s.i.Logger.Infof("%s#%d: %d failed blocks retried. Resolved count = %d.", s.ServiceID(), workerID, result.RetriedCount, result.ResolvedCount)
+	return result
+}
+
+func (s *DownloadBlock) loadFailedBlocks(root string) *FailedBlocksResult {
+	var request multiplex.ExecParams
+	if s.useDatabase {
+		request = multiplex.ExecParams{}
+		request.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_failed_blocks", request)
+	} else {
+		request = multiplex.ExecParams{
+			"root": root,
+		}
+		request.ExpectReturn()
+		s.Dispatch("ReadFileSystem", "read_failed_blocks", request)
+	}
+	return request.WaitForReturn().(*FailedBlocksResult)
+}
+
+func (s *DownloadBlock) recordFailedBlocks(workerID uint64, failedBlocks []*FailedBlock, root string) {
+	if len(failedBlocks) == 0 {
+		return
+	}
+	s.i.Logger.Warnf("%s#%d: %d blocks failed. First failed block = #%d.", s.ServiceID(), workerID, len(failedBlocks), failedBlocks[0].Number.Uint64())
+	request := multiplex.ExecParams{
+		"failed_blocks": failedBlocks,
+		"root":          root,
+	}
+	request.ExpectReturn()
+	s.Dispatch(opx.Ternary(s.useDatabase, "WriteDatabase", "WriteFileSystem"), opx.Ternary(s.useDatabase, "save_failed_blocks", "record_failed_blocks"), request)
+	request.Wait()
+}
+
+func (s *DownloadBlock) resolveFailedBlocks(failedBlocks []*FailedBlock, root string) *FailedBlocksResult {
+	if len(failedBlocks) == 0 {
+		return &FailedBlocksResult{}
+	}
+	request := multiplex.ExecParams{
+		"failed_blocks": failedBlocks,
+		"root":          root,
+	}
+	request.ExpectReturn()
+	s.Dispatch(opx.Ternary(s.useDatabase, "WriteDatabase", "WriteFileSystem"), "resolve_failed_blocks", request)
+	return request.WaitForReturn().(*FailedBlocksResult)
+}
+
+type RetryFailedResult struct {
+	RetriedCount  int
+	ResolvedCount int
+	Error         error
+}
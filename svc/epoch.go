@@ -0,0 +1,79 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/gurukami/typ"
+)
+
+// Length of a double validator index in the validators field of checkpoint blocks.
+const m2IndexLength = 4
+
+// Decode the epoch opened by a checkpoint block. Masternodes are listed in extra data,
+// the validators field holds the index of the double validator of every masternode
+// and the penalties field lists penalized masternodes. Return nil if the block is not a checkpoint block.
+// Indexes are random numbers which consensus reduces modulo the number of masternodes. If the validators field
+// is malformed or holds fewer indexes than masternodes, M2 indexes are left null.
+func DecodeEpoch(block *rpc.Block) (*db.Epoch, []*db.EpochValidator, []*db.EpochPenalty) {
+	blockNumber := block.Number.Int()
+	if blockNumber%EpochLength != 0 {
+		return nil, nil, nil
+	}
+	epoch := &db.Epoch{
+		ID:                  blockNumber / EpochLength,
+		CheckpointBlockID:   blockNumber,
+		CheckpointBlockHash: block.Hash.Hex(),
+		Timestamp:           int64(block.Timestamp.Int()),
+	}
+	masternodes := parseSigners(block.ExtraData.Bytes())
+	m2Indexes := parseM2Indexes(block.Validators.Bytes())
+	if len(m2Indexes) < len(masternodes) {
+		m2Indexes = nil
+	}
+	validators := make([]*db.EpochValidator, len(masternodes))
+	for i, masternode := range masternodes {
+		validators[i] = &db.EpochValidator{
+			EpochID:  epoch.ID,
+			Position: uint16(i),
+			Address:  hex.EncodeToString(masternode),
+		}
+		if m2Indexes != nil {
+			validators[i].M2Index = typ.NUint16(uint16(m2Indexes[i] % uint64(len(masternodes))))
+		}
+	}
+	penalties := []*db.EpochPenalty{}
+	penaltyBytes := block.Penalties.Bytes()
+	if len(penaltyBytes)%addressLength == 0 {
+		for i := 0; i < len(penaltyBytes); i += addressLength {
+			penalties = append(penalties, &db.EpochPenalty{
+				EpochID: epoch.ID,
+				Address: hex.EncodeToString(penaltyBytes[i : i+addressLength]),
+			})
+		}
+	}
+	epoch.ValidatorCount = uint16(len(validators))
+	epoch.PenaltyCount = uint16(len(penalties))
+	return epoch, validators, penalties
+}
+
+// Split the validators field of a checkpoint block into 4-byte chunks. Each chunk is a decimal number
+// in ASCII left-padded with zero bytes, e.g. 0x00000032 is 2. Return nil if the field is malformed.
+func parseM2Indexes(data []byte) []uint64 {
+	if len(data) == 0 || len(data)%m2IndexLength != 0 {
+		return nil
+	}
+	indexes := make([]uint64, len(data)/m2IndexLength)
+	for i := range indexes {
+		digits := bytes.TrimLeft(data[i*m2IndexLength:(i+1)*m2IndexLength], "\x00")
+		index, err := strconv.ParseUint(string(digits), 10, 32)
+		if err != nil {
+			return nil
+		}
+		indexes[i] = index
+	}
+	return indexes
+}
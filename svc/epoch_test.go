@@ -0,0 +1,73 @@
+package svc
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+	"viction-rpc-crawler-go/rpc"
+)
+
+func TestDecodeEpoch(t *testing.T) {
+	epoch, validators, penalties := DecodeEpoch(loadFixtureBlock(t, "synthetic_checkpoint_block.json"))
+	if epoch == nil {
+		t.Fatal("Expected epoch to be decoded from checkpoint block.")
+	}
+	if epoch.ID != 96000 || epoch.CheckpointBlockID != 86400000 || epoch.ValidatorCount != 3 || epoch.PenaltyCount != 1 {
+		t.Errorf("Unexpected epoch %+v.", epoch)
+	}
+	addresses := []string{
+		"1111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222",
+		"3333333333333333333333333333333333333333",
+	}
+	m2Indexes := []uint16{2, 0, 1}
+	if len(validators) != len(addresses) {
+		t.Fatalf("Expected %d validators, got %d.", len(addresses), len(validators))
+	}
+	for i, validator := range validators {
+		if validator.EpochID != 96000 || validator.Position != uint16(i) || validator.Address != addresses[i] ||
+			!validator.M2Index.Present() || validator.M2Index.V() != m2Indexes[i] {
+			t.Errorf("Unexpected validator #%d %+v.", i, validator)
+		}
+	}
+	if len(penalties) != 1 || penalties[0].EpochID != 96000 || penalties[0].Address != "4444444444444444444444444444444444444444" {
+		t.Errorf("Unexpected penalties %+v.", penalties)
+	}
+
+	block := loadFixtureBlock(t, "synthetic_checkpoint_block.json")
+	block.Validators = rpc.NewHex([]byte("\x00\x00\x002\x00\x00\x000"))
+	_, validators, _ = DecodeEpoch(block)
+	for i, validator := range validators {
+		if validator.M2Index.Present() {
+			t.Errorf("Expected no M2 index when indexes are fewer than masternodes, got #%d %+v.", i, validator)
+		}
+	}
+
+	epoch, validators, penalties = DecodeEpoch(loadFixtureBlock(t, "synthetic_block_empty.json"))
+	if epoch != nil || validators != nil || penalties != nil {
+		t.Errorf("Expected nothing to be decoded from non-checkpoint block, got %+v.", epoch)
+	}
+}
+
+func TestParseM2Indexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []uint64
+	}{
+		{"padded", "000000320000003000003132", []uint64{2, 0, 12}},
+		{"unpadded", "31323334", []uint64{1234}},
+		{"empty", "", nil},
+		{"truncated", "0000003200", nil},
+		{"binary", "00000002", nil},
+		{"zero_chunk", "0000003200000000", nil},
+		{"inner_zero", "00320031", nil},
+		{"negative", "00002d31", nil},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		if actual := parseM2Indexes(data); !slices.Equal(actual, test.expected) || (actual == nil) != (test.expected == nil) {
+			t.Errorf("%s: expected %v, got %v.", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package svc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/filesystem"
+
+	"github.com/gurukami/typ"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Tables that can be exported.
+const (
+	BlocksTable       = "blocks"
+	TransactionsTable = "transactions"
+	IssuesTable       = "issues"
+)
+
+// exportTable describes columns of an exported table and how to read its rows of a block range.
+type exportTable struct {
+	Columns []*filesystem.ExportColumn
+	// Read rows of blocks between from and to inclusively as records in column order.
+	ReadRecords func(s *ExportDatabase, from, to *big.Int) ([][]interface{}, error)
+}
+
+var exportTables = map[string]*exportTable{
+	BlocksTable: {
+		Columns: []*filesystem.ExportColumn{
+			{Name: "id", Type: filesystem.ColumnTypeInt64},
+			{Name: "hash", Type: filesystem.ColumnTypeString},
+			{Name: "parent_hash", Type: filesystem.ColumnTypeString},
+			{Name: "timestamp", Type: filesystem.ColumnTypeInt64},
+			{Name: "size", Type: filesystem.ColumnTypeInt64},
+			{Name: "gas_limit", Type: filesystem.ColumnTypeInt64},
+			{Name: "gas_used", Type: filesystem.ColumnTypeInt64},
+			{Name: "difficulty", Type: filesystem.ColumnTypeString},
+			{Name: "total_difficulty", Type: filesystem.ColumnTypeString},
+			{Name: "transaction_count", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_system", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_sign", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_randomize", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_tomox", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_tomox_trading_state", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_lending", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_final_lending", Type: filesystem.ColumnTypeInt64},
+			{Name: "transaction_count_debug", Type: filesystem.ColumnTypeInt64},
+			{Name: "block_mint_duration", Type: filesystem.ColumnTypeInt64},
+			{Name: "uncle_hash", Type: filesystem.ColumnTypeString},
+			{Name: "state_root", Type: filesystem.ColumnTypeString},
+			{Name: "transaction_root", Type: filesystem.ColumnTypeString},
+			{Name: "receipts_root", Type: filesystem.ColumnTypeString},
+			{Name: "logs_bloom", Type: filesystem.ColumnTypeString},
+			{Name: "miner", Type: filesystem.ColumnTypeString},
+			{Name: "extra_data", Type: filesystem.ColumnTypeString},
+			{Name: "mix_digest", Type: filesystem.ColumnTypeString},
+			{Name: "nonce", Type: filesystem.ColumnTypeString},
+			{Name: "validator", Type: filesystem.ColumnTypeString},
+			{Name: "validators", Type: filesystem.ColumnTypeString},
+			{Name: "penalties", Type: filesystem.ColumnTypeString},
+			{Name: "creator", Type: filesystem.ColumnTypeString},
+			{Name: "attestor", Type: filesystem.ColumnTypeString},
+		},
+		ReadRecords: func(s *ExportDatabase, from, to *big.Int) ([][]interface{}, error) {
+			request := multiplex.ExecParams{
+				"from_block_number": from,
+				"to_block_number":   to,
+			}
+			request.ExpectReturn()
+			s.Dispatch("ReadDatabase", "get_blocks_range", request)
+			response := request.WaitForReturn().(*DbBlocksResult)
+			if response.Error != nil {
+				return nil, response.Error
+			}
+			records := make([][]interface{}, len(response.Data))
+			for i, b := range response.Data {
+				records[i] = []interface{}{
+					int64(b.ID), b.Hash, b.ParentHash, b.Timestamp, int64(b.Size), int64(b.GasLimit), int64(b.GasUsed),
+					b.Difficulty.String(), b.TotalDifficulty.String(),
+					exportNullUint16(b.TransactionCount), exportNullUint16(b.TransactionCountSystem),
+					exportNullUint16(b.TransactionCountSign), exportNullUint16(b.TransactionCountRandomize), exportNullUint16(b.TransactionCountTomoX),
+					exportNullUint16(b.TransactionCountTomoXTradingState), exportNullUint16(b.TransactionCountLending), exportNullUint16(b.TransactionCountFinalLending),
+					exportNullUint16(b.TransactionCountDebug),
+					exportNullUint64(b.BlockMintDuration),
+					exportBytes(b.UncleHash), exportBytes(b.StateRoot), exportBytes(b.TransactionsRoot), exportBytes(b.ReceiptsRoot),
+					exportBytes(b.LogsBloom), exportBytes(b.Miner), exportBytes(b.ExtraData), exportBytes(b.MixDigest), exportBytes(b.Nonce),
+					exportBytes(b.Validator), exportBytes(b.Validators), exportBytes(b.Penalties),
+					exportNullString(b.Creator), exportNullString(b.Attestor),
+				}
+			}
+			return records, nil
+		},
+	},
+	TransactionsTable: {
+		Columns: []*filesystem.ExportColumn{
+			{Name: "id", Type: filesystem.ColumnTypeInt64},
+			{Name: "hash", Type: filesystem.ColumnTypeString},
+			{Name: "block_id", Type: filesystem.ColumnTypeInt64},
+			{Name: "block_hash", Type: filesystem.ColumnTypeString},
+			{Name: "transaction_index", Type: filesystem.ColumnTypeInt64},
+			{Name: "from", Type: filesystem.ColumnTypeString},
+			{Name: "to", Type: filesystem.ColumnTypeString},
+			{Name: "value", Type: filesystem.ColumnTypeString},
+			{Name: "nonce", Type: filesystem.ColumnTypeInt64},
+			{Name: "gas", Type: filesystem.ColumnTypeInt64},
+			{Name: "gas_price", Type: filesystem.ColumnTypeString},
+			{Name: "category", Type: filesystem.ColumnTypeString},
+		},
+		ReadRecords: func(s *ExportDatabase, from, to *big.Int) ([][]interface{}, error) {
+			request := multiplex.ExecParams{
+				"from_block_number": from,
+				"to_block_number":   to,
+			}
+			request.ExpectReturn()
+			s.Dispatch("ReadDatabase", "get_transactions_range", request)
+			response := request.WaitForReturn().(*DbTransactionsResult)
+			if response.Error != nil {
+				return nil, response.Error
+			}
+			records := make([][]interface{}, len(response.Data))
+			for i, t := range response.Data {
+				records[i] = []interface{}{
+					int64(t.ID), t.Hash, int64(t.BlockID), t.BlockHash, int64(t.TransactionIndex), t.From, t.To,
+					t.Value.String(), int64(t.Nonce), int64(t.Gas), t.GasPrice.String(), exportTransactionCategory(t.Category),
+				}
+			}
+			return records, nil
+		},
+	},
+	IssuesTable: {
+		Columns: []*filesystem.ExportColumn{
+			{Name: "id", Type: filesystem.ColumnTypeInt64},
+			{Name: "type", Type: filesystem.ColumnTypeInt64},
+			{Name: "block_number", Type: filesystem.ColumnTypeInt64},
+			{Name: "block_hash", Type: filesystem.ColumnTypeString},
+			{Name: "tx_hash", Type: filesystem.ColumnTypeString},
+			{Name: "timestamp", Type: filesystem.ColumnTypeInt64},
+			{Name: "status", Type: filesystem.ColumnTypeBool},
+			{Name: "hash", Type: filesystem.ColumnTypeString},
+			{Name: "extras", Type: filesystem.ColumnTypeString},
+		},
+		ReadRecords: func(s *ExportDatabase, from, to *big.Int) ([][]interface{}, error) {
+			request := multiplex.ExecParams{
+				"from_block_number": from,
+				"to_block_number":   to,
+			}
+			request.ExpectReturn()
+			s.Dispatch("ReadDatabase", "get_issues_range", request)
+			response := request.WaitForReturn().(*IssuesResult)
+			if response.Error != nil {
+				return nil, response.Error
+			}
+			records := make([][]interface{}, len(response.Data))
+			for i, issue := range response.Data {
+				extras, err := json.Marshal(issue.Extras)
+				if err != nil {
+					return nil, err
+				}
+				records[i] = []interface{}{
+					int64(issue.ID), int64(issue.Type), int64(issue.BlockNumber), issue.BlockHash, issue.TxHash,
+					issue.Timestamp, issue.Status, issue.Hash, string(extras),
+				}
+			}
+			return records, nil
+		},
+	},
+}
+
+func IsExportTable(table string) bool {
+	_, ok := exportTables[table]
+	return ok
+}
+
+type ExportDatabase struct {
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+}
+
+func NewExportDatabase(logger diag.Logger) *ExportDatabase {
+	svc := &ExportDatabase{}
+	svc.i = svc.InitServiceCore("ExportDatabase", logger, svc.coreProcessHook)
+	return svc
+}
+
+func (s *ExportDatabase) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "export_tables":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		tables := msg.GetParam("tables", []string{}).([]string)
+		format := msg.GetParam("format", filesystem.ExportFormatCsv).(string)
+		rootDir := msg.GetParam("root", "").(string)
+		chunkSize := msg.GetParam("chunk_size", 1).(int)
+		splitSize := msg.GetParam("split_size", uint64(0)).(uint64)
+		result := s.exportTables(workerID, fromBlockNumber, toBlockNumber, tables, format, rootDir, chunkSize, splitSize)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Export stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Export rows of blocks from `from` to `to` inclusively. Rows are read `chunk` blocks at a time and
+// every `split` blocks are written to a separate file under root/<table>. If `split` is zero, every table
+// is written to a single file. If `to` is zero, export stops at the index checkpoint.
+func (s *ExportDatabase) exportTables(workerID uint64, from, to *big.Int, tables []string, format, root string, chunk int, split uint64) *ExportResult {
+	result := &ExportResult{
+		From:      new(big.Int).Set(from),
+		To:        new(big.Int).Set(to),
+		Files:     []string{},
+		RowCounts: map[string]uint64{},
+	}
+	if !filesystem.IsExportFormat(format) {
+		result.Error = fmt.Errorf("unsupported export format %s", format)
+		return result
+	}
+	for _, table := range tables {
+		if !IsExportTable(table) {
+			result.Error = fmt.Errorf("unsupported export table %s", table)
+			return result
+		}
+	}
+	if result.To.Sign() == 0 {
+		checkpointRequest := multiplex.ExecParams{}
+		checkpointRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_highest_index_block", checkpointRequest)
+		checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+		if checkpointResponse.Error != nil {
+			result.Error = checkpointResponse.Error
+			return result
+		}
+		if checkpointResponse.Number == nil {
+			result.Error = errors.New("no index checkpoint found")
+			return result
+		}
+		result.To.Set(checkpointResponse.Number)
+	}
+	if chunk < 1 {
+		chunk = 1
+	}
+	from64 := result.From.Uint64()
+	to64 := result.To.Uint64()
+	if split == 0 {
+		split = to64 - from64 + 1
+	}
+	s.i.Logger.Infof("%s#%d: Export started. From #%d to #%d.", s.ServiceID(), workerID, from64, to64)
+	for _, table := range tables {
+		for fileStart := from64; fileStart <= to64; fileStart += split {
+			fileEnd := min(fileStart+split-1, to64)
+			filePath := filepath.Join(root, table, fmt.Sprintf("%s_%d_%d.%s", table, fileStart, fileEnd, format))
+			rowCount, err := s.exportFile(exportTables[table], format, filePath, fileStart, fileEnd, uint64(chunk))
+			if err != nil {
+				result.Error = err
+				return result
+			}
+			result.RowCounts[table] += rowCount
+			if rowCount > 0 {
+				result.Files = append(result.Files, filePath)
+				s.i.Logger.Infof("%s#%d: Exported %d rows of %s from block #%d to #%d.", s.ServiceID(), workerID, rowCount, table, fileStart, fileEnd)
+			}
+			if fileEnd == to64 {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Write rows of blocks between from and to inclusively to a single file. No file is left if there are no rows.
+func (s *ExportDatabase) exportFile(table *exportTable, format, filePath string, from, to, chunk uint64) (uint64, error) {
+	w, err := filesystem.NewRecordWriter(format, filePath, table.Columns)
+	if err != nil {
+		return 0, err
+	}
+	rowCount := uint64(0)
+	for chunkStart := from; chunkStart <= to; chunkStart += chunk {
+		chunkEnd := min(chunkStart+chunk-1, to)
+		records, err := table.ReadRecords(s, new(big.Int).SetUint64(chunkStart), new(big.Int).SetUint64(chunkEnd))
+		if err != nil {
+			w.Abort()
+			return 0, err
+		}
+		for _, record := range records {
+			err = w.Write(record)
+			if err != nil {
+				w.Abort()
+				return 0, err
+			}
+		}
+		rowCount += uint64(len(records))
+		if chunkEnd == to {
+			break
+		}
+	}
+	if rowCount == 0 {
+		w.Abort()
+		return 0, nil
+	}
+	return rowCount, w.Close()
+}
+
+func exportBytes(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return hex.EncodeToString(b)
+}
+
+func exportNullString(n typ.NullString) interface{} {
+	if !n.Valid() {
+		return nil
+	}
+	return n.V()
+}
+
+func exportNullUint16(n typ.NullUint16) interface{} {
+	if !n.Valid() {
+		return nil
+	}
+	return int64(n.V())
+}
+
+// Transactions indexed before classification have no category and are exported as null.
+func exportTransactionCategory(n typ.NullUint16) interface{} {
+	if !n.Valid() {
+		return nil
+	}
+	return db.TransactionCategoryName(n.V())
+}
+
+func exportNullUint64(n typ.NullUint64) interface{} {
+	if !n.Valid() {
+		return nil
+	}
+	return int64(n.V())
+}
+
+type ExportResult struct {
+	From      *big.Int
+	To        *big.Int
+	Files     []string
+	RowCounts map[string]uint64
+	Error     error
+}
@@ -0,0 +1,76 @@
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+)
+
+// Name of the file under root dir listing failed blocks when no database is configured.
+const FailureLedgerFile = "failedBlocks.json"
+
+// FailedBlock is a block that could not be fetched. It is backed by an issue in database or
+// by an entry in the failure ledger.
+type FailedBlock struct {
+	Category   string         `json:"category"`
+	Number     *big.Int       `json:"block_number"`
+	ErrorClass rpc.ErrorClass `json:"error_class"`
+	Error      string         `json:"error"`
+	Timestamp  int64          `json:"timestamp"`
+	IssueID    uint64         `json:"-"`
+}
+
+func NewFailedBlock(category string, number *big.Int, errorClass rpc.ErrorClass, err error) *FailedBlock {
+	return &FailedBlock{
+		Category:   category,
+		Number:     number,
+		ErrorClass: errorClass,
+		Error:      err.Error(),
+		Timestamp:  time.Now().UnixMicro(),
+	}
+}
+
+func NewFailedBlockFromIssue(issue *db.Issue) *FailedBlock {
+	failedBlock := &FailedBlock{
+		Number:    new(big.Int).SetUint64(issue.BlockNumber),
+		Timestamp: issue.Timestamp,
+		IssueID:   issue.ID,
+	}
+	failedBlock.Category, _ = issue.Extras["category"].(string)
+	errorClass, _ := issue.Extras["error_class"].(string)
+	failedBlock.ErrorClass = rpc.ErrorClass(errorClass)
+	failedBlock.Error, _ = issue.Extras["error"].(string)
+	return failedBlock
+}
+
+func (b *FailedBlock) Key() string {
+	return fmt.Sprintf("%s/%s", b.Category, b.Number.String())
+}
+
+func GetFailureLedgerFile(rootDir string) string {
+	return filepath.Join(rootDir, FailureLedgerFile)
+}
+
+// Read failed blocks from the ledger under root dir. Missing ledger means no failed blocks.
+func ReadFailureLedger(rootDir string) ([]*FailedBlock, error) {
+	data, err := os.ReadFile(GetFailureLedgerFile(rootDir))
+	if os.IsNotExist(err) {
+		return []*FailedBlock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	failedBlocks := []*FailedBlock{}
+	err = json.Unmarshal(data, &failedBlocks)
+	return failedBlocks, err
+}
+
+type FailedBlocksResult struct {
+	Data  []*FailedBlock
+	Error error
+}
@@ -0,0 +1,149 @@
+package svc
+
+import (
+	"math/big"
+	"sync"
+	"time"
+	"viction-rpc-crawler-go/rpc"
+
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Interval between resubscribe attempts while falling back to polling.
+const ResubscribeIntervalMs = 5000
+
+type FollowHead struct {
+	multiplex.ServiceCore
+	i   *multiplex.ServiceCoreInternal
+	rpc *rpc.EthPool
+
+	exit      chan bool
+	exitOnce  sync.Once
+	startOnce sync.Once
+	done      sync.WaitGroup
+}
+
+func NewFollowHead(logger diag.Logger, rpcClient *rpc.EthPool) *FollowHead {
+	svc := &FollowHead{
+		exit: make(chan bool),
+	}
+	svc.i = svc.InitServiceCore("FollowHead", logger, svc.coreProcessHook)
+	svc.rpc = rpcClient
+	return svc
+}
+
+func (s *FollowHead) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "start":
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		pollIntervalMs := msg.GetParam("poll_interval_ms", int64(30000)).(int64)
+		if pollIntervalMs < 1 {
+			pollIntervalMs = 1
+		}
+		s.startOnce.Do(func() {
+			s.done.Add(1)
+			go s.follow(workerID, batchSize, time.Duration(pollIntervalMs)*time.Millisecond)
+		})
+		msg.Return(true)
+	case "stop":
+		s.exitOnce.Do(func() {
+			close(s.exit)
+		})
+		s.i.Logger.Infof("%s#%d: Waiting for running indexing.", s.ServiceID(), workerID)
+		s.done.Wait()
+		s.i.Logger.Infof("%s#%d: Head following stopped.", s.ServiceID(), workerID)
+		msg.Return(true)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Index new blocks as soon as their headers are notified. While the subscription is not available,
+// the chain is polled every pollInterval and resubscription is attempted every ResubscribeIntervalMs.
+func (s *FollowHead) follow(workerID uint64, batchSize int, pollInterval time.Duration) {
+	defer s.done.Done()
+	heads := make(chan *rpc.Block, 64)
+	var sub *ethrpc.ClientSubscription
+	resubscribeAt := time.Time{}
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+	// Catch up with the chain before waiting for the first notification.
+	s.indexBlocks(workerID, nil, batchSize)
+	for {
+		if sub == nil && !time.Now().Before(resubscribeAt) {
+			var err error
+			sub, err = s.rpc.SubscribeNewHeads(heads)
+			if err != nil {
+				sub = nil
+				resubscribeAt = time.Now().Add(ResubscribeIntervalMs * time.Millisecond)
+				s.i.Logger.Warnf("%s#%d: Cannot subscribe to new heads. Fallback to polling. %v", s.ServiceID(), workerID, err)
+			} else {
+				s.i.Logger.Infof("%s#%d: Subscribed to new heads.", s.ServiceID(), workerID)
+			}
+		}
+		var subErr <-chan error
+		if sub != nil {
+			subErr = sub.Err()
+		}
+		select {
+		case <-s.exit:
+			if sub != nil {
+				sub.Unsubscribe()
+			}
+			return
+		case head := <-heads:
+			head = s.latestHead(head, heads)
+			if head.Number == nil {
+				continue
+			}
+			s.i.Logger.Debugf("%s#%d: New head #%d.", s.ServiceID(), workerID, head.Number.Int())
+			s.indexBlocks(workerID, head.Number.BigInt(), batchSize)
+		case err := <-subErr:
+			sub = nil
+			resubscribeAt = time.Now().Add(ResubscribeIntervalMs * time.Millisecond)
+			s.i.Logger.Warnf("%s#%d: Subscription dropped. Fallback to polling. %v", s.ServiceID(), workerID, err)
+		case <-pollTicker.C:
+			if sub == nil {
+				s.indexBlocks(workerID, nil, batchSize)
+			}
+		}
+	}
+}
+
+// Drain pending notifications so only the highest head is indexed.
+func (s *FollowHead) latestHead(head *rpc.Block, heads <-chan *rpc.Block) *rpc.Block {
+	for {
+		select {
+		case next := <-heads:
+			if next.Number != nil && (head.Number == nil || next.Number.Int() > head.Number.Int()) {
+				head = next
+			}
+		default:
+			return head
+		}
+	}
+}
+
+// Index blocks up to toBlockNumber, or to the current head of the chain if toBlockNumber is nil.
+func (s *FollowHead) indexBlocks(workerID uint64, toBlockNumber *big.Int, batchSize int) {
+	if toBlockNumber == nil {
+		toBlockNumber = new(big.Int)
+	}
+	params := multiplex.ExecParams{
+		"from_block_number": new(big.Int),
+		"to_block_number":   toBlockNumber,
+		"batch_size":        batchSize,
+		"forced":            false,
+		"include_txs":       true,
+	}
+	params.ExpectReturn()
+	s.Dispatch("IndexBlock", "index_blocks", params)
+	result := params.WaitForReturn().(*IndexBlocksResult)
+	if result.Error == nil && result.BlockCount > 0 {
+		s.i.Logger.Infof("%s#%d: Indexed %d blocks up to #%d.", s.ServiceID(), workerID, result.BlockCount, result.To)
+	}
+}
@@ -2,7 +2,8 @@ package svc
 
 import (
 	"math/big"
-	"strings"
+	"time"
+	"viction-rpc-crawler-go/config"
 	"viction-rpc-crawler-go/rpc"
 
 	"github.com/tforce-io/tf-golib/diag"
@@ -14,18 +15,15 @@ type GetBlock struct {
 	multiplex.ServiceCore
 	i   *multiplex.ServiceCoreInternal
 	o   *NetworkOptions
-	rpc *rpc.EthClient
+	rpc *rpc.EthPool
 }
 
-func NewGetBlock(logger diag.Logger, rpc *rpc.EthClient) *GetBlock {
+func NewGetBlock(logger diag.Logger, rpc *rpc.EthPool, retry *config.RetryPolicyConfig) *GetBlock {
 	svc := &GetBlock{
 		rpc: rpc,
 	}
 	svc.i = svc.InitServiceCore("GetBlock", logger, svc.coreProcessHook)
-	svc.o = &NetworkOptions{
-		MaxRetries:  3,
-		MaxRetryGap: 200 * 1000000,
-	}
+	svc.o = NewNetworkOptions(retry)
 	return svc
 }
 
@@ -33,55 +31,49 @@ func (s *GetBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessag
 	switch msg.Command {
 	case "get_block":
 		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
-		block, str, err := s.rpc.GetBlockByNumber2(blockNumber)
-		retryCount := 0
-		halfRetry := false
-		for err != nil && retryCount < s.o.MaxRetries {
-			errStr := err.Error()
-			if strings.HasPrefix(err.Error(), "503 Service Unavailable: <html><body><h1>503 Service Unavailable</h1>") {
-				if !halfRetry {
-					retryCount--
-				}
-				halfRetry = !halfRetry
-			} else {
-				s.i.Logger.Warnf("%s#%02d: Block #%d retrying. %v", s.i.ServiceID, workerID, blockNumber.Uint64(), errStr)
-			}
-			s.o.WaitRetryGap()
+		var block *rpc.Block
+		var str string
+		startTime := time.Now()
+		retryCount, retryTime, err := s.o.Retry(func() error {
+			var err error
 			block, str, err = s.rpc.GetBlockByNumber2(blockNumber)
-			retryCount++
-		}
+			return err
+		}, func(err error) {
+			s.i.Logger.Warnf("%s#%02d: Block #%d retrying. %s: %v", s.i.ServiceID, workerID, blockNumber.Uint64(), rpc.ErrorClassOf(err), err)
+		})
 		result := &GetBlockResult{
-			Number:  blockNumber,
-			Data:    block,
-			RawData: str,
-			Error:   err,
+			Number:     blockNumber,
+			Data:       block,
+			RawData:    str,
+			Error:      err,
+			ErrorClass: rpc.ErrorClassOf(err),
+			Latency:    time.Since(startTime),
+			RetryCount: retryCount,
 		}
-		s.i.Logger.Infof("%s#%02d: Block #%d processed. %s. Retry count = %d.", s.i.ServiceID, workerID, blockNumber.Uint64(),
-			opx.Ternary(err == nil, "SUCCESS", "FAILED"),
+		s.i.Logger.Infof("%s#%02d: Block #%d processed. %s. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID, blockNumber.Uint64(),
+			opx.Ternary(err == nil, "SUCCESS", "FAILED "+string(result.ErrorClass)),
 			retryCount,
+			retryTime.Round(time.Millisecond),
 		)
 		msg.Return(result)
+	case "get_blocks_batch":
+		blockNumbers := msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
+		msg.Return(&GetBlocksResult{
+			Data: s.getBlocksBatch(workerID, blockNumbers),
+		})
 	case "get_block_number":
-		head, err := s.rpc.GetBlockNumber()
-		retryCount := 0
-		halfRetry := false
-		for err != nil && retryCount < s.o.MaxRetries {
-			errStr := err.Error()
-			if strings.HasPrefix(err.Error(), "503 Service Unavailable: <html><body><h1>503 Service Unavailable</h1>") {
-				if !halfRetry {
-					retryCount--
-				}
-				halfRetry = !halfRetry
-			} else {
-				s.i.Logger.Warnf("%s#%02d: Retrying. %v", s.i.ServiceID, workerID, errStr)
-			}
-			s.o.WaitRetryGap()
+		var head uint64
+		_, _, err := s.o.Retry(func() error {
+			var err error
 			head, err = s.rpc.GetBlockNumber()
-			retryCount++
-		}
+			return err
+		}, func(err error) {
+			s.i.Logger.Warnf("%s#%02d: Retrying. %s: %v", s.i.ServiceID, workerID, rpc.ErrorClassOf(err), err)
+		})
 		result := &GetBlockNumberResult{
-			Number: new(big.Int).SetUint64(head),
-			Error:  err,
+			Number:     new(big.Int).SetUint64(head),
+			Error:      err,
+			ErrorClass: rpc.ErrorClassOf(err),
 		}
 		msg.Return(result)
 	default:
@@ -91,14 +83,92 @@ func (s *GetBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessag
 	return &multiplex.HookState{Handled: true}
 }
 
+// Fetch blocks in one JSON-RPC batch. Only failed items are retried.
+func (s *GetBlock) getBlocksBatch(workerID uint64, blockNumbers []*big.Int) []*GetBlockResult {
+	results := make([]*GetBlockResult, len(blockNumbers))
+	pending := make([]int, len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		results[i] = &GetBlockResult{
+			Number: blockNumber,
+		}
+		pending[i] = i
+	}
+	start := time.Now()
+	var retryStart time.Time
+	retryCount := 0
+	for len(pending) > 0 {
+		pendingNumbers := make([]*big.Int, len(pending))
+		for j, i := range pending {
+			pendingNumbers[j] = blockNumbers[i]
+		}
+		blocks, strs, errs, err := s.rpc.GetBlocksByNumber2(pendingNumbers)
+		failed := []int{}
+		for j, i := range pending {
+			if err != nil {
+				results[i].Error = err
+			} else {
+				results[i].Data = blocks[j]
+				results[i].RawData = strs[j]
+				results[i].Error = errs[j]
+			}
+			results[i].ErrorClass = rpc.ErrorClassOf(results[i].Error)
+			if s.o.CanRetry(results[i].Error, retryCount, time.Since(start)) {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 {
+			break
+		}
+		for _, i := range pending {
+			results[i].RetryCount++
+		}
+		firstErr := results[pending[0]].Error
+		s.i.Logger.Warnf("%s#%02d: %d of %d blocks retrying. %s: %v", s.i.ServiceID, workerID, len(pending), len(blockNumbers), rpc.ErrorClassOf(firstErr), firstErr)
+		if retryCount == 0 {
+			retryStart = time.Now()
+		}
+		s.o.WaitRetryDelay(firstErr, retryCount, time.Since(start))
+		retryCount++
+	}
+	retryTime := time.Duration(0)
+	if retryCount > 0 {
+		retryTime = time.Since(retryStart)
+	}
+	latency := time.Since(start)
+	for _, result := range results {
+		result.Latency = latency
+	}
+	errorCount := 0
+	for _, result := range results {
+		if result.Error != nil {
+			errorCount++
+		}
+	}
+	if len(blockNumbers) > 0 {
+		s.i.Logger.Infof("%s#%02d: Block #%d to #%d processed. Error count = %d. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID,
+			blockNumbers[0].Uint64(), blockNumbers[len(blockNumbers)-1].Uint64(),
+			errorCount,
+			retryCount,
+			retryTime.Round(time.Millisecond),
+		)
+	}
+	return results
+}
+
 type GetBlockResult struct {
-	Number  *big.Int
-	Data    *rpc.Block
-	RawData string
-	Error   error
+	Number     *big.Int
+	Data       *rpc.Block
+	RawData    string
+	Error      error
+	ErrorClass rpc.ErrorClass
+	// Time spent on the request including retries. Blocks fetched in one batch share the same latency.
+	Latency    time.Duration
+	RetryCount int
 }
 
 type GetBlockNumberResult struct {
-	Number *big.Int
-	Error  error
+	Number     *big.Int
+	Error      error
+	ErrorClass rpc.ErrorClass
 }
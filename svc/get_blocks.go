@@ -11,63 +11,48 @@ import (
 
 type GetBlocks struct {
 	multiplex.ServiceCore
-	i *multiplex.ServiceCoreInternal
+	i            *multiplex.ServiceCoreInternal
+	rpcBatchSize int
 }
 
-func NewGetBlocks(logger diag.Logger) *GetBlocks {
-	svc := &GetBlocks{}
+func NewGetBlocks(logger diag.Logger, rpcBatchSize int) *GetBlocks {
+	svc := &GetBlocks{
+		rpcBatchSize: rpcBatchSize,
+	}
 	svc.i = svc.InitServiceCore("GetBlocks", logger, svc.coreProcessHook)
 	return svc
 }
 
 func (s *GetBlocks) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
 	switch msg.Command {
-	case "get_blocks":
-	case "get_blocks_range":
+	case "get_blocks", "get_blocks_range":
 		s.i.Logger.Infof("%s#%02d: %s started.", s.i.ServiceID, workerID, msg.Command)
 		startTime := time.Now()
-		requests := []multiplex.ExecParams{}
-		signal := new(sync.WaitGroup)
+		blockNumbers := []*big.Int{}
 		if msg.Command == "get_blocks" {
-			blockNumbers := msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
-			for _, blockNumber := range blockNumbers {
-				request := multiplex.ExecParams{
-					"block_number": blockNumber,
-					"signal":       signal,
-				}
-				request.ExpectReturnCustomSignal(signal)
-				requests = append(requests, request)
-			}
+			blockNumbers = msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
 		}
 		if msg.Command == "get_blocks_range" {
 			fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
 			toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
-			for blockNumber := fromBlockNumber; blockNumber.Cmp(toBlockNumber) <= 0; blockNumber.Set(new(big.Int).Add(blockNumber, big.NewInt(1))) {
-				request := multiplex.ExecParams{
-					"block_number": new(big.Int).Set(blockNumber),
-				}
-				request.ExpectReturnCustomSignal(signal)
-				requests = append(requests, request)
+			for blockNumber := new(big.Int).Set(fromBlockNumber); blockNumber.Cmp(toBlockNumber) <= 0; blockNumber = new(big.Int).Add(blockNumber, big.NewInt(1)) {
+				blockNumbers = append(blockNumbers, blockNumber)
 			}
 		}
-		results := &GetBlocksResult{
-			Data: make([]*GetBlockResult, len(requests)),
+		results := &GetBlocksResult{}
+		if s.rpcBatchSize > 1 {
+			results.Data = s.getBlocksBatch(blockNumbers)
+		} else {
+			results.Data = s.getBlocks(blockNumbers)
 		}
 		errorCount := 0
-		if len(requests) > 0 {
-			signal.Add(len(requests))
-			for _, request := range requests {
-				s.Dispatch("GetBlock", "get_block", request)
-			}
-			signal.Wait()
-			for i, request := range requests {
-				results.Data[i] = request.ReturnResult().(*GetBlockResult)
-				if results.Data[i].Error != nil {
-					errorCount++
-				}
+		for _, result := range results.Data {
+			if result.Error != nil {
+				errorCount++
 			}
 		}
-		s.i.Logger.Infof("%s#%02d: %d blocks retrieved in %v. Error count = %d.", s.i.ServiceID, workerID, len(requests), time.Since(startTime), errorCount)
+		results.Elapsed = time.Since(startTime)
+		s.i.Logger.Infof("%s#%02d: %d blocks retrieved in %v. Error count = %d.", s.i.ServiceID, workerID, len(blockNumbers), results.Elapsed, errorCount)
 		msg.Return(results)
 	default:
 		s.i.Logger.Warnf("%s#%02d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
@@ -76,10 +61,57 @@ func (s *GetBlocks) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessa
 	return &multiplex.HookState{Handled: true}
 }
 
+func (s *GetBlocks) getBlocks(blockNumbers []*big.Int) []*GetBlockResult {
+	results := make([]*GetBlockResult, len(blockNumbers))
+	if len(blockNumbers) == 0 {
+		return results
+	}
+	requests := make([]multiplex.ExecParams, len(blockNumbers))
+	signal := new(sync.WaitGroup)
+	signal.Add(len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		requests[i] = multiplex.ExecParams{
+			"block_number": blockNumber,
+		}
+		requests[i].ExpectReturnCustomSignal(signal)
+		s.Dispatch("GetBlock", "get_block", requests[i])
+	}
+	signal.Wait()
+	for i, request := range requests {
+		results[i] = request.ReturnResult().(*GetBlockResult)
+	}
+	return results
+}
+
+func (s *GetBlocks) getBlocksBatch(blockNumbers []*big.Int) []*GetBlockResult {
+	results := []*GetBlockResult{}
+	requests := []multiplex.ExecParams{}
+	signal := new(sync.WaitGroup)
+	for start := 0; start < len(blockNumbers); start += s.rpcBatchSize {
+		end := min(start+s.rpcBatchSize, len(blockNumbers))
+		request := multiplex.ExecParams{
+			"block_numbers": blockNumbers[start:end],
+		}
+		request.ExpectReturnCustomSignal(signal)
+		requests = append(requests, request)
+	}
+	signal.Add(len(requests))
+	for _, request := range requests {
+		s.Dispatch("GetBlock", "get_blocks_batch", request)
+	}
+	signal.Wait()
+	for _, request := range requests {
+		results = append(results, request.ReturnResult().(*GetBlocksResult).Data...)
+	}
+	return results
+}
+
 type GetBlocksOptions struct {
 	MaxRetries int
 }
 
 type GetBlocksResult struct {
 	Data []*GetBlockResult
+	// Wall time to fetch all blocks. Only set by commands fetching multiple blocks.
+	Elapsed time.Duration
 }
@@ -0,0 +1,110 @@
+package svc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+	"github.com/tforce-io/tf-golib/opx"
+)
+
+type GetReceipt struct {
+	multiplex.ServiceCore
+	i   *multiplex.ServiceCoreInternal
+	o   *NetworkOptions
+	rpc *rpc.EthPool
+}
+
+func NewGetReceipt(logger diag.Logger, rpc *rpc.EthPool, retry *config.RetryPolicyConfig) *GetReceipt {
+	svc := &GetReceipt{
+		rpc: rpc,
+	}
+	svc.i = svc.InitServiceCore("GetReceipt", logger, svc.coreProcessHook)
+	svc.o = NewNetworkOptions(retry)
+	return svc
+}
+
+func (s *GetReceipt) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "get_block_receipts":
+		block := msg.GetParam("block", &rpc.Block{}).(*rpc.Block)
+		blockNumber := block.Number.BigInt()
+		var receipts []*rpc.Receipt
+		var issues []*db.Issue
+		retryCount, retryTime, err := s.o.Retry(func() error {
+			var err error
+			receipts, issues, err = s.getBlockReceipts(block)
+			return err
+		}, func(err error) {
+			s.i.Logger.Warnf("%s#%02d: Receipts of block #%d retrying. %s: %v", s.i.ServiceID, workerID, blockNumber.Uint64(), rpc.ErrorClassOf(err), err)
+		})
+		result := &GetReceiptsResult{
+			Number:     blockNumber,
+			Data:       receipts,
+			Issues:     issues,
+			Error:      err,
+			ErrorClass: rpc.ErrorClassOf(err),
+		}
+		s.i.Logger.Infof("%s#%02d: Receipts of block #%d processed. %s. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID, blockNumber.Uint64(),
+			opx.Ternary(err == nil, "SUCCESS", "FAILED "+string(result.ErrorClass)),
+			retryCount,
+			retryTime.Round(time.Millisecond),
+		)
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%02d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Fetch receipts of all transactions of the block in one JSON-RPC batch.
+// A receipt of another block number means the transaction hash is duplicated in an earlier block, so the receipt
+// is skipped and an issue is returned instead. A receipt of the same block number with another hash means
+// the block has been reorganized since it was fetched.
+func (s *GetReceipt) getBlockReceipts(block *rpc.Block) ([]*rpc.Receipt, []*db.Issue, error) {
+	if len(block.Transactions) == 0 {
+		return []*rpc.Receipt{}, []*db.Issue{}, nil
+	}
+	txHashes := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txHashes[i] = tx.Hash.Hex0x()
+	}
+	receipts, _, errs, err := s.rpc.GetTransactionReceipts(txHashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	blockReceipts := []*rpc.Receipt{}
+	issues := []*db.Issue{}
+	for i, receipt := range receipts {
+		if errs[i] != nil {
+			return nil, nil, errs[i]
+		}
+		receiptBlockHash := hex.EncodeToString(receipt.BlockHash.Bytes())
+		if receiptBlockHash == block.Hash.Hex() {
+			blockReceipts = append(blockReceipts, receipt)
+			continue
+		}
+		if receipt.BlockNumber.Int() == block.Number.Int() {
+			return nil, nil, fmt.Errorf("receipt of transaction %s belongs to block 0x%s instead of %s", txHashes[i], receiptBlockHash, block.Hash.Hex0x())
+		}
+		issues = append(issues, db.NewDuplicatedTxHashIssue(block.Transactions[i].Hash.Hex(), block.Number.Int(), block.Hash.Hex(),
+			receipt.BlockNumber.Int(), receiptBlockHash))
+	}
+	return blockReceipts, issues, nil
+}
+
+type GetReceiptsResult struct {
+	Number *big.Int
+	Data   []*rpc.Receipt
+	// Issues of transactions whose receipts belong to another block.
+	Issues     []*db.Issue
+	Error      error
+	ErrorClass rpc.ErrorClass
+}
@@ -0,0 +1,160 @@
+package svc
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gurukami/typ"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+	"github.com/tforce-io/tf-golib/opx"
+)
+
+// Gas limit of metadata calls. Well-behaved getters use a small fraction of it.
+const TokenCallGasLimit = 200000
+
+var (
+	tokenNameSelector     = common.FromHex("0x06fdde03") // name()
+	tokenSymbolSelector   = common.FromHex("0x95d89b41") // symbol()
+	tokenDecimalsSelector = common.FromHex("0x313ce567") // decimals()
+)
+
+type GetToken struct {
+	multiplex.ServiceCore
+	i   *multiplex.ServiceCoreInternal
+	o   *NetworkOptions
+	rpc *rpc.EthPool
+}
+
+func NewGetToken(logger diag.Logger, rpc *rpc.EthPool, retry *config.RetryPolicyConfig) *GetToken {
+	svc := &GetToken{
+		rpc: rpc,
+	}
+	svc.i = svc.InitServiceCore("GetToken", logger, svc.coreProcessHook)
+	svc.o = NewNetworkOptions(retry)
+	return svc
+}
+
+func (s *GetToken) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "get_token_metadata":
+		address := msg.GetParam("address", "").(string)
+		var metadata *TokenMetadata
+		retryCount, retryTime, err := s.o.Retry(func() error {
+			var err error
+			metadata, err = s.getTokenMetadata(common.HexToAddress(address))
+			return err
+		}, func(err error) {
+			s.i.Logger.Warnf("%s#%02d: Metadata of token 0x%s retrying. %s: %v", s.i.ServiceID, workerID, address, rpc.ErrorClassOf(err), err)
+		})
+		result := &GetTokenResult{
+			Address:    address,
+			Data:       metadata,
+			Error:      err,
+			ErrorClass: rpc.ErrorClassOf(err),
+		}
+		s.i.Logger.Infof("%s#%02d: Metadata of token 0x%s processed. %s. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID, address,
+			opx.Ternary(err == nil, "SUCCESS", "FAILED "+string(result.ErrorClass)),
+			retryCount,
+			retryTime.Round(time.Millisecond),
+		)
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%02d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Call name(), symbol() and decimals() of the token. Getters which revert or return malformed data are
+// left empty since they are optional in most token standards. Only transient errors are returned.
+func (s *GetToken) getTokenMetadata(address common.Address) (*TokenMetadata, error) {
+	metadata := &TokenMetadata{}
+	name, err := s.call(address, tokenNameSelector)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Name = DecodeAbiString(name)
+	symbol, err := s.call(address, tokenSymbolSelector)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Symbol = DecodeAbiString(symbol)
+	decimals, err := s.call(address, tokenDecimalsSelector)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Decimals = DecodeAbiUint8(decimals)
+	return metadata, nil
+}
+
+func (s *GetToken) call(address common.Address, data []byte) ([]byte, error) {
+	result, err := s.rpc.StaticCall(&address, data, TokenCallGasLimit, common.Address{})
+	if err != nil {
+		if rpc.ErrorClassOf(err).IsTransient() {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Decode return data of a string getter. Some early tokens return bytes32 instead of string,
+// in that case trailing zero bytes are trimmed. Return empty string if data is malformed or not valid UTF-8.
+func DecodeAbiString(data []byte) string {
+	if len(data) == 32 {
+		return sanitizeTokenString(bytes.TrimRight(data, "\x00"))
+	}
+	if len(data) < 64 {
+		return ""
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data)-32) {
+		return ""
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data))-start {
+		return ""
+	}
+	return sanitizeTokenString(data[start : start+length.Uint64()])
+}
+
+// Decode return data of decimals(). Return null if data is malformed or the value does not fit in uint8.
+func DecodeAbiUint8(data []byte) typ.NullUint16 {
+	if len(data) < 32 {
+		return typ.NullUint16{}
+	}
+	value := new(big.Int).SetBytes(data[:32])
+	if !value.IsUint64() || value.Uint64() > 255 {
+		return typ.NullUint16{}
+	}
+	return typ.NUint16(uint16(value.Uint64()))
+}
+
+// PostgreSQL text columns reject NUL characters, so strings are trimmed and dropped if not valid UTF-8.
+func sanitizeTokenString(data []byte) string {
+	if !utf8.Valid(data) {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", ""))
+}
+
+type TokenMetadata struct {
+	Name     string
+	Symbol   string
+	Decimals typ.NullUint16
+}
+
+type GetTokenResult struct {
+	Address    string
+	Data       *TokenMetadata
+	Error      error
+	ErrorClass rpc.ErrorClass
+}
@@ -0,0 +1,605 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Maximum number of blocks to walk back while looking for common ancestor.
+const MaxReorgDepth = 900
+
+// Maximum number of times a batch not linking to the canonical stored tip is fetched again.
+const MaxStaleBatchRetries = 3
+
+// Time to wait before a stale batch is fetched again.
+var staleBatchDelay = BlockPeriod * time.Second
+
+type IndexBlock struct {
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+}
+
+func NewIndexBlock(logger diag.Logger) *IndexBlock {
+	svc := &IndexBlock{}
+	svc.i = svc.InitServiceCore("IndexBlock", logger, svc.coreProcessHook)
+	return svc
+}
+
+func (s *IndexBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "index_blocks":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		includeTxs := msg.GetParam("include_txs", true).(bool)
+		includeReceipts := msg.GetParam("include_receipts", false).(bool)
+		result := &IndexBlocksResult{}
+		if toBlockNumber.Sign() == 0 {
+			toBlockNumber, result.Error = s.getBlockNumber()
+		}
+		if result.Error == nil {
+			result = s.indexBlocks(workerID, fromBlockNumber, toBlockNumber, batchSize, forced, includeTxs, includeReceipts, !forced, "index", s.getBlocks)
+		}
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Block indexing stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "index_traces":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		result := s.indexTraces(workerID, fromBlockNumber, toBlockNumber, batchSize, forced)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Trace indexing stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "index_receipts":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		result := s.indexReceiptsRange(workerID, fromBlockNumber, toBlockNumber, batchSize, forced)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Receipt indexing stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "index_tokens":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		result := s.indexTokens(workerID, fromBlockNumber, toBlockNumber, batchSize, forced)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Token indexing stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "index_validators":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		result := s.indexValidators(workerID, fromBlockNumber, toBlockNumber, batchSize, forced)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Validator indexing stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "import_blocks":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		forced := msg.GetParam("forced", false).(bool)
+		includeTxs := msg.GetParam("include_txs", true).(bool)
+		root := msg.GetParam("root", "").(string)
+		readBlocks := func(from, to *big.Int) *GetBlocksResult {
+			return s.readBlocks(from, to, root)
+		}
+		result := s.indexBlocks(workerID, fromBlockNumber, toBlockNumber, batchSize, forced, includeTxs, false, false, "import", readBlocks)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Block import stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "rollback_blocks":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		removeIssues := msg.GetParam("remove_issues", false).(bool)
+		dryRun := msg.GetParam("dry_run", true).(bool)
+		result := s.rollbackBlocks(workerID, blockNumber, removeIssues, dryRun)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Rollback stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	case "get_status":
+		checkHead := msg.GetParam("check_head", false).(bool)
+		exact := msg.GetParam("exact", false).(bool)
+		msg.Return(s.getStatus(checkHead, exact))
+	case "find_gaps":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		checkTxs := msg.GetParam("check_txs", true).(bool)
+		fill := msg.GetParam("fill", false).(bool)
+		result := s.findGaps(workerID, fromBlockNumber, toBlockNumber, batchSize, checkTxs, fill)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Gap detection stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Index blocks from `from` to `to` inclusively using blocks returned by `fetchBlocks`.
+// Progress is kept in the checkpoint named `checkpoint`, either index or import. Unless forced, indexing resumes from it.
+// If `includeReceipts` is set, receipts of every batch are written before the index checkpoint is moved.
+// If `followChain` is set, parent hash of every batch is checked against the stored tip to detect reorg.
+func (s *IndexBlock) indexBlocks(workerID uint64, from, to *big.Int, batch int, forced, includeTxs, includeReceipts, followChain bool, checkpoint string, fetchBlocks func(from, to *big.Int) *GetBlocksResult) *IndexBlocksResult {
+	s.i.Logger.Infof("%s#%d: Block indexing started.", s.ServiceID(), workerID)
+	result := &IndexBlocksResult{}
+	if batch < 1 {
+		batch = 1
+	}
+	batchStartBlockNumber := new(big.Int).Set(from)
+	if !forced {
+		checkpointRequest := multiplex.ExecParams{}
+		checkpointRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", fmt.Sprintf("get_highest_%s_block", checkpoint), checkpointRequest)
+		checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+		if checkpointResponse.Error != nil {
+			result.Error = checkpointResponse.Error
+			return result
+		}
+		if checkpointResponse.Number != nil && checkpointResponse.Number.Cmp(batchStartBlockNumber) >= 0 {
+			batchStartBlockNumber = new(big.Int).Add(checkpointResponse.Number, big.NewInt(1))
+			s.i.Logger.Infof("%s#%d: Resume from checkpoint #%d.", s.ServiceID(), workerID, checkpointResponse.Number.Uint64())
+		}
+	}
+	finalBlockNumber := new(big.Int).Set(to)
+	staleRetries := 0
+	for batchStartBlockNumber.Cmp(finalBlockNumber) <= 0 {
+		batchEndBlockNumber := new(big.Int).Add(batchStartBlockNumber, big.NewInt(int64(batch)-1))
+		if batchEndBlockNumber.Cmp(finalBlockNumber) > 0 {
+			batchEndBlockNumber.Set(finalBlockNumber)
+		}
+		getBlocksResponse := fetchBlocks(new(big.Int).Set(batchStartBlockNumber), new(big.Int).Set(batchEndBlockNumber))
+		// Only the leading contiguous blocks are written so the checkpoint never skips a failed block.
+		blocks := []*rpc.Block{}
+		var batchErr error
+		for _, blockResult := range getBlocksResponse.Data {
+			if blockResult.Error != nil {
+				batchErr = fmt.Errorf("block #%d: %w", blockResult.Number.Uint64(), blockResult.Error)
+				break
+			}
+			if blockResult.Data == nil {
+				batchErr = fmt.Errorf("block #%d not found", blockResult.Number.Uint64())
+				break
+			}
+			blocks = append(blocks, blockResult.Data)
+		}
+		if followChain && len(blocks) > 0 {
+			blocks = s.trimUnlinkedBlocks(blocks)
+			ancestor, reverted, err := s.checkParentBlock(workerID, blocks[0])
+			if err != nil {
+				result.Error = err
+				return result
+			}
+			if ancestor != nil {
+				if reverted {
+					result.ReorgCount++
+					staleRetries = 0
+				} else {
+					// Stored tip is still canonical, the fetched batch is stale so fetch it again.
+					staleRetries++
+					if staleRetries > MaxStaleBatchRetries {
+						result.Error = fmt.Errorf("block #%d does not link to canonical parent after %d retries", blocks[0].Number.Int(), MaxStaleBatchRetries)
+						return result
+					}
+					time.Sleep(staleBatchDelay)
+				}
+				batchStartBlockNumber = new(big.Int).Add(ancestor, big.NewInt(1))
+				continue
+			}
+			staleRetries = 0
+		}
+		if len(blocks) > 0 {
+			writeBlocksRequest := multiplex.ExecParams{
+				"blocks":      blocks,
+				"include_txs": includeTxs,
+			}
+			writeBlocksRequest.ExpectReturn()
+			s.Dispatch("WriteDatabase", "write_blocks", writeBlocksRequest)
+			writeBlocksResponse := writeBlocksRequest.WaitForReturn().(*WriteBlocksResult)
+			if writeBlocksResponse.Error != nil {
+				result.Error = writeBlocksResponse.Error
+				return result
+			}
+			if includeReceipts {
+				receiptCount, err := s.indexReceipts(blocks)
+				if err != nil {
+					result.Error = err
+					return result
+				}
+				result.ReceiptCount += receiptCount
+			}
+			lastBlockNumber := blocks[len(blocks)-1].Number.BigInt()
+			checkpointRequest := multiplex.ExecParams{
+				"block_number": new(big.Int).Set(lastBlockNumber),
+			}
+			checkpointRequest.ExpectReturn()
+			s.Dispatch("WriteDatabase", fmt.Sprintf("save_highest_%s_block", checkpoint), checkpointRequest)
+			checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+			if checkpointResponse.Error != nil {
+				result.Error = checkpointResponse.Error
+				return result
+			}
+			if result.From == nil {
+				result.From = new(big.Int).Set(blocks[0].Number.BigInt())
+			}
+			result.To = new(big.Int).Set(lastBlockNumber)
+			result.BlockCount += len(blocks)
+			s.i.Logger.Infof("%s#%d: Block #%d to #%d indexed. Transaction count = %d.", s.ServiceID(), workerID,
+				blocks[0].Number.Int(), lastBlockNumber.Uint64(), writeBlocksResponse.TxCount)
+			batchStartBlockNumber = new(big.Int).Add(lastBlockNumber, big.NewInt(1))
+		}
+		if batchErr != nil {
+			result.Error = batchErr
+			return result
+		}
+	}
+	s.i.Logger.Infof("%s#%d: Block indexing finished. Block count = %d.", s.ServiceID(), workerID, result.BlockCount)
+	return result
+}
+
+// Find blocks missing from database and blocks with missing transactions between from and to inclusively.
+// If to is zero, the index checkpoint is used. If fill is set, these blocks are fetched and written again
+// without moving the checkpoint.
+func (s *IndexBlock) findGaps(workerID uint64, from, to *big.Int, batch int, checkTxs, fill bool) *GapsResult {
+	result := &GapsResult{
+		From: new(big.Int).Set(from),
+		To:   new(big.Int).Set(to),
+	}
+	if result.To.Sign() == 0 {
+		checkpointRequest := multiplex.ExecParams{}
+		checkpointRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_highest_index_block", checkpointRequest)
+		checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+		if checkpointResponse.Error != nil {
+			result.Error = checkpointResponse.Error
+			return result
+		}
+		if checkpointResponse.Number == nil {
+			result.Error = errors.New("no index checkpoint found")
+			return result
+		}
+		result.To.Set(checkpointResponse.Number)
+	}
+	s.i.Logger.Infof("%s#%d: Gap detection started. From #%d to #%d.", s.ServiceID(), workerID, result.From.Uint64(), result.To.Uint64())
+	gapsRequest := multiplex.ExecParams{
+		"from_block_number": result.From,
+		"to_block_number":   result.To,
+		"check_txs":         checkTxs,
+	}
+	gapsRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_block_gaps", gapsRequest)
+	gapsResponse := gapsRequest.WaitForReturn().(*BlockGapsResult)
+	if gapsResponse.Error != nil {
+		result.Error = gapsResponse.Error
+		return result
+	}
+	result.Gaps = gapsResponse.Gaps
+	result.Mismatches = gapsResponse.Mismatches
+	if !fill {
+		return result
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	mismatchNumbers := make([]uint64, len(result.Mismatches))
+	for i, mismatch := range result.Mismatches {
+		mismatchNumbers[i] = mismatch.BlockNumber
+	}
+	fillRanges := db.MergeBlockRanges(append(slices.Clone(result.Gaps), db.CompactBlockNumbers(mismatchNumbers)...))
+	for _, fillRange := range fillRanges {
+		for batchStart := fillRange.From; batchStart <= fillRange.To; batchStart += uint64(batch) {
+			batchEnd := min(batchStart+uint64(batch)-1, fillRange.To)
+			getBlocksResponse := s.getBlocks(new(big.Int).SetUint64(batchStart), new(big.Int).SetUint64(batchEnd))
+			blocks := []*rpc.Block{}
+			for _, blockResult := range getBlocksResponse.Data {
+				if blockResult.Error != nil {
+					s.i.Logger.Warnf("%s#%d: Block #%d cannot be filled. %v", s.ServiceID(), workerID, blockResult.Number.Uint64(), blockResult.Error)
+					result.ErrorCount++
+					continue
+				}
+				blocks = append(blocks, blockResult.Data)
+			}
+			if len(blocks) == 0 {
+				continue
+			}
+			writeBlocksRequest := multiplex.ExecParams{
+				"blocks":      blocks,
+				"include_txs": true,
+			}
+			writeBlocksRequest.ExpectReturn()
+			s.Dispatch("WriteDatabase", "write_blocks", writeBlocksRequest)
+			writeBlocksResponse := writeBlocksRequest.WaitForReturn().(*WriteBlocksResult)
+			if writeBlocksResponse.Error != nil {
+				result.Error = writeBlocksResponse.Error
+				return result
+			}
+			result.FilledCount += len(blocks)
+			s.i.Logger.Infof("%s#%d: Block #%d to #%d filled. Transaction count = %d.", s.ServiceID(), workerID,
+				batchStart, batchEnd, writeBlocksResponse.TxCount)
+		}
+	}
+	return result
+}
+
+// Return leading blocks that link to each other. The rest will be checked against stored tip in next batch.
+func (s *IndexBlock) trimUnlinkedBlocks(blocks []*rpc.Block) []*rpc.Block {
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].ParentHash.Hex() != blocks[i-1].Hash.Hex() {
+			return blocks[:i]
+		}
+	}
+	return blocks
+}
+
+// Compare parent hash of the block with the stored one. If they mismatch, stored blocks are reverted
+// to the common ancestor and its number is returned. Reverted is unset when the stored parent is still canonical,
+// meaning the block itself is stale and must be fetched again.
+func (s *IndexBlock) checkParentBlock(workerID uint64, block *rpc.Block) (*big.Int, bool, error) {
+	if block.Number.BigInt().Sign() == 0 {
+		return nil, false, nil
+	}
+	parentNumber := new(big.Int).Sub(block.Number.BigInt(), big.NewInt(1))
+	parent, err := s.getStoredBlock(parentNumber)
+	if err != nil {
+		return nil, false, err
+	}
+	if parent == nil || parent.Hash == block.ParentHash.Hex() {
+		return nil, false, nil
+	}
+	s.i.Logger.Warnf("%s#%d: Block #%d does not link to stored parent %s.", s.ServiceID(), workerID, block.Number.Int(), parent.Hash)
+	return s.revertToCommonAncestor(workerID, parentNumber)
+}
+
+func (s *IndexBlock) revertToCommonAncestor(workerID uint64, tipNumber *big.Int) (*big.Int, bool, error) {
+	var ancestorNumber *big.Int
+	forkNumber := new(big.Int).Set(tipNumber)
+	orphanHash, canonicalHash := "", ""
+	for depth := 0; depth <= MaxReorgDepth && tipNumber.Cmp(big.NewInt(int64(depth))) >= 0; depth++ {
+		blockNumber := new(big.Int).Sub(tipNumber, big.NewInt(int64(depth)))
+		storedBlock, err := s.getStoredBlock(blockNumber)
+		if err != nil {
+			return nil, false, err
+		}
+		if storedBlock == nil {
+			ancestorNumber = blockNumber
+			break
+		}
+		blockResponse := s.getBlock(blockNumber)
+		if blockResponse.Error != nil {
+			return nil, false, blockResponse.Error
+		}
+		if blockResponse.Data == nil {
+			return nil, false, fmt.Errorf("block #%d not found", blockNumber.Uint64())
+		}
+		if blockResponse.Data.Hash.Hex() == storedBlock.Hash {
+			ancestorNumber = blockNumber
+			break
+		}
+		forkNumber = blockNumber
+		orphanHash = storedBlock.Hash
+		canonicalHash = blockResponse.Data.Hash.Hex()
+	}
+	if ancestorNumber == nil {
+		return nil, false, fmt.Errorf("common ancestor of block #%d not found within %d blocks", tipNumber.Uint64(), MaxReorgDepth)
+	}
+	depth := new(big.Int).Sub(tipNumber, ancestorNumber).Uint64()
+	if depth == 0 {
+		s.i.Logger.Warnf("%s#%d: Stored block #%d is canonical. Block #%d will be fetched again.", s.ServiceID(), workerID,
+			tipNumber.Uint64(), tipNumber.Uint64()+1)
+		return ancestorNumber, false, nil
+	}
+	issue := db.NewChainReorgIssue(forkNumber.Uint64(), canonicalHash, orphanHash, depth)
+	revertRequest := multiplex.ExecParams{
+		"block_number": ancestorNumber,
+		"issues":       []*db.Issue{issue},
+	}
+	revertRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "revert_blocks", revertRequest)
+	revertResponse := revertRequest.WaitForReturn().(*CheckpointResult)
+	if revertResponse.Error != nil {
+		return nil, false, revertResponse.Error
+	}
+	s.i.Logger.Warnf("%s#%d: Reorg detected at block #%d. Depth = %d. Reverted to block #%d.", s.ServiceID(), workerID,
+		forkNumber.Uint64(), depth, ancestorNumber.Uint64())
+	return ancestorNumber, true, nil
+}
+
+func (s *IndexBlock) getStoredBlock(blockNumber *big.Int) (*db.Block, error) {
+	blockRequest := multiplex.ExecParams{
+		"block_number": blockNumber,
+	}
+	blockRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_block", blockRequest)
+	blockResponse := blockRequest.WaitForReturn().(*DbBlockResult)
+	return blockResponse.Data, blockResponse.Error
+}
+
+func (s *IndexBlock) getBlock(blockNumber *big.Int) *GetBlockResult {
+	blockRequest := multiplex.ExecParams{
+		"block_number": blockNumber,
+	}
+	blockRequest.ExpectReturn()
+	s.Dispatch("GetBlock", "get_block", blockRequest)
+	return blockRequest.WaitForReturn().(*GetBlockResult)
+}
+
+// Delete blocks and transactions above `number` and lower both checkpoints to `number`.
+// Data to be deleted is always summarized first. Nothing is deleted if `dryRun` is set.
+func (s *IndexBlock) rollbackBlocks(workerID uint64, number *big.Int, removeIssues, dryRun bool) *RollbackResult {
+	summaryRequest := multiplex.ExecParams{
+		"block_number": number,
+	}
+	summaryRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_rollback_summary", summaryRequest)
+	result := summaryRequest.WaitForReturn().(*RollbackResult)
+	result.RemoveIssues = removeIssues
+	result.DryRun = dryRun
+	if result.Error != nil {
+		return result
+	}
+	checkpoints := map[string]**big.Int{
+		"get_highest_index_block":     &result.IndexCheckpoint,
+		"get_highest_trace_block":     &result.TraceCheckpoint,
+		"get_highest_receipt_block":   &result.ReceiptCheckpoint,
+		"get_highest_token_block":     &result.TokenCheckpoint,
+		"get_highest_validator_block": &result.ValidatorCheckpoint,
+	}
+	for command, checkpoint := range checkpoints {
+		*checkpoint, result.Error = s.getCheckpoint(command)
+		if result.Error != nil {
+			return result
+		}
+	}
+	if dryRun {
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: Rolling back to block #%d.", s.ServiceID(), workerID, number.Uint64())
+	rollbackRequest := multiplex.ExecParams{
+		"block_number":  number,
+		"remove_issues": removeIssues,
+	}
+	rollbackRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "rollback_blocks", rollbackRequest)
+	rollbackResponse := rollbackRequest.WaitForReturn().(*CheckpointResult)
+	result.Error = rollbackResponse.Error
+	return result
+}
+
+// Collect database status. If `checkHead` is set, lag of checkpoints behind the node head is also calculated.
+// Failure to get the head is reported in HeadError and does not fail the status.
+// Row counts are estimated unless `exact` is set.
+func (s *IndexBlock) getStatus(checkHead, exact bool) *StatusResult {
+	statusRequest := multiplex.ExecParams{
+		"exact": exact,
+	}
+	statusRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_database_status", statusRequest)
+	statusResponse := statusRequest.WaitForReturn().(*DatabaseStatusResult)
+	result := &StatusResult{
+		DatabaseStatusResult: statusResponse,
+	}
+	if statusResponse.Error != nil || !checkHead {
+		return result
+	}
+	result.Head, result.HeadError = s.getBlockNumber()
+	if result.HeadError != nil {
+		result.Head = nil
+		return result
+	}
+	if result.IndexCheckpoint != nil {
+		result.IndexLag = new(big.Int).Sub(result.Head, result.IndexCheckpoint)
+	}
+	if result.TraceCheckpoint != nil {
+		result.TraceLag = new(big.Int).Sub(result.Head, result.TraceCheckpoint)
+	}
+	if result.ReceiptCheckpoint != nil {
+		result.ReceiptLag = new(big.Int).Sub(result.Head, result.ReceiptCheckpoint)
+	}
+	if result.TokenCheckpoint != nil {
+		result.TokenLag = new(big.Int).Sub(result.Head, result.TokenCheckpoint)
+	}
+	if result.ValidatorCheckpoint != nil {
+		result.ValidatorLag = new(big.Int).Sub(result.Head, result.ValidatorCheckpoint)
+	}
+	return result
+}
+
+func (s *IndexBlock) getBlockNumber() (*big.Int, error) {
+	blockNumberRequest := multiplex.ExecParams{}
+	blockNumberRequest.ExpectReturn()
+	s.Dispatch("GetBlock", "get_block_number", blockNumberRequest)
+	blockNumberResponse := blockNumberRequest.WaitForReturn().(*GetBlockNumberResult)
+	return blockNumberResponse.Number, blockNumberResponse.Error
+}
+
+func (s *IndexBlock) getBlocks(from, to *big.Int) *GetBlocksResult {
+	getBlocksRequest := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+	}
+	getBlocksRequest.ExpectReturn()
+	s.Dispatch("GetBlocks", "get_blocks_range", getBlocksRequest)
+	return getBlocksRequest.WaitForReturn().(*GetBlocksResult)
+}
+
+func (s *IndexBlock) readBlocks(from, to *big.Int, root string) *GetBlocksResult {
+	readBlocksRequest := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"root":              root,
+	}
+	readBlocksRequest.ExpectReturn()
+	s.Dispatch("ReadFileSystem", "eth_getBlockByNumber", readBlocksRequest)
+	return readBlocksRequest.WaitForReturn().(*GetBlocksResult)
+}
+
+type GapsResult struct {
+	From        *big.Int
+	To          *big.Int
+	Gaps        []*db.BlockRange
+	Mismatches  []*db.TransactionCountMismatch
+	FilledCount int
+	ErrorCount  int
+	Error       error
+}
+
+type RollbackResult struct {
+	Number  *big.Int
+	Summary *db.RollbackSummary
+	// Checkpoints before rollback. Nil if not set.
+	IndexCheckpoint     *big.Int
+	TraceCheckpoint     *big.Int
+	ReceiptCheckpoint   *big.Int
+	TokenCheckpoint     *big.Int
+	ValidatorCheckpoint *big.Int
+	RemoveIssues        bool
+	DryRun              bool
+	Error               error
+}
+
+type StatusResult struct {
+	*DatabaseStatusResult
+	Head         *big.Int
+	HeadError    error
+	IndexLag     *big.Int
+	TraceLag     *big.Int
+	ReceiptLag   *big.Int
+	TokenLag     *big.Int
+	ValidatorLag *big.Int
+}
+
+type IndexBlocksResult struct {
+	From            *big.Int
+	To              *big.Int
+	BlockCount      int
+	ReorgCount      int
+	InternalTxCount int
+	ReceiptCount    int
+	TransferCount   int
+	TokenCount      int
+	MissedSlotCount int
+	Error           error
+}
@@ -0,0 +1,271 @@
+package svc
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Service answering every command with handle.
+type stubService struct {
+	multiplex.ServiceCore
+	handle func(msg *multiplex.ServiceMessage) interface{}
+}
+
+func newStubService(serviceID string, router *multiplex.ServiceController, handle func(msg *multiplex.ServiceMessage) interface{}) *stubService {
+	svc := &stubService{handle: handle}
+	svc.InitServiceCore(serviceID, diag.NewDebugLogger(10), svc.coreProcessHook)
+	svc.SetRouter(router)
+	svc.SetWorker(1)
+	router.Register(svc)
+	return svc
+}
+
+func (s *stubService) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	msg.Return(s.handle(msg))
+	return &multiplex.HookState{Handled: true}
+}
+
+// Stored blocks and blocks served by RPC, keyed by number. Fork 0 is canonical.
+type testChain struct {
+	lock      sync.Mutex
+	stored    map[uint64]string
+	canonical map[uint64]string
+	reverts   []uint64
+	issues    []*db.Issue
+	written   []uint64
+}
+
+func testBlockHash(number uint64, fork int) string {
+	return fmt.Sprintf("%02x%062x", fork, number)
+}
+
+func newTestChainBlock(t *testing.T, number uint64, hash, parentHash string) *rpc.Block {
+	rawBlock := fmt.Sprintf(`{"number":"0x%x","hash":"0x%s","parentHash":"0x%s"}`, number, hash, parentHash)
+	block, err := rpc.DecodeBlock([]byte(rawBlock))
+	if err != nil {
+		t.Fatalf("Error while decoding block. %v", err)
+	}
+	return block
+}
+
+// Store blocks from 0 to tip. Blocks from forkNumber are stored on fork 1 while RPC serves fork 0.
+func newTestChain(tip, forkNumber uint64) *testChain {
+	chain := &testChain{
+		stored:    map[uint64]string{},
+		canonical: map[uint64]string{},
+	}
+	for number := uint64(0); number <= tip+1; number++ {
+		chain.canonical[number] = testBlockHash(number, 0)
+		if number > tip {
+			continue
+		}
+		if number >= forkNumber {
+			chain.stored[number] = testBlockHash(number, 1)
+		} else {
+			chain.stored[number] = testBlockHash(number, 0)
+		}
+	}
+	return chain
+}
+
+func newTestIndexBlock(t *testing.T, chain *testChain) *IndexBlock {
+	router := multiplex.NewServiceController(diag.NewDebugLogger(10))
+	router.Run(false)
+	newStubService("ReadDatabase", router, func(msg *multiplex.ServiceMessage) interface{} {
+		chain.lock.Lock()
+		defer chain.lock.Unlock()
+		switch msg.Command {
+		case "get_block":
+			number := msg.GetParam("block_number", new(big.Int)).(*big.Int).Uint64()
+			result := &DbBlockResult{Number: new(big.Int).SetUint64(number)}
+			if hash, ok := chain.stored[number]; ok {
+				result.Data = &db.Block{ID: number, Hash: hash}
+			}
+			return result
+		}
+		t.Errorf("Unexpected ReadDatabase command %s.", msg.Command)
+		return nil
+	})
+	newStubService("WriteDatabase", router, func(msg *multiplex.ServiceMessage) interface{} {
+		chain.lock.Lock()
+		defer chain.lock.Unlock()
+		switch msg.Command {
+		case "revert_blocks":
+			number := msg.GetParam("block_number", new(big.Int)).(*big.Int).Uint64()
+			for blockNumber := range chain.stored {
+				if blockNumber > number {
+					delete(chain.stored, blockNumber)
+				}
+			}
+			chain.reverts = append(chain.reverts, number)
+			chain.issues = append(chain.issues, msg.GetParam("issues", []*db.Issue{}).([]*db.Issue)...)
+			return &CheckpointResult{Number: new(big.Int).SetUint64(number)}
+		case "write_blocks":
+			for _, block := range msg.GetParam("blocks", []*rpc.Block{}).([]*rpc.Block) {
+				chain.stored[block.Number.Int()] = block.Hash.Hex()
+				chain.written = append(chain.written, block.Number.Int())
+			}
+			return &WriteBlocksResult{}
+		case "save_highest_index_block":
+			return &CheckpointResult{}
+		}
+		t.Errorf("Unexpected WriteDatabase command %s.", msg.Command)
+		return nil
+	})
+	newStubService("GetBlock", router, func(msg *multiplex.ServiceMessage) interface{} {
+		chain.lock.Lock()
+		defer chain.lock.Unlock()
+		number := msg.GetParam("block_number", new(big.Int)).(*big.Int).Uint64()
+		result := &GetBlockResult{Number: new(big.Int).SetUint64(number)}
+		if hash, ok := chain.canonical[number]; ok {
+			result.Data = newTestChainBlock(t, number, hash, chain.canonical[number-1])
+		}
+		return result
+	})
+	svc := NewIndexBlock(diag.NewDebugLogger(10))
+	svc.SetRouter(router)
+	return svc
+}
+
+func TestTrimUnlinkedBlocks(t *testing.T) {
+	a := newTestChainBlock(t, 10, testBlockHash(10, 0), testBlockHash(9, 0))
+	b := newTestChainBlock(t, 11, testBlockHash(11, 0), testBlockHash(10, 0))
+	c := newTestChainBlock(t, 12, testBlockHash(12, 0), testBlockHash(11, 1))
+	d := newTestChainBlock(t, 13, testBlockHash(13, 0), testBlockHash(12, 0))
+	tests := []struct {
+		name     string
+		blocks   []*rpc.Block
+		expected int
+	}{
+		{"empty", []*rpc.Block{}, 0},
+		{"single", []*rpc.Block{a}, 1},
+		{"linked", []*rpc.Block{a, b}, 2},
+		{"unlinked", []*rpc.Block{a, b, c, d}, 2},
+	}
+	for _, test := range tests {
+		if actual := (&IndexBlock{}).trimUnlinkedBlocks(test.blocks); len(actual) != test.expected {
+			t.Errorf("%s: expected %d blocks, got %d.", test.name, test.expected, len(actual))
+		}
+	}
+}
+
+func TestCheckParentBlock(t *testing.T) {
+	tests := []struct {
+		name       string
+		tip        uint64
+		forkNumber uint64
+		parentFork int
+		ancestor   int64
+		reverted   bool
+	}{
+		{"linked", 105, 106, 0, -1, false},
+		{"depth_1", 105, 105, 0, 104, true},
+		{"depth_3", 105, 103, 0, 102, true},
+		{"stale_tip", 105, 106, 2, 105, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := newTestChain(test.tip, test.forkNumber)
+			svc := newTestIndexBlock(t, chain)
+			block := newTestChainBlock(t, test.tip+1, testBlockHash(test.tip+1, test.parentFork), testBlockHash(test.tip, test.parentFork))
+			ancestor, reverted, err := svc.checkParentBlock(1, block)
+			if err != nil {
+				t.Fatalf("Error while checking parent block. %v", err)
+			}
+			if test.ancestor < 0 {
+				if ancestor != nil || reverted {
+					t.Fatalf("Expected no revert, got ancestor %v.", ancestor)
+				}
+				return
+			}
+			if ancestor == nil || ancestor.Int64() != test.ancestor || reverted != test.reverted {
+				t.Fatalf("Ancestor mismatch. Expected '%d' reverted '%t' Actual '%v' reverted '%t'", test.ancestor, test.reverted, ancestor, reverted)
+			}
+			if !test.reverted {
+				if len(chain.reverts) != 0 || len(chain.stored) != int(test.tip)+1 {
+					t.Fatalf("Stored blocks must be kept when tip is canonical. Reverts %v", chain.reverts)
+				}
+				return
+			}
+			if len(chain.reverts) != 1 || chain.reverts[0] != uint64(test.ancestor) {
+				t.Fatalf("Revert mismatch. Expected '%d' Actual '%v'", test.ancestor, chain.reverts)
+			}
+			if len(chain.stored) != int(test.ancestor)+1 {
+				t.Fatalf("Expected blocks above #%d to be reverted, %d blocks left.", test.ancestor, len(chain.stored))
+			}
+			depth := test.tip - uint64(test.ancestor)
+			if len(chain.issues) != 1 || chain.issues[0].BlockNumber != test.forkNumber ||
+				chain.issues[0].Extras["depth"] != depth || chain.issues[0].Extras["prev_block_hash"] != testBlockHash(test.forkNumber, 1) {
+				t.Fatalf("Unexpected reorg issue %+v.", chain.issues)
+			}
+		})
+	}
+}
+
+func TestRevertToCommonAncestorMaxDepth(t *testing.T) {
+	tip := uint64(MaxReorgDepth + 10)
+	chain := newTestChain(tip, 5)
+	svc := newTestIndexBlock(t, chain)
+	_, reverted, err := svc.revertToCommonAncestor(1, new(big.Int).SetUint64(tip))
+	if err == nil || reverted {
+		t.Fatalf("Expected error when common ancestor is deeper than %d blocks.", MaxReorgDepth)
+	}
+	if len(chain.reverts) != 0 || len(chain.stored) != int(tip)+1 {
+		t.Fatalf("Stored blocks must be kept when common ancestor is not found. Reverts %v", chain.reverts)
+	}
+}
+
+func TestIndexBlocksFollowChain(t *testing.T) {
+	staleBatchDelay = 0
+	tests := []struct {
+		name       string
+		forkNumber uint64
+		staleCount int
+		fetchCount int
+		reorgCount int
+		written    []uint64
+		failed     bool
+	}{
+		{"reorg", 104, 0, 2, 1, []uint64{104, 105, 106}, false},
+		{"stale_batch", 106, 2, 3, 0, []uint64{106}, false},
+		{"stale_batch_exhausted", 106, MaxStaleBatchRetries + 1, MaxStaleBatchRetries + 1, 0, []uint64{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := newTestChain(105, test.forkNumber)
+			svc := newTestIndexBlock(t, chain)
+			fetchCount := 0
+			fetchBlocks := func(from, to *big.Int) *GetBlocksResult {
+				result := &GetBlocksResult{}
+				for number := from.Uint64(); number <= to.Uint64(); number++ {
+					block := newTestChainBlock(t, number, chain.canonical[number], chain.canonical[number-1])
+					if fetchCount < test.staleCount {
+						block = newTestChainBlock(t, number, testBlockHash(number, 2), testBlockHash(number-1, 2))
+					}
+					result.Data = append(result.Data, &GetBlockResult{Number: new(big.Int).SetUint64(number), Data: block})
+				}
+				fetchCount++
+				return result
+			}
+			result := svc.indexBlocks(1, big.NewInt(106), big.NewInt(106), 3, true, false, false, true, "index", fetchBlocks)
+			if (result.Error != nil) != test.failed {
+				t.Fatalf("Error mismatch. Expected failure '%t' Actual '%v'", test.failed, result.Error)
+			}
+			if fetchCount != test.fetchCount || result.ReorgCount != test.reorgCount || len(chain.written) != len(test.written) {
+				t.Fatalf("Unexpected result %+v. Fetch count %d. Written blocks %v", result, fetchCount, chain.written)
+			}
+			for i, number := range test.written {
+				if chain.written[i] != number || chain.stored[number] != chain.canonical[number] {
+					t.Fatalf("Block #%d mismatch. Written blocks %v", number, chain.written)
+				}
+			}
+		})
+	}
+}
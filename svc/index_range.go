@@ -0,0 +1,84 @@
+package svc
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Resolve range of blocks from `from` to `to` inclusively to index by a mode reading data written by an earlier mode.
+// `to` is capped at the checkpoint returned by `sourceCmd`, and zero `to` means that checkpoint.
+// Unless forced, indexing resumes after the checkpoint returned by `checkpointCmd`.
+// The returned range is empty if `from` is above `to`.
+func (s *IndexBlock) resolveRange(workerID uint64, sourceCmd, checkpointCmd string, from, to *big.Int, forced bool) (*big.Int, *big.Int, error) {
+	sourceCheckpoint, err := s.getCheckpoint(sourceCmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sourceCheckpoint == nil {
+		return nil, nil, fmt.Errorf("no %s checkpoint found, nothing to index yet", checkpointName(sourceCmd))
+	}
+	finalBlockNumber := new(big.Int).Set(to)
+	if finalBlockNumber.Sign() == 0 || finalBlockNumber.Cmp(sourceCheckpoint) > 0 {
+		finalBlockNumber.Set(sourceCheckpoint)
+	}
+	startBlockNumber := new(big.Int).Set(from)
+	if !forced {
+		checkpoint, err := s.getCheckpoint(checkpointCmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		if checkpoint != nil && checkpoint.Cmp(startBlockNumber) >= 0 {
+			startBlockNumber = new(big.Int).Add(checkpoint, big.NewInt(1))
+			s.i.Logger.Infof("%s#%d: Resume from %s checkpoint #%d.", s.ServiceID(), workerID, checkpointName(checkpointCmd), checkpoint.Uint64())
+		}
+	}
+	return startBlockNumber, finalBlockNumber, nil
+}
+
+// Index blocks from `from` to `to` inclusively in batches of `batch` blocks.
+// `indexBatch` returns the last block it indexed, which is lower than the batch end if only the leading contiguous
+// blocks were indexed, so the next batch starts right after it and a checkpoint never skips a failed block.
+// Indexing stops at the first error. Range of indexed blocks and the error are reported in `result`.
+func (s *IndexBlock) indexBatches(result *IndexBlocksResult, from, to *big.Int, batch int, indexBatch func(from, to *big.Int) (*big.Int, error)) {
+	if batch < 1 {
+		batch = 1
+	}
+	batchStartBlockNumber := new(big.Int).Set(from)
+	for batchStartBlockNumber.Cmp(to) <= 0 {
+		batchEndBlockNumber := new(big.Int).Add(batchStartBlockNumber, big.NewInt(int64(batch)-1))
+		if batchEndBlockNumber.Cmp(to) > 0 {
+			batchEndBlockNumber.Set(to)
+		}
+		lastBlockNumber, err := indexBatch(new(big.Int).Set(batchStartBlockNumber), batchEndBlockNumber)
+		if lastBlockNumber != nil {
+			if result.From == nil {
+				result.From = new(big.Int).Set(batchStartBlockNumber)
+			}
+			result.To = new(big.Int).Set(lastBlockNumber)
+			batchStartBlockNumber = new(big.Int).Add(lastBlockNumber, big.NewInt(1))
+		}
+		if err == nil && lastBlockNumber == nil {
+			err = fmt.Errorf("no block of block #%d to #%d indexed", batchStartBlockNumber.Uint64(), batchEndBlockNumber.Uint64())
+		}
+		if err != nil {
+			result.Error = err
+			return
+		}
+	}
+}
+
+func (s *IndexBlock) getCheckpoint(command string) (*big.Int, error) {
+	checkpointRequest := multiplex.ExecParams{}
+	checkpointRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", command, checkpointRequest)
+	checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+	return checkpointResponse.Number, checkpointResponse.Error
+}
+
+// Return name of the checkpoint returned by a get_highest_*_block command, e.g. "trace".
+func checkpointName(command string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(command, "get_highest_"), "_block")
+}
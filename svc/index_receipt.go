@@ -0,0 +1,103 @@
+package svc
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Index receipts and logs of blocks from `from` to `to` inclusively. Blocks must be indexed first.
+func (s *IndexBlock) indexReceiptsRange(workerID uint64, from, to *big.Int, batch int, forced bool) *IndexBlocksResult {
+	s.i.Logger.Infof("%s#%d: Receipt indexing started.", s.ServiceID(), workerID)
+	result := &IndexBlocksResult{}
+	from, to, result.Error = s.resolveRange(workerID, "get_highest_index_block", "get_highest_receipt_block", from, to, forced)
+	if result.Error != nil {
+		return result
+	}
+	s.indexBatches(result, from, to, batch, func(from, to *big.Int) (*big.Int, error) {
+		getBlocksResponse := s.getBlocks(new(big.Int).Set(from), new(big.Int).Set(to))
+		blocks := []*rpc.Block{}
+		var batchErr error
+		for _, blockResult := range getBlocksResponse.Data {
+			if blockResult.Error != nil {
+				batchErr = fmt.Errorf("block #%d: %w", blockResult.Number.Uint64(), blockResult.Error)
+				break
+			}
+			if blockResult.Data == nil {
+				batchErr = fmt.Errorf("block #%d not found", blockResult.Number.Uint64())
+				break
+			}
+			blocks = append(blocks, blockResult.Data)
+		}
+		if len(blocks) == 0 {
+			return nil, batchErr
+		}
+		receiptCount, err := s.indexReceipts(blocks)
+		if err != nil {
+			return nil, err
+		}
+		lastBlockNumber := blocks[len(blocks)-1].Number.BigInt()
+		result.BlockCount += len(blocks)
+		result.ReceiptCount += receiptCount
+		s.i.Logger.Infof("%s#%d: Receipts of block #%d to #%d indexed. Receipt count = %d.", s.ServiceID(), workerID,
+			blocks[0].Number.Int(), lastBlockNumber.Uint64(), receiptCount)
+		return new(big.Int).Set(lastBlockNumber), batchErr
+	})
+	if result.Error != nil {
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: Receipt indexing finished. Block count = %d.", s.ServiceID(), workerID, result.BlockCount)
+	return result
+}
+
+// Fetch and write receipts of all blocks then move the receipt checkpoint to the last block.
+// Nothing is written if receipts of any block cannot be fetched. Return number of receipts written.
+func (s *IndexBlock) indexReceipts(blocks []*rpc.Block) (int, error) {
+	blockReceipts := s.getReceipts(blocks)
+	for _, blockReceipt := range blockReceipts {
+		if blockReceipt.Error != nil {
+			return 0, fmt.Errorf("receipts of block #%d: %w", blockReceipt.Number.Uint64(), blockReceipt.Error)
+		}
+	}
+	writeReceiptsRequest := multiplex.ExecParams{
+		"block_receipts": blockReceipts,
+	}
+	writeReceiptsRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "write_receipts", writeReceiptsRequest)
+	writeReceiptsResponse := writeReceiptsRequest.WaitForReturn().(*WriteBlocksResult)
+	if writeReceiptsResponse.Error != nil {
+		return 0, writeReceiptsResponse.Error
+	}
+	checkpointRequest := multiplex.ExecParams{
+		"block_number": new(big.Int).Set(blocks[len(blocks)-1].Number.BigInt()),
+	}
+	checkpointRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "save_highest_receipt_block", checkpointRequest)
+	checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+	if checkpointResponse.Error != nil {
+		return 0, checkpointResponse.Error
+	}
+	return writeReceiptsResponse.TxCount, nil
+}
+
+func (s *IndexBlock) getReceipts(blocks []*rpc.Block) []*GetReceiptsResult {
+	results := make([]*GetReceiptsResult, len(blocks))
+	requests := make([]multiplex.ExecParams, len(blocks))
+	signal := new(sync.WaitGroup)
+	signal.Add(len(blocks))
+	for i, block := range blocks {
+		requests[i] = multiplex.ExecParams{
+			"block": block,
+		}
+		requests[i].ExpectReturnCustomSignal(signal)
+		s.Dispatch("GetReceipt", "get_block_receipts", requests[i])
+	}
+	signal.Wait()
+	for i, request := range requests {
+		results[i] = request.ReturnResult().(*GetReceiptsResult)
+	}
+	return results
+}
@@ -0,0 +1,260 @@
+package svc
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"viction-rpc-crawler-go/db"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+var (
+	// Transfer(address,address,uint256) of ERC20, TRC20 and VRC25 tokens. ERC721 tokens emit the same event
+	// with the token ID indexed.
+	TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()[2:]
+	// TransferSingle(address,address,address,uint256,uint256) of ERC1155 tokens.
+	TransferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)")).Hex()[2:]
+	// TransferBatch(address,address,address,uint256[],uint256[]) of ERC1155 tokens.
+	TransferBatchTopic = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])")).Hex()[2:]
+
+	TokenTransferTopics = []string{TransferTopic, TransferSingleTopic, TransferBatchTopic}
+)
+
+// Decode token transfers of stored logs from `from` to `to` inclusively and discover metadata of new tokens.
+// Receipts must be indexed first.
+func (s *IndexBlock) indexTokens(workerID uint64, from, to *big.Int, batch int, forced bool) *IndexBlocksResult {
+	s.i.Logger.Infof("%s#%d: Token indexing started.", s.ServiceID(), workerID)
+	result := &IndexBlocksResult{}
+	from, to, result.Error = s.resolveRange(workerID, "get_highest_receipt_block", "get_highest_token_block", from, to, forced)
+	if result.Error != nil {
+		return result
+	}
+	s.indexBatches(result, from, to, batch, func(from, to *big.Int) (*big.Int, error) {
+		transferCount, tokenCount, err := s.indexTokenTransfers(new(big.Int).Set(from), new(big.Int).Set(to))
+		if err != nil {
+			return nil, err
+		}
+		result.BlockCount += int(new(big.Int).Sub(to, from).Int64()) + 1
+		result.TransferCount += transferCount
+		result.TokenCount += tokenCount
+		s.i.Logger.Infof("%s#%d: Token transfers of block #%d to #%d indexed. Transfer count = %d. New token count = %d.", s.ServiceID(), workerID,
+			from.Uint64(), to.Uint64(), transferCount, tokenCount)
+		return to, nil
+	})
+	if result.Error != nil {
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: Token indexing finished. Block count = %d.", s.ServiceID(), workerID, result.BlockCount)
+	return result
+}
+
+// Decode and write token transfers of blocks from `from` to `to` inclusively then move the token checkpoint to `to`.
+// Nothing is written if metadata of any new token cannot be fetched. Return number of transfers and new tokens written.
+func (s *IndexBlock) indexTokenTransfers(from, to *big.Int) (int, int, error) {
+	logsRequest := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+	}
+	logsRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_transfer_logs_range", logsRequest)
+	logsResponse := logsRequest.WaitForReturn().(*DbLogsResult)
+	if logsResponse.Error != nil {
+		return 0, 0, logsResponse.Error
+	}
+	transfers := []*db.TokenTransfer{}
+	tokens := map[string]*db.Token{}
+	addresses := []string{}
+	for _, log := range logsResponse.Data {
+		logTransfers := DecodeTokenTransfers(log)
+		if len(logTransfers) == 0 {
+			continue
+		}
+		transfers = append(transfers, logTransfers...)
+		if _, ok := tokens[log.Address]; !ok {
+			tokens[log.Address] = &db.Token{
+				Address:      log.Address,
+				Standard:     logTransfers[0].Standard,
+				FirstBlockID: log.BlockID,
+			}
+			addresses = append(addresses, log.Address)
+		}
+	}
+	newTokens, err := s.getNewTokens(addresses, tokens)
+	if err != nil {
+		return 0, 0, err
+	}
+	writeRequest := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+		"transfers":         transfers,
+		"tokens":            newTokens,
+	}
+	writeRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "write_token_transfers", writeRequest)
+	writeResponse := writeRequest.WaitForReturn().(*WriteBlocksResult)
+	if writeResponse.Error != nil {
+		return 0, 0, writeResponse.Error
+	}
+	checkpointRequest := multiplex.ExecParams{
+		"block_number": to,
+	}
+	checkpointRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "save_highest_token_block", checkpointRequest)
+	checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+	if checkpointResponse.Error != nil {
+		return 0, 0, checkpointResponse.Error
+	}
+	return len(transfers), len(newTokens), nil
+}
+
+// Return tokens among `addresses` which are not stored yet with their metadata filled.
+func (s *IndexBlock) getNewTokens(addresses []string, tokens map[string]*db.Token) ([]*db.Token, error) {
+	newTokens := []*db.Token{}
+	if len(addresses) == 0 {
+		return newTokens, nil
+	}
+	tokensRequest := multiplex.ExecParams{
+		"addresses": addresses,
+	}
+	tokensRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_tokens", tokensRequest)
+	tokensResponse := tokensRequest.WaitForReturn().(*DbTokensResult)
+	if tokensResponse.Error != nil {
+		return nil, tokensResponse.Error
+	}
+	known := map[string]bool{}
+	for _, token := range tokensResponse.Data {
+		known[token.Address] = true
+	}
+	newAddresses := []string{}
+	for _, address := range addresses {
+		if !known[address] {
+			newAddresses = append(newAddresses, address)
+		}
+	}
+	for _, metadata := range s.getTokenMetadata(newAddresses) {
+		if metadata.Error != nil {
+			return nil, fmt.Errorf("metadata of token 0x%s: %w", metadata.Address, metadata.Error)
+		}
+		token := tokens[metadata.Address]
+		token.Name = metadata.Data.Name
+		token.Symbol = metadata.Data.Symbol
+		token.Decimals = metadata.Data.Decimals
+		newTokens = append(newTokens, token)
+	}
+	return newTokens, nil
+}
+
+func (s *IndexBlock) getTokenMetadata(addresses []string) []*GetTokenResult {
+	results := make([]*GetTokenResult, len(addresses))
+	requests := make([]multiplex.ExecParams, len(addresses))
+	signal := new(sync.WaitGroup)
+	signal.Add(len(addresses))
+	for i, address := range addresses {
+		requests[i] = multiplex.ExecParams{
+			"address": address,
+		}
+		requests[i].ExpectReturnCustomSignal(signal)
+		s.Dispatch("GetToken", "get_token_metadata", requests[i])
+	}
+	signal.Wait()
+	for i, request := range requests {
+		results[i] = request.ReturnResult().(*GetTokenResult)
+	}
+	return results
+}
+
+// Decode token transfers of a Transfer, TransferSingle or TransferBatch log.
+// Return nil if the log is not a token transfer or is malformed.
+func DecodeTokenTransfers(log *db.Log) []*db.TokenTransfer {
+	newTransfer := func(standard, from, to string, tokenID, value *big.Int) *db.TokenTransfer {
+		return &db.TokenTransfer{
+			BlockID:         log.BlockID,
+			TransactionHash: log.TransactionHash,
+			LogIndex:        log.LogIndex,
+			TokenAddress:    log.Address,
+			Standard:        standard,
+			From:            from,
+			To:              to,
+			TokenID:         decimal.NewFromBigInt(tokenID, 0),
+			Value:           decimal.NewFromBigInt(value, 0),
+		}
+	}
+	switch log.Topic0 {
+	case TransferTopic:
+		from, to := topicToAddress(log.Topic1), topicToAddress(log.Topic2)
+		if from == "" || to == "" {
+			return nil
+		}
+		if log.Topic3 == "" {
+			if len(log.Data) != 32 {
+				return nil
+			}
+			return []*db.TokenTransfer{
+				newTransfer(db.TOKEN_STANDARD_ERC20, from, to, new(big.Int), new(big.Int).SetBytes(log.Data)),
+			}
+		}
+		tokenID, ok := new(big.Int).SetString(log.Topic3, 16)
+		if !ok || len(log.Data) != 0 {
+			return nil
+		}
+		return []*db.TokenTransfer{
+			newTransfer(db.TOKEN_STANDARD_ERC721, from, to, tokenID, big.NewInt(1)),
+		}
+	case TransferSingleTopic:
+		from, to := topicToAddress(log.Topic2), topicToAddress(log.Topic3)
+		if from == "" || to == "" || len(log.Data) != 64 {
+			return nil
+		}
+		return []*db.TokenTransfer{
+			newTransfer(db.TOKEN_STANDARD_ERC1155, from, to, new(big.Int).SetBytes(log.Data[:32]), new(big.Int).SetBytes(log.Data[32:])),
+		}
+	case TransferBatchTopic:
+		from, to := topicToAddress(log.Topic2), topicToAddress(log.Topic3)
+		if from == "" || to == "" || len(log.Data) < 64 {
+			return nil
+		}
+		tokenIDs := decodeAbiUintArray(log.Data, log.Data[:32])
+		values := decodeAbiUintArray(log.Data, log.Data[32:64])
+		if tokenIDs == nil || len(tokenIDs) != len(values) {
+			return nil
+		}
+		transfers := make([]*db.TokenTransfer, len(tokenIDs))
+		for i := range tokenIDs {
+			transfers[i] = newTransfer(db.TOKEN_STANDARD_ERC1155, from, to, tokenIDs[i], values[i])
+			transfers[i].BatchIndex = uint16(i)
+		}
+		return transfers
+	}
+	return nil
+}
+
+// Return the address stored in an indexed topic without 0x prefix. Return empty string if the topic is missing.
+func topicToAddress(topic string) string {
+	if len(topic) != 64 {
+		return ""
+	}
+	return topic[24:]
+}
+
+// Decode a uint256[] in ABI encoded `data` located at `offset`. Return nil if data is malformed.
+func decodeAbiUintArray(data []byte, offset []byte) []*big.Int {
+	start := new(big.Int).SetBytes(offset)
+	if !start.IsUint64() || start.Uint64() > uint64(len(data)-32) {
+		return nil
+	}
+	length := new(big.Int).SetBytes(data[start.Uint64() : start.Uint64()+32])
+	itemsStart := start.Uint64() + 32
+	if !length.IsUint64() || length.Uint64() > (uint64(len(data))-itemsStart)/32 {
+		return nil
+	}
+	items := make([]*big.Int, length.Uint64())
+	for i := range items {
+		itemStart := itemsStart + uint64(i)*32
+		items[i] = new(big.Int).SetBytes(data[itemStart : itemStart+32])
+	}
+	return items
+}
@@ -0,0 +1,91 @@
+package svc
+
+import (
+	"math/big"
+	"testing"
+	"viction-rpc-crawler-go/db"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeTokenTransfers(t *testing.T) {
+	from := "1111111111111111111111111111111111111111"
+	to := "2222222222222222222222222222222222222222"
+	operator := "000000000000000000000000" + "3333333333333333333333333333333333333333"
+	word := func(value int64) []byte {
+		return common.LeftPadBytes(big.NewInt(value).Bytes(), 32)
+	}
+	concat := func(words ...[]byte) []byte {
+		data := []byte{}
+		for _, w := range words {
+			data = append(data, w...)
+		}
+		return data
+	}
+	tests := []struct {
+		name     string
+		log      *db.Log
+		standard string
+		tokenIDs []string
+		values   []string
+	}{
+		{"erc20", &db.Log{Topic0: TransferTopic, Topic1: "000000000000000000000000" + from, Topic2: "000000000000000000000000" + to,
+			Data: word(1000)}, db.TOKEN_STANDARD_ERC20, []string{"0"}, []string{"1000"}},
+		{"erc721", &db.Log{Topic0: TransferTopic, Topic1: "000000000000000000000000" + from, Topic2: "000000000000000000000000" + to,
+			Topic3: "000000000000000000000000000000000000000000000000000000000000002a"}, db.TOKEN_STANDARD_ERC721, []string{"42"}, []string{"1"}},
+		{"erc1155 single", &db.Log{Topic0: TransferSingleTopic, Topic1: operator, Topic2: "000000000000000000000000" + from, Topic3: "000000000000000000000000" + to,
+			Data: concat(word(7), word(3))}, db.TOKEN_STANDARD_ERC1155, []string{"7"}, []string{"3"}},
+		{"erc1155 batch", &db.Log{Topic0: TransferBatchTopic, Topic1: operator, Topic2: "000000000000000000000000" + from, Topic3: "000000000000000000000000" + to,
+			Data: concat(word(64), word(160), word(2), word(1), word(2), word(2), word(10), word(20))}, db.TOKEN_STANDARD_ERC1155, []string{"1", "2"}, []string{"10", "20"}},
+		{"erc20 malformed", &db.Log{Topic0: TransferTopic, Topic1: "000000000000000000000000" + from, Topic2: "000000000000000000000000" + to,
+			Data: word(1)[:16]}, "", nil, nil},
+		{"erc1155 batch length mismatch", &db.Log{Topic0: TransferBatchTopic, Topic1: operator, Topic2: "000000000000000000000000" + from, Topic3: "000000000000000000000000" + to,
+			Data: concat(word(64), word(160), word(2), word(1), word(2), word(1), word(10))}, "", nil, nil},
+		{"erc1155 batch out of bounds", &db.Log{Topic0: TransferBatchTopic, Topic1: operator, Topic2: "000000000000000000000000" + from, Topic3: "000000000000000000000000" + to,
+			Data: concat(word(64), word(4096), word(0))}, "", nil, nil},
+		{"other event", &db.Log{Topic0: "8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"}, "", nil, nil},
+	}
+	for _, test := range tests {
+		test.log.BlockID = 100
+		test.log.LogIndex = 5
+		transfers := DecodeTokenTransfers(test.log)
+		if len(transfers) != len(test.tokenIDs) {
+			t.Errorf("%s: expected %d transfers, got %d.", test.name, len(test.tokenIDs), len(transfers))
+			continue
+		}
+		for i, transfer := range transfers {
+			if transfer.BlockID != 100 || transfer.LogIndex != 5 || transfer.BatchIndex != uint16(i) || transfer.Standard != test.standard ||
+				transfer.From != from || transfer.To != to || transfer.TokenID.String() != test.tokenIDs[i] || transfer.Value.String() != test.values[i] {
+				t.Errorf("%s: unexpected transfer #%d: %+v", test.name, i, transfer)
+			}
+		}
+	}
+}
+
+func TestDecodeAbiString(t *testing.T) {
+	word := func(value int64) []byte {
+		return common.LeftPadBytes(big.NewInt(value).Bytes(), 32)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"string", append(append(word(32), word(4)...), common.RightPadBytes([]byte("USDT"), 32)...), "USDT"},
+		{"bytes32", common.RightPadBytes([]byte("MKR"), 32), "MKR"},
+		{"empty", []byte{}, ""},
+		{"length out of bounds", append(append(word(32), word(64)...), common.RightPadBytes([]byte("USDT"), 32)...), ""},
+		{"invalid utf8", append(append(word(32), word(2)...), common.RightPadBytes([]byte{0xff, 0xfe}, 32)...), ""},
+	}
+	for _, test := range tests {
+		if actual := DecodeAbiString(test.data); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q.", test.name, test.expected, actual)
+		}
+	}
+	if decimals := DecodeAbiUint8(word(18)); !decimals.Present() || decimals.V() != 18 {
+		t.Errorf("Expected 18 decimals, got %+v.", decimals)
+	}
+	if decimals := DecodeAbiUint8(word(256)); decimals.Present() {
+		t.Errorf("Expected null decimals, got %+v.", decimals)
+	}
+}
@@ -0,0 +1,194 @@
+package svc
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/ethutil"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Index traces of blocks from `from` to `to` inclusively as internal transactions.
+// Blocks must be indexed first. Indexing stops at the first block whose hash differs from the stored one,
+// as stored blocks must be reindexed after a reorg before tracing.
+func (s *IndexBlock) indexTraces(workerID uint64, from, to *big.Int, batch int, forced bool) *IndexBlocksResult {
+	s.i.Logger.Infof("%s#%d: Trace indexing started.", s.ServiceID(), workerID)
+	result := &IndexBlocksResult{}
+	from, to, result.Error = s.resolveRange(workerID, "get_highest_index_block", "get_highest_trace_block", from, to, forced)
+	if result.Error != nil {
+		return result
+	}
+	s.indexBatches(result, from, to, batch, func(from, to *big.Int) (*big.Int, error) {
+		blockCount, internalTxCount, lastBlockNumber, err := s.indexTraceBatch(workerID, from, to)
+		result.BlockCount += blockCount
+		result.InternalTxCount += internalTxCount
+		return lastBlockNumber, err
+	})
+	if result.Error != nil {
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: Trace indexing finished. Block count = %d.", s.ServiceID(), workerID, result.BlockCount)
+	return result
+}
+
+// Trace blocks from `from` to `to` inclusively then write internal transactions of the leading contiguous blocks
+// and move the trace checkpoint to the last of them. Return number of blocks and internal transactions written
+// and the last block written.
+func (s *IndexBlock) indexTraceBatch(workerID uint64, from, to *big.Int) (int, int, *big.Int, error) {
+	storedBlocksRequest := multiplex.ExecParams{
+		"from_block_number": new(big.Int).Set(from),
+		"to_block_number":   new(big.Int).Set(to),
+	}
+	storedBlocksRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_blocks_range", storedBlocksRequest)
+	storedBlocksResponse := storedBlocksRequest.WaitForReturn().(*DbBlocksResult)
+	if storedBlocksResponse.Error != nil {
+		return 0, 0, nil, storedBlocksResponse.Error
+	}
+	storedHashes := map[uint64]string{}
+	for _, block := range storedBlocksResponse.Data {
+		storedHashes[block.ID] = block.Hash
+	}
+	traceBlocksResponse := s.traceBlocks(new(big.Int).Set(from), new(big.Int).Set(to))
+	getBlocksResponse := s.getBlocks(new(big.Int).Set(from), new(big.Int).Set(to))
+	txCounts := map[uint64]uint16{}
+	internalTxs := []*db.InternalTransaction{}
+	var lastBlockNumber *big.Int
+	var batchErr error
+	if len(traceBlocksResponse.Data) != len(getBlocksResponse.Data) {
+		batchErr = fmt.Errorf("got %d traces but %d blocks of block #%d to #%d", len(traceBlocksResponse.Data), len(getBlocksResponse.Data),
+			from.Uint64(), to.Uint64())
+	}
+	for i, traceResult := range traceBlocksResponse.Data {
+		if i >= len(getBlocksResponse.Data) {
+			break
+		}
+		blockResult := getBlocksResponse.Data[i]
+		if blockResult.Number.Cmp(traceResult.Number) != 0 {
+			batchErr = fmt.Errorf("trace of block #%d does not match block #%d", traceResult.Number.Uint64(), blockResult.Number.Uint64())
+			break
+		}
+		if traceResult.Error != nil {
+			batchErr = fmt.Errorf("trace of block #%d: %w", traceResult.Number.Uint64(), traceResult.Error)
+			break
+		}
+		if blockResult.Error != nil {
+			batchErr = fmt.Errorf("block #%d: %w", blockResult.Number.Uint64(), blockResult.Error)
+			break
+		}
+		if blockResult.Data == nil {
+			batchErr = fmt.Errorf("block #%d not found", blockResult.Number.Uint64())
+			break
+		}
+		storedHash, ok := storedHashes[blockResult.Number.Uint64()]
+		if !ok {
+			batchErr = fmt.Errorf("block #%d is not indexed", blockResult.Number.Uint64())
+			break
+		}
+		if blockResult.Data.Hash.Hex() != storedHash {
+			batchErr = fmt.Errorf("block #%d hash %s differs from stored hash %s, reindex blocks before tracing",
+				blockResult.Number.Uint64(), blockResult.Data.Hash.Hex(), storedHash)
+			break
+		}
+		if len(traceResult.Data) != len(blockResult.Data.Transactions) {
+			s.i.Logger.Warnf("%s#%d: Block #%d has %d transactions but %d traces.", s.ServiceID(), workerID,
+				traceResult.Number.Uint64(), len(blockResult.Data.Transactions), len(traceResult.Data))
+		}
+		txCounts[traceResult.Number.Uint64()] = uint16(len(traceResult.Data))
+		internalTxs = append(internalTxs, FlattenBlockTraces(blockResult.Data, traceResult.Data)...)
+		lastBlockNumber = traceResult.Number
+	}
+	if lastBlockNumber == nil {
+		return 0, 0, nil, batchErr
+	}
+	writeRequest := multiplex.ExecParams{
+		"tx_counts":    txCounts,
+		"internal_txs": internalTxs,
+	}
+	writeRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "write_internal_transactions", writeRequest)
+	writeResponse := writeRequest.WaitForReturn().(*WriteBlocksResult)
+	if writeResponse.Error != nil {
+		return 0, 0, nil, writeResponse.Error
+	}
+	checkpointRequest := multiplex.ExecParams{
+		"block_number": new(big.Int).Set(lastBlockNumber),
+	}
+	checkpointRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "save_highest_trace_block", checkpointRequest)
+	checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+	if checkpointResponse.Error != nil {
+		return 0, 0, nil, checkpointResponse.Error
+	}
+	s.i.Logger.Infof("%s#%d: Block #%d to #%d traced. Internal transaction count = %d.", s.ServiceID(), workerID,
+		from.Uint64(), lastBlockNumber.Uint64(), len(internalTxs))
+	return len(txCounts), len(internalTxs), lastBlockNumber, batchErr
+}
+
+func (s *IndexBlock) traceBlocks(from, to *big.Int) *TraceBlocksResult {
+	traceBlocksRequest := multiplex.ExecParams{
+		"from_block_number": from,
+		"to_block_number":   to,
+	}
+	traceBlocksRequest.ExpectReturn()
+	s.Dispatch("TraceBlocks", "trace_blocks_range", traceBlocksRequest)
+	return traceBlocksRequest.WaitForReturn().(*TraceBlocksResult)
+}
+
+// Flatten call trees of block traces into internal transactions in depth-first order.
+// Traces are matched with block transactions by position. Transactions failed to trace are skipped.
+func FlattenBlockTraces(block *rpc.Block, traces rpc.TraceBlockResult) []*db.InternalTransaction {
+	internalTxs := []*db.InternalTransaction{}
+	blockNumber := block.Number.Int()
+	for i, trace := range traces {
+		if trace == nil {
+			continue
+		}
+		txHash := ""
+		if i < len(block.Transactions) {
+			txHash = block.Transactions[i].Hash.Hex()
+		}
+		call := &rpc.TraceTransactionCall{
+			Type:    trace.Type,
+			From:    trace.From,
+			To:      trace.To,
+			Value:   trace.Value,
+			Gas:     trace.Gas,
+			GasUsed: trace.GasUsed,
+			Error:   trace.Error,
+			Calls:   trace.Calls,
+		}
+		internalTxs = flattenTraceCall(internalTxs, blockNumber, txHash, uint16(i), call, []int{})
+	}
+	return internalTxs
+}
+
+func flattenTraceCall(internalTxs []*db.InternalTransaction, blockNumber uint64, txHash string, txIndex uint16, call *rpc.TraceTransactionCall, traceAddress []int) []*db.InternalTransaction {
+	internalTx := db.NewInternalTransaction(blockNumber, txHash, txIndex, formatTraceAddress(traceAddress), uint16(len(traceAddress)),
+		call.Type, normalizeTraceAddress(call.From), normalizeTraceAddress(call.To), ethutil.HexToBigInt(call.Value),
+		ethutil.HexToBigInt(call.Gas).Uint64(), ethutil.HexToBigInt(call.GasUsed).Uint64(), call.Error)
+	internalTxs = append(internalTxs, internalTx)
+	for i, child := range call.Calls {
+		internalTxs = flattenTraceCall(internalTxs, blockNumber, txHash, txIndex, child, append(slices.Clone(traceAddress), i))
+	}
+	return internalTxs
+}
+
+// Format position of a call in the call tree as comma-separated child indexes, e.g. "0,2".
+func formatTraceAddress(traceAddress []int) string {
+	parts := make([]string, len(traceAddress))
+	for i, index := range traceAddress {
+		parts[i] = strconv.Itoa(index)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Convert address in trace to the form stored in database: lowercase hex without 0x prefix.
+func normalizeTraceAddress(address string) string {
+	return strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
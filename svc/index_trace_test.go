@@ -0,0 +1,55 @@
+package svc
+
+import (
+	"testing"
+	"viction-rpc-crawler-go/rpc"
+)
+
+func TestFlattenBlockTraces(t *testing.T) {
+	rawBlock := `{"number":"0x64","hash":"0x01","transactions":[{"hash":"0xaa"},{"hash":"0xbb"}]}`
+	rawTraces := `[
+		{"result":{"type":"CALL","from":"0x1111111111111111111111111111111111111111","to":"0x2222222222222222222222222222222222222222","value":"0xde0b6b3a7640000","gas":"0x5208","gasUsed":"0x5208"}},
+		{"result":{"type":"CALL","from":"0x1111111111111111111111111111111111111111","to":"0x3333333333333333333333333333333333333333","value":"0x0","gas":"0x30d40","gasUsed":"0x1d4c0","calls":[
+			{"type":"CALL","from":"0x3333333333333333333333333333333333333333","to":"0x4444444444444444444444444444444444444444","value":"0x10","gas":"0x100","gasUsed":"0x50","calls":[
+				{"type":"STATICCALL","from":"0x4444444444444444444444444444444444444444","to":"0x5555555555555555555555555555555555555555","gas":"0x80","gasUsed":"0x10"}
+			]},
+			{"type":"DELEGATECALL","from":"0x3333333333333333333333333333333333333333","to":"0x6666666666666666666666666666666666666666","gas":"0x200","gasUsed":"0x200","error":"out of gas"}
+		]}}
+	]`
+	block, err := rpc.DecodeBlock([]byte(rawBlock))
+	if err != nil {
+		t.Fatalf("Error while decoding block. %v", err)
+	}
+	traces, err := rpc.DecodeTraceBlock([]byte(rawTraces))
+	if err != nil {
+		t.Fatalf("Error while decoding traces. %v", err)
+	}
+	internalTxs := FlattenBlockTraces(block, traces)
+	if len(internalTxs) != 5 {
+		t.Fatalf("Expected 5 internal transactions, got %d.", len(internalTxs))
+	}
+	tests := []struct {
+		txHash       string
+		traceAddress string
+		depth        uint16
+		callType     string
+		to           string
+		value        string
+		gasUsed      uint64
+		err          string
+	}{
+		{"aa", "", 0, "CALL", "2222222222222222222222222222222222222222", "1000000000000000000", 21000, ""},
+		{"bb", "", 0, "CALL", "3333333333333333333333333333333333333333", "0", 120000, ""},
+		{"bb", "0", 1, "CALL", "4444444444444444444444444444444444444444", "16", 80, ""},
+		{"bb", "0,0", 2, "STATICCALL", "5555555555555555555555555555555555555555", "0", 16, ""},
+		{"bb", "1", 1, "DELEGATECALL", "6666666666666666666666666666666666666666", "0", 512, "out of gas"},
+	}
+	for i, test := range tests {
+		internalTx := internalTxs[i]
+		if internalTx.BlockID != 100 || internalTx.TransactionHash != test.txHash || internalTx.TraceAddress != test.traceAddress ||
+			internalTx.Depth != test.depth || internalTx.Type != test.callType || internalTx.To != test.to ||
+			internalTx.Value.String() != test.value || internalTx.GasUsed != test.gasUsed || internalTx.Error != test.err {
+			t.Errorf("Unexpected internal transaction #%d: %+v", i, internalTx)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package svc
+
+import (
+	"encoding/hex"
+	"math/big"
+	"slices"
+	"strings"
+	"viction-rpc-crawler-go/db"
+
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Minimum number of seconds between two blocks.
+const BlockPeriod = 2
+
+// Build block production statistics of masternodes from stored blocks from `from` to `to` inclusively.
+// Blocks must be indexed first.
+func (s *IndexBlock) indexValidators(workerID uint64, from, to *big.Int, batch int, forced bool) *IndexBlocksResult {
+	s.i.Logger.Infof("%s#%d: Validator indexing started.", s.ServiceID(), workerID)
+	result := &IndexBlocksResult{}
+	// Genesis block has no creator.
+	if from.Sign() == 0 {
+		from = big.NewInt(1)
+	}
+	from, to, result.Error = s.resolveRange(workerID, "get_highest_index_block", "get_highest_validator_block", from, to, forced)
+	if result.Error != nil {
+		return result
+	}
+	s.indexBatches(result, from, to, batch, func(from, to *big.Int) (*big.Int, error) {
+		missedSlotCount, err := s.indexValidatorStats(from.Uint64(), to.Uint64())
+		if err != nil {
+			return nil, err
+		}
+		result.BlockCount += int(new(big.Int).Sub(to, from).Int64()) + 1
+		result.MissedSlotCount += missedSlotCount
+		s.i.Logger.Infof("%s#%d: Validators of block #%d to #%d indexed. Missed slot count = %d.", s.ServiceID(), workerID,
+			from.Uint64(), to.Uint64(), missedSlotCount)
+		return to, nil
+	})
+	if result.Error != nil {
+		return result
+	}
+	s.i.Logger.Infof("%s#%d: Validator indexing finished. Block count = %d.", s.ServiceID(), workerID, result.BlockCount)
+	return result
+}
+
+// Rebuild statistics of epochs containing blocks from `from` to `to` inclusively then move the validator checkpoint to `to`.
+// Every epoch is rebuilt from its first block so statistics never count a block twice.
+// Return number of missed slots found in blocks from `from` to `to`.
+func (s *IndexBlock) indexValidatorStats(from, to uint64) (int, error) {
+	epochIDs := []uint64{}
+	stats := []*db.ValidatorStat{}
+	missedSlots := []*db.MissedSlot{}
+	missedSlotCount := 0
+	for epoch := (from - 1) / EpochLength; epoch <= (to-1)/EpochLength; epoch++ {
+		blocksRequest := multiplex.ExecParams{
+			"from_block_number": new(big.Int).SetUint64(epoch * EpochLength),
+			"to_block_number":   new(big.Int).SetUint64(min(to, (epoch+1)*EpochLength)),
+		}
+		blocksRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_blocks_range", blocksRequest)
+		blocksResponse := blocksRequest.WaitForReturn().(*DbBlocksResult)
+		if blocksResponse.Error != nil {
+			return 0, blocksResponse.Error
+		}
+		blocks := blocksResponse.Data
+		var checkpoint *db.Block
+		if len(blocks) > 0 && blocks[0].ID == epoch*EpochLength {
+			checkpoint = blocks[0]
+			blocks = blocks[1:]
+		}
+		epochStats, epochMissedSlots := NewValidatorStats(epoch, checkpoint, blocks)
+		epochIDs = append(epochIDs, epoch)
+		stats = append(stats, epochStats...)
+		missedSlots = append(missedSlots, epochMissedSlots...)
+		for _, missedSlot := range epochMissedSlots {
+			if missedSlot.BlockID >= from {
+				missedSlotCount++
+			}
+		}
+	}
+	writeRequest := multiplex.ExecParams{
+		"epoch_ids":    epochIDs,
+		"stats":        stats,
+		"missed_slots": missedSlots,
+	}
+	writeRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "write_validator_stats", writeRequest)
+	writeResponse := writeRequest.WaitForReturn().(*WriteBlocksResult)
+	if writeResponse.Error != nil {
+		return 0, writeResponse.Error
+	}
+	checkpointRequest := multiplex.ExecParams{
+		"block_number": new(big.Int).SetUint64(to),
+	}
+	checkpointRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "save_highest_validator_block", checkpointRequest)
+	checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+	if checkpointResponse.Error != nil {
+		return 0, checkpointResponse.Error
+	}
+	return missedSlotCount, nil
+}
+
+// Summarize block production of `epoch` per masternode. Masternodes take turns in the order listed in the checkpoint block,
+// the turn after the creator of the parent block is expected to create the next block. Checkpoint is nil if not stored,
+// in which case turns are unknown. Blocks must be ordered by number.
+//
+// When a block is created out of turn, every masternode between the expected one and the creator missed its slot.
+// The delay since the parent block tells how many slots elapsed: rotation alone cannot tell whole rounds apart,
+// so a round is added for every full round of slots elapsed, and slots are counted from the delay alone when
+// the creator is not listed. Missed slots are only inferred between consecutive blocks with known creators,
+// and record how late the block was created compared to the block period.
+func NewValidatorStats(epoch uint64, checkpoint *db.Block, blocks []*db.Block) ([]*db.ValidatorStat, []*db.MissedSlot) {
+	masternodes := []string{}
+	if checkpoint != nil {
+		for _, signer := range parseSigners(checkpoint.ExtraData) {
+			masternodes = append(masternodes, hex.EncodeToString(signer))
+		}
+	}
+	positions := map[string]int{}
+	statMap := map[string]*db.ValidatorStat{}
+	stats := []*db.ValidatorStat{}
+	for i, address := range masternodes {
+		positions[address] = i
+		statMap[address] = &db.ValidatorStat{
+			EpochID: epoch,
+			Address: address,
+			Listed:  true,
+		}
+		stats = append(stats, statMap[address])
+	}
+	unlisted := []*db.ValidatorStat{}
+	getStat := func(address string) *db.ValidatorStat {
+		if stat, ok := statMap[address]; ok {
+			return stat
+		}
+		statMap[address] = &db.ValidatorStat{
+			EpochID: epoch,
+			Address: address,
+		}
+		unlisted = append(unlisted, statMap[address])
+		return statMap[address]
+	}
+
+	missedSlots := []*db.MissedSlot{}
+	parent := checkpoint
+	toBlockID := epoch * EpochLength
+	for _, block := range blocks {
+		toBlockID = block.ID
+		if block.Attestor.Present() {
+			getStat(block.Attestor.V()).BlocksAttested++
+		}
+		if !block.Creator.Present() {
+			parent = nil
+			continue
+		}
+		creator := block.Creator.V()
+		getStat(creator).BlocksCreated++
+		if len(masternodes) > 0 && parent != nil && parent.ID+1 == block.ID && parent.Creator.Present() {
+			count := len(masternodes)
+			preIndex, ok := positions[parent.Creator.V()]
+			if !ok {
+				preIndex = -1
+			}
+			delay := max(block.Timestamp-parent.Timestamp-BlockPeriod, 0)
+			slots := int(delay/BlockPeriod) + 1
+			hops := slots
+			if curIndex, ok := positions[creator]; ok {
+				hops = (curIndex-preIndex-1+count)%count + 1
+				for hops+count <= slots {
+					hops += count
+				}
+			}
+			for i := 0; i < hops; i++ {
+				address := masternodes[(preIndex+1+i)%count]
+				statMap[address].ExpectedTurns++
+				if i == hops-1 && address == creator {
+					continue
+				}
+				statMap[address].MissedSlots++
+				missedSlots = append(missedSlots, &db.MissedSlot{
+					BlockID: block.ID,
+					EpochID: epoch,
+					Address: address,
+					Delay:   delay,
+				})
+			}
+		}
+		parent = block
+	}
+	slices.SortFunc(unlisted, func(a, b *db.ValidatorStat) int {
+		return strings.Compare(a.Address, b.Address)
+	})
+	stats = append(stats, unlisted...)
+	for _, stat := range stats {
+		stat.ToBlockID = toBlockID
+	}
+	return stats, missedSlots
+}
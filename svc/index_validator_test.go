@@ -0,0 +1,137 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"viction-rpc-crawler-go/db"
+
+	"github.com/gurukami/typ"
+)
+
+func TestNewValidatorStats(t *testing.T) {
+	a := "1111111111111111111111111111111111111111"
+	b := "2222222222222222222222222222222222222222"
+	c := "3333333333333333333333333333333333333333"
+	d := "4444444444444444444444444444444444444444"
+	extraData := make([]byte, extraVanityLength)
+	for _, address := range []string{a, b, c} {
+		signer, _ := hex.DecodeString(address)
+		extraData = append(extraData, signer...)
+	}
+	extraData = append(extraData, bytes.Repeat([]byte{0x66}, extraSealLength)...)
+	newBlock := func(id uint64, timestamp int64, creator, attestor string) *db.Block {
+		block := &db.Block{ID: id, Timestamp: timestamp}
+		if creator != "" {
+			block.Creator = typ.NString(creator)
+		}
+		if attestor != "" {
+			block.Attestor = typ.NString(attestor)
+		}
+		return block
+	}
+	checkpoint := newBlock(900, 1000, c, "")
+	checkpoint.ExtraData = extraData
+	blocks := []*db.Block{
+		newBlock(901, 1002, a, b),
+		newBlock(902, 1006, c, ""),
+		newBlock(903, 1008, a, ""),
+		newBlock(904, 1010, d, ""),
+		newBlock(905, 1012, "", ""),
+		newBlock(906, 1014, c, ""),
+	}
+	stats, missedSlots := NewValidatorStats(1, checkpoint, blocks)
+
+	expected := []db.ValidatorStat{
+		{EpochID: 1, Address: a, Listed: true, BlocksCreated: 2, ExpectedTurns: 2, ToBlockID: 906},
+		{EpochID: 1, Address: b, Listed: true, BlocksAttested: 1, ExpectedTurns: 2, MissedSlots: 2, ToBlockID: 906},
+		{EpochID: 1, Address: c, Listed: true, BlocksCreated: 2, ExpectedTurns: 1, ToBlockID: 906},
+		{EpochID: 1, Address: d, BlocksCreated: 1, ToBlockID: 906},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d validators, got %d.", len(expected), len(stats))
+	}
+	for i, stat := range stats {
+		if *stat != expected[i] {
+			t.Errorf("Unexpected statistics of validator #%d: %+v", i, stat)
+		}
+	}
+	expectedSlots := []db.MissedSlot{
+		{BlockID: 902, EpochID: 1, Address: b, Delay: 2},
+		{BlockID: 904, EpochID: 1, Address: b, Delay: 0},
+	}
+	if len(missedSlots) != len(expectedSlots) {
+		t.Fatalf("Expected %d missed slots, got %d.", len(expectedSlots), len(missedSlots))
+	}
+	for i, missedSlot := range missedSlots {
+		if *missedSlot != expectedSlots[i] {
+			t.Errorf("Unexpected missed slot #%d: %+v", i, missedSlot)
+		}
+	}
+}
+
+func TestNewValidatorStatsWithoutCheckpoint(t *testing.T) {
+	a := "1111111111111111111111111111111111111111"
+	blocks := []*db.Block{
+		{ID: 1801, Timestamp: 1000, Creator: typ.NString(a)},
+		{ID: 1802, Timestamp: 1010, Creator: typ.NString(a)},
+	}
+	stats, missedSlots := NewValidatorStats(2, nil, blocks)
+	if len(stats) != 1 || stats[0].Listed || stats[0].BlocksCreated != 2 || stats[0].ExpectedTurns != 0 || stats[0].ToBlockID != 1802 {
+		t.Errorf("Unexpected statistics: %+v", stats)
+	}
+	if len(missedSlots) != 0 {
+		t.Errorf("Expected no missed slots, got %d.", len(missedSlots))
+	}
+}
+
+func TestNewValidatorStatsTimestampGap(t *testing.T) {
+	a := "1111111111111111111111111111111111111111"
+	b := "2222222222222222222222222222222222222222"
+	c := "3333333333333333333333333333333333333333"
+	d := "4444444444444444444444444444444444444444"
+	extraData := make([]byte, extraVanityLength)
+	for _, address := range []string{a, b, c} {
+		signer, _ := hex.DecodeString(address)
+		extraData = append(extraData, signer...)
+	}
+	extraData = append(extraData, bytes.Repeat([]byte{0x66}, extraSealLength)...)
+	checkpoint := &db.Block{ID: 900, Timestamp: 1000, Creator: typ.NString(c), ExtraData: extraData}
+	blocks := []*db.Block{
+		{ID: 901, Timestamp: 1002, Creator: typ.NString(a)},
+		{ID: 902, Timestamp: 1010, Creator: typ.NString(b)},
+		{ID: 903, Timestamp: 1016, Creator: typ.NString(d)},
+	}
+	stats, missedSlots := NewValidatorStats(1, checkpoint, blocks)
+
+	expected := []db.ValidatorStat{
+		{EpochID: 1, Address: a, Listed: true, BlocksCreated: 1, ExpectedTurns: 3, MissedSlots: 2, ToBlockID: 903},
+		{EpochID: 1, Address: b, Listed: true, BlocksCreated: 1, ExpectedTurns: 3, MissedSlots: 2, ToBlockID: 903},
+		{EpochID: 1, Address: c, Listed: true, ExpectedTurns: 2, MissedSlots: 2, ToBlockID: 903},
+		{EpochID: 1, Address: d, BlocksCreated: 1, ToBlockID: 903},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d validators, got %d.", len(expected), len(stats))
+	}
+	for i, stat := range stats {
+		if *stat != expected[i] {
+			t.Errorf("Unexpected statistics of validator #%d: %+v", i, stat)
+		}
+	}
+	expectedSlots := []db.MissedSlot{
+		{BlockID: 902, EpochID: 1, Address: b, Delay: 6},
+		{BlockID: 902, EpochID: 1, Address: c, Delay: 6},
+		{BlockID: 902, EpochID: 1, Address: a, Delay: 6},
+		{BlockID: 903, EpochID: 1, Address: c, Delay: 4},
+		{BlockID: 903, EpochID: 1, Address: a, Delay: 4},
+		{BlockID: 903, EpochID: 1, Address: b, Delay: 4},
+	}
+	if len(missedSlots) != len(expectedSlots) {
+		t.Fatalf("Expected %d missed slots, got %d.", len(expectedSlots), len(missedSlots))
+	}
+	for i, missedSlot := range missedSlots {
+		if *missedSlot != expectedSlots[i] {
+			t.Errorf("Unexpected missed slot #%d: %+v", i, missedSlot)
+		}
+	}
+}
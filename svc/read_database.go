@@ -1,6 +1,7 @@
 package svc
 
 import (
+	"math/big"
 	"viction-rpc-crawler-go/db"
 
 	"github.com/tforce-io/tf-golib/diag"
@@ -21,5 +22,333 @@ func NewReadDatabase(logger diag.Logger, dbClient *db.DbClient) *ReadDatabase {
 }
 
 func (s *ReadDatabase) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "get_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		block, err := s.db.GetBlock(blockNumber.Uint64())
+		msg.Return(&DbBlockResult{
+			Number: blockNumber,
+			Data:   block,
+			Error:  err,
+		})
+	case "get_blocks_range":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		blocks, err := s.db.GetBlocksInRange(fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		msg.Return(&DbBlocksResult{
+			Data:  blocks,
+			Error: err,
+		})
+	case "get_transactions_range":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		transactions, err := s.db.GetTransactionsInRange(fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		msg.Return(&DbTransactionsResult{
+			Data:  transactions,
+			Error: err,
+		})
+	case "get_transfer_logs_range":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		logs, err := s.db.GetLogsByTopicsInRange(fromBlockNumber.Uint64(), toBlockNumber.Uint64(), TokenTransferTopics)
+		msg.Return(&DbLogsResult{
+			Data:  logs,
+			Error: err,
+		})
+	case "get_block_signatures_range":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		signatures, err := s.db.GetBlockSignaturesBySignedRange(fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		msg.Return(&DbBlockSignaturesResult{
+			Data:  signatures,
+			Error: err,
+		})
+	case "get_epochs_range":
+		fromEpoch := msg.GetParam("from_epoch", uint64(0)).(uint64)
+		toEpoch := msg.GetParam("to_epoch", uint64(0)).(uint64)
+		msg.Return(s.getEpochs(fromEpoch, toEpoch))
+	case "get_validator_stats_range":
+		fromEpoch := msg.GetParam("from_epoch", uint64(0)).(uint64)
+		toEpoch := msg.GetParam("to_epoch", uint64(0)).(uint64)
+		stats, err := s.db.GetValidatorStatsInRange(fromEpoch, toEpoch)
+		msg.Return(&DbValidatorStatsResult{
+			Data:  stats,
+			Error: err,
+		})
+	case "get_tokens":
+		addresses := msg.GetParam("addresses", []string{}).([]string)
+		tokens, err := s.db.GetTokens(addresses)
+		msg.Return(&DbTokensResult{
+			Data:  tokens,
+			Error: err,
+		})
+	case "get_issues_range":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		issues, err := s.db.GetIssuesInRange(fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		msg.Return(&IssuesResult{
+			Data:  issues,
+			Error: err,
+		})
+	case "get_highest_index_block":
+		checkpoint, err := s.db.GetHighestIndexBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_highest_trace_block":
+		checkpoint, err := s.db.GetHighestTraceBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_highest_receipt_block":
+		checkpoint, err := s.db.GetHighestReceiptBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_highest_token_block":
+		checkpoint, err := s.db.GetHighestTokenBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_highest_validator_block":
+		checkpoint, err := s.db.GetHighestValidatorBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_highest_import_block":
+		checkpoint, err := s.db.GetHighestImportBlock()
+		msg.Return(s.newCheckpointResult(checkpoint, err))
+	case "get_failed_blocks":
+		category := msg.GetParam("category", "").(string)
+		issues, err := s.db.GetFailedBlockIssues(category)
+		result := &FailedBlocksResult{
+			Data:  []*FailedBlock{},
+			Error: err,
+		}
+		for _, issue := range issues {
+			result.Data = append(result.Data, NewFailedBlockFromIssue(issue))
+		}
+		msg.Return(result)
+	case "get_block_gaps":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		checkTxs := msg.GetParam("check_txs", true).(bool)
+		msg.Return(s.getBlockGaps(fromBlockNumber.Uint64(), toBlockNumber.Uint64(), checkTxs))
+	case "get_tx_count_mismatches":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		mismatches, err := s.db.GetTransactionCountMismatches(fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		msg.Return(&BlockGapsResult{
+			Gaps:       []*db.BlockRange{},
+			Mismatches: mismatches,
+			Error:      err,
+		})
+	case "get_rollback_summary":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		summary, err := s.db.GetRollbackSummary(blockNumber.Uint64())
+		msg.Return(&RollbackResult{
+			Number:  blockNumber,
+			Summary: summary,
+			Error:   err,
+		})
+	case "get_issues":
+		filter := msg.GetParam("filter", &db.IssueFilter{}).(*db.IssueFilter)
+		issues, err := s.db.GetIssues(filter)
+		msg.Return(&IssuesResult{
+			Data:  issues,
+			Error: err,
+		})
+	case "get_issue":
+		hash := msg.GetParam("hash", "").(string)
+		issue, err := s.db.GetIssueByHash(hash)
+		result := &IssuesResult{
+			Data:  []*db.Issue{},
+			Error: err,
+		}
+		if issue != nil {
+			result.Data = append(result.Data, issue)
+		}
+		msg.Return(result)
+	case "get_database_status":
+		exact := msg.GetParam("exact", false).(bool)
+		msg.Return(s.getDatabaseStatus(exact))
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
 	return &multiplex.HookState{Handled: true}
 }
+
+// Scan blocks table in chunks so large ranges do not hold a long running query.
+// Gaps crossing chunk boundaries are merged.
+func (s *ReadDatabase) getBlockGaps(from, to uint64, checkTxs bool) *BlockGapsResult {
+	result := &BlockGapsResult{
+		Gaps:       []*db.BlockRange{},
+		Mismatches: []*db.TransactionCountMismatch{},
+	}
+	for chunkStart := from; chunkStart <= to; chunkStart += GapScanChunkSize {
+		chunkEnd := min(chunkStart+GapScanChunkSize-1, to)
+		gaps, err := s.db.GetBlockGaps(chunkStart, chunkEnd)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		for _, gap := range gaps {
+			lastIndex := len(result.Gaps) - 1
+			if lastIndex >= 0 && result.Gaps[lastIndex].To+1 == gap.From {
+				result.Gaps[lastIndex].To = gap.To
+				continue
+			}
+			result.Gaps = append(result.Gaps, gap)
+		}
+		if checkTxs {
+			mismatches, err := s.db.GetTransactionCountMismatches(chunkStart, chunkEnd)
+			if err != nil {
+				result.Error = err
+				return result
+			}
+			result.Mismatches = append(result.Mismatches, mismatches...)
+		}
+		if chunkEnd == to {
+			break
+		}
+	}
+	return result
+}
+
+func (s *ReadDatabase) getDatabaseStatus(exact bool) *DatabaseStatusResult {
+	result := &DatabaseStatusResult{ExactRowCounts: exact}
+	indexCheckpoint, err := s.db.GetHighestIndexBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.IndexCheckpoint = s.newCheckpointResult(indexCheckpoint, nil).Number
+	traceCheckpoint, err := s.db.GetHighestTraceBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.TraceCheckpoint = s.newCheckpointResult(traceCheckpoint, nil).Number
+	receiptCheckpoint, err := s.db.GetHighestReceiptBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.ReceiptCheckpoint = s.newCheckpointResult(receiptCheckpoint, nil).Number
+	tokenCheckpoint, err := s.db.GetHighestTokenBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.TokenCheckpoint = s.newCheckpointResult(tokenCheckpoint, nil).Number
+	validatorCheckpoint, err := s.db.GetHighestValidatorBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.ValidatorCheckpoint = s.newCheckpointResult(validatorCheckpoint, nil).Number
+	importCheckpoint, err := s.db.GetHighestImportBlock()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.ImportCheckpoint = s.newCheckpointResult(importCheckpoint, nil).Number
+	result.Bounds, result.Error = s.db.GetBlockBounds()
+	if result.Error != nil {
+		return result
+	}
+	result.RowCounts, result.Error = s.db.GetRowCounts(exact)
+	if result.Error != nil {
+		return result
+	}
+	result.OpenIssueCounts, result.Error = s.db.GetOpenIssueCounts()
+	return result
+}
+
+func (s *ReadDatabase) getEpochs(from, to uint64) *DbEpochsResult {
+	result := &DbEpochsResult{}
+	result.Epochs, result.Error = s.db.GetEpochsInRange(from, to)
+	if result.Error != nil {
+		return result
+	}
+	result.Validators, result.Error = s.db.GetEpochValidatorsInRange(from, to)
+	if result.Error != nil {
+		return result
+	}
+	result.Penalties, result.Error = s.db.GetEpochPenaltiesInRange(from, to)
+	return result
+}
+
+func (s *ReadDatabase) newCheckpointResult(checkpoint *db.Checkpoint, err error) *CheckpointResult {
+	result := &CheckpointResult{
+		Error: err,
+	}
+	if err == nil && checkpoint != nil {
+		result.Number = new(big.Int).SetUint64(checkpoint.BlockNumber)
+	}
+	return result
+}
+
+// Number of blocks scanned in one query when looking for gaps.
+const GapScanChunkSize = 100000
+
+type BlockGapsResult struct {
+	Gaps       []*db.BlockRange
+	Mismatches []*db.TransactionCountMismatch
+	Error      error
+}
+
+type CheckpointResult struct {
+	Number *big.Int
+	Error  error
+}
+
+type DatabaseStatusResult struct {
+	IndexCheckpoint     *big.Int
+	TraceCheckpoint     *big.Int
+	ReceiptCheckpoint   *big.Int
+	TokenCheckpoint     *big.Int
+	ValidatorCheckpoint *big.Int
+	ImportCheckpoint    *big.Int
+	// Lowest and highest stored block numbers. Nil if no blocks are stored.
+	Bounds    *db.BlockRange
+	RowCounts map[string]int64
+	// Whether RowCounts are exact. Otherwise they are planner estimates.
+	ExactRowCounts  bool
+	OpenIssueCounts map[uint16]int64
+	Error           error
+}
+
+type DbBlockResult struct {
+	Number *big.Int
+	Data   *db.Block
+	Error  error
+}
+
+type DbBlocksResult struct {
+	Data  []*db.Block
+	Error error
+}
+
+type DbTransactionsResult struct {
+	Data  []*db.Transaction
+	Error error
+}
+
+type DbBlockSignaturesResult struct {
+	Data  []*db.BlockSignature
+	Error error
+}
+
+type DbEpochsResult struct {
+	Epochs     []*db.Epoch
+	Validators []*db.EpochValidator
+	Penalties  []*db.EpochPenalty
+	Error      error
+}
+
+type DbLogsResult struct {
+	Data  []*db.Log
+	Error error
+}
+
+type DbValidatorStatsResult struct {
+	Data  []*db.ValidatorStat
+	Error error
+}
+
+type DbTokensResult struct {
+	Data  []*db.Token
+	Error error
+}
@@ -1,6 +1,10 @@
 package svc
 
 import (
+	"math/big"
+	"os"
+	"viction-rpc-crawler-go/rpc"
+
 	"github.com/tforce-io/tf-golib/diag"
 	"github.com/tforce-io/tf-golib/multiplex"
 )
@@ -17,5 +21,71 @@ func NewReadFileSystem(logger diag.Logger) *ReadFileSystem {
 }
 
 func (s *ReadFileSystem) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "eth_getBlockByNumber":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		rootDir := msg.GetParam("root", "").(string)
+		results := &GetBlocksResult{
+			Data: []*GetBlockResult{},
+		}
+		errorCount := 0
+		for blockNumber := new(big.Int).Set(fromBlockNumber); blockNumber.Cmp(toBlockNumber) <= 0; blockNumber = new(big.Int).Add(blockNumber, big.NewInt(1)) {
+			result := &GetBlockResult{
+				Number: blockNumber,
+			}
+			blockFile := GetNumberedFile(rootDir, BlockCategory, blockNumber)
+			data, err := os.ReadFile(blockFile)
+			if err == nil {
+				result.RawData = string(data)
+				result.Data, err = rpc.DecodeBlock(data)
+			}
+			if err != nil {
+				s.i.Logger.Errorf(err, "%s#%d: Failed to read block file #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+				errorCount++
+			}
+			result.Error = err
+			results.Data = append(results.Data, result)
+		}
+		s.i.Logger.Infof("%s#%d: %d blocks read. Error count = %d.", s.ServiceID(), workerID, len(results.Data), errorCount)
+		msg.Return(results)
+	case "debug_traceBlockByNumber":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		rootDir := msg.GetParam("root", "").(string)
+		results := &TraceBlocksResult{
+			Data: []*TraceBlockResult{},
+		}
+		errorCount := 0
+		for blockNumber := new(big.Int).Set(fromBlockNumber); blockNumber.Cmp(toBlockNumber) <= 0; blockNumber = new(big.Int).Add(blockNumber, big.NewInt(1)) {
+			result := &TraceBlockResult{
+				Number: blockNumber,
+			}
+			blockTraceFile := GetNumberedFile(rootDir, TraceBlockCategory, blockNumber)
+			data, err := os.ReadFile(blockTraceFile)
+			if err == nil {
+				result.RawData = string(data)
+				result.Data, err = rpc.DecodeTraceBlock(data)
+			}
+			if err != nil {
+				s.i.Logger.Errorf(err, "%s#%d: Failed to read block trace file #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+				errorCount++
+			}
+			result.Error = err
+			results.Data = append(results.Data, result)
+		}
+		s.i.Logger.Infof("%s#%d: %d block traces read. Error count = %d.", s.ServiceID(), workerID, len(results.Data), errorCount)
+		msg.Return(results)
+	case "read_failed_blocks":
+		rootDir := msg.GetParam("root", "").(string)
+		failedBlocks, err := ReadFailureLedger(rootDir)
+		msg.Return(&FailedBlocksResult{
+			Data:  failedBlocks,
+			Error: err,
+		})
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
 	return &multiplex.HookState{Handled: true}
 }
@@ -0,0 +1,222 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"slices"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Address of the block-signing contract without 0x prefix.
+const BlockSignerAddress = "0000000000000000000000000000000000000089"
+
+// Selector of sign(uint256 blockNumber, bytes32 blockHash).
+var signMethodID = crypto.Keccak256([]byte("sign(uint256,bytes32)"))[:4]
+
+type ReportSigning struct {
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+}
+
+func NewReportSigning(logger diag.Logger) *ReportSigning {
+	svc := &ReportSigning{}
+	svc.i = svc.InitServiceCore("ReportSigning", logger, svc.coreProcessHook)
+	return svc
+}
+
+func (s *ReportSigning) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "get_signing_report":
+		fromEpoch := msg.GetParam("from_epoch", uint64(0)).(uint64)
+		toEpoch := msg.GetParam("to_epoch", uint64(0)).(uint64)
+		result := s.getSigningReport(fromEpoch, toEpoch)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Signing report stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Build signing reports of epochs from `fromEpoch` to `toEpoch` inclusively.
+func (s *ReportSigning) getSigningReport(fromEpoch, toEpoch uint64) *SigningReportResult {
+	result := &SigningReportResult{
+		Epochs: []*EpochSigningReport{},
+	}
+	for epoch := fromEpoch; epoch <= toEpoch; epoch++ {
+		checkpointRequest := multiplex.ExecParams{
+			"block_number": new(big.Int).SetUint64(epoch * EpochLength),
+		}
+		checkpointRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_block", checkpointRequest)
+		checkpointResponse := checkpointRequest.WaitForReturn().(*DbBlockResult)
+		if checkpointResponse.Error != nil {
+			result.Error = checkpointResponse.Error
+			return result
+		}
+		var signers [][]byte
+		if checkpointResponse.Data != nil {
+			signers = parseSigners(checkpointResponse.Data.ExtraData)
+		}
+		signaturesRequest := multiplex.ExecParams{
+			"from_block_number": new(big.Int).SetUint64(epoch*EpochLength + 1),
+			"to_block_number":   new(big.Int).SetUint64((epoch + 1) * EpochLength),
+		}
+		signaturesRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_block_signatures_range", signaturesRequest)
+		signaturesResponse := signaturesRequest.WaitForReturn().(*DbBlockSignaturesResult)
+		if signaturesResponse.Error != nil {
+			result.Error = signaturesResponse.Error
+			return result
+		}
+		blocksRequest := multiplex.ExecParams{
+			"from_block_number": new(big.Int).SetUint64(epoch*EpochLength + 1),
+			"to_block_number":   new(big.Int).SetUint64((epoch + 1) * EpochLength),
+		}
+		blocksRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_blocks_range", blocksRequest)
+		blocksResponse := blocksRequest.WaitForReturn().(*DbBlocksResult)
+		if blocksResponse.Error != nil {
+			result.Error = blocksResponse.Error
+			return result
+		}
+		blockHashes := map[uint64]string{}
+		for _, block := range blocksResponse.Data {
+			blockHashes[block.ID] = block.Hash
+		}
+		result.Epochs = append(result.Epochs, NewEpochSigningReport(epoch, signers, signaturesResponse.Data, blockHashes))
+	}
+	return result
+}
+
+// Decode a transaction calling sign(uint256,bytes32) of the block-signing contract.
+// Return nil if the transaction is not a signing transaction or its input is malformed.
+func DecodeSignTransaction(tx *rpc.Transaction, block *rpc.Block) *db.BlockSignature {
+	if tx.To == nil || tx.To.Hex() != BlockSignerAddress {
+		return nil
+	}
+	input := tx.Input.Bytes()
+	if len(input) != 4+32+32 || !bytes.Equal(input[:4], signMethodID) {
+		return nil
+	}
+	signedBlockNumber := new(big.Int).SetBytes(input[4:36])
+	if !signedBlockNumber.IsUint64() {
+		return nil
+	}
+	return &db.BlockSignature{
+		BlockID:         block.Number.Int(),
+		TransactionHash: tx.Hash.Hex(),
+		Signer:          tx.From.Hex(),
+		SignedBlockID:   signedBlockNumber.Uint64(),
+		SignedBlockHash: hex.EncodeToString(input[36:68]),
+	}
+}
+
+// Summarize signing of blocks of `epoch` per masternode. Signers are listed in the checkpoint block of the epoch,
+// masternodes signing without being listed are appended in address order.
+// Blocks are expected to be signed only if at least one masternode signed them, since masternodes
+// do not sign every block. Signatures of reverted transactions and signatures whose block hash differs from
+// the stored hash in `blockHashes` are ignored. Signatures of blocks not stored are kept.
+func NewEpochSigningReport(epoch uint64, signers [][]byte, signatures []*db.BlockSignature, blockHashes map[uint64]string) *EpochSigningReport {
+	report := &EpochSigningReport{
+		Epoch:       epoch,
+		FromBlock:   epoch*EpochLength + 1,
+		ToBlock:     (epoch + 1) * EpochLength,
+		Masternodes: []*MasternodeSigning{},
+	}
+	signableBlocks := []uint64{}
+	signedBlocks := map[string]map[uint64]bool{}
+	for _, signature := range signatures {
+		if signature.Status.Present() && signature.Status.V() == 0 {
+			continue
+		}
+		if blockHash, ok := blockHashes[signature.SignedBlockID]; ok && blockHash != signature.SignedBlockHash {
+			continue
+		}
+		if len(signableBlocks) == 0 || signableBlocks[len(signableBlocks)-1] != signature.SignedBlockID {
+			signableBlocks = append(signableBlocks, signature.SignedBlockID)
+		}
+		if _, ok := signedBlocks[signature.Signer]; !ok {
+			signedBlocks[signature.Signer] = map[uint64]bool{}
+		}
+		signedBlocks[signature.Signer][signature.SignedBlockID] = true
+	}
+	report.SignableBlockCount = len(signableBlocks)
+	listed := map[string]bool{}
+	for _, signer := range signers {
+		address := hex.EncodeToString(signer)
+		listed[address] = true
+		report.Masternodes = append(report.Masternodes, newMasternodeSigning(address, true, signableBlocks, signedBlocks[address]))
+	}
+	unlisted := []string{}
+	for address := range signedBlocks {
+		if !listed[address] {
+			unlisted = append(unlisted, address)
+		}
+	}
+	slices.Sort(unlisted)
+	for _, address := range unlisted {
+		report.Masternodes = append(report.Masternodes, newMasternodeSigning(address, false, signableBlocks, signedBlocks[address]))
+	}
+	return report
+}
+
+// Consecutive signable blocks missed by the masternode are merged into one range.
+func newMasternodeSigning(address string, listed bool, signableBlocks []uint64, signed map[uint64]bool) *MasternodeSigning {
+	signing := &MasternodeSigning{
+		Address:      address,
+		Listed:       listed,
+		MissedBlocks: []*db.BlockRange{},
+	}
+	missing := false
+	for _, number := range signableBlocks {
+		if signed[number] {
+			signing.SignedCount++
+			missing = false
+			continue
+		}
+		signing.MissedCount++
+		if missing {
+			signing.MissedBlocks[len(signing.MissedBlocks)-1].To = number
+		} else {
+			signing.MissedBlocks = append(signing.MissedBlocks, &db.BlockRange{From: number, To: number})
+		}
+		missing = true
+	}
+	if len(signableBlocks) > 0 {
+		signing.Rate = float64(signing.SignedCount) / float64(len(signableBlocks))
+	}
+	return signing
+}
+
+type MasternodeSigning struct {
+	Address string `json:"address"`
+	// True if the masternode is in the signer list of the epoch checkpoint block.
+	Listed       bool             `json:"listed"`
+	SignedCount  int              `json:"signed_count"`
+	MissedCount  int              `json:"missed_count"`
+	Rate         float64          `json:"rate"`
+	MissedBlocks []*db.BlockRange `json:"missed_blocks"`
+}
+
+type EpochSigningReport struct {
+	Epoch              uint64               `json:"epoch"`
+	FromBlock          uint64               `json:"from_block"`
+	ToBlock            uint64               `json:"to_block"`
+	SignableBlockCount int                  `json:"signable_block_count"`
+	Masternodes        []*MasternodeSigning `json:"masternodes"`
+}
+
+type SigningReportResult struct {
+	Epochs []*EpochSigningReport
+	Error  error
+}
@@ -0,0 +1,94 @@
+package svc
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/ethutil"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/gurukami/typ"
+)
+
+func TestDecodeSignTransaction(t *testing.T) {
+	rawBlock := `{"number":"0x3e8","hash":"0x01","transactions":[
+		{"hash":"0xaa","from":"0x1111111111111111111111111111111111111111","to":"0x0000000000000000000000000000000000000089",
+			"input":"0xe341eaa400000000000000000000000000000000000000000000000000000000000003e7abababababababababababababababababababababababababababababababab"},
+		{"hash":"0xbb","from":"0x1111111111111111111111111111111111111111","to":"0x0000000000000000000000000000000000000089","input":"0xe341eaa4"},
+		{"hash":"0xcc","from":"0x1111111111111111111111111111111111111111","to":"0x2222222222222222222222222222222222222222",
+			"input":"0xe341eaa400000000000000000000000000000000000000000000000000000000000003e7abababababababababababababababababababababababababababababababab"},
+		{"hash":"0xdd","from":"0x1111111111111111111111111111111111111111","input":"0x"}
+	]}`
+	block, err := rpc.DecodeBlock([]byte(rawBlock))
+	if err != nil {
+		t.Fatalf("Error while decoding block. %v", err)
+	}
+	signature := DecodeSignTransaction(block.Transactions[0], block)
+	if signature == nil {
+		t.Fatalf("Expected signature of transaction 0xaa.")
+	}
+	if signature.BlockID != 1000 || signature.TransactionHash != "aa" || signature.Signer != "1111111111111111111111111111111111111111" ||
+		signature.SignedBlockID != 999 || signature.SignedBlockHash != "abababababababababababababababababababababababababababababababab" {
+		t.Errorf("Unexpected signature: %+v", signature)
+	}
+	for _, tx := range block.Transactions[1:] {
+		if signature := DecodeSignTransaction(tx, block); signature != nil {
+			t.Errorf("Unexpected signature of transaction 0x%s: %+v", tx.Hash.Hex(), signature)
+		}
+	}
+}
+
+func TestNewEpochSigningReport(t *testing.T) {
+	a := "1111111111111111111111111111111111111111"
+	b := "2222222222222222222222222222222222222222"
+	c := "3333333333333333333333333333333333333333"
+	signers := [][]byte{ethutil.HexToBytes(a), ethutil.HexToBytes(b)}
+	signatures := []*db.BlockSignature{
+		{Signer: a, SignedBlockID: 915},
+		{Signer: b, SignedBlockID: 915},
+		{Signer: a, SignedBlockID: 930},
+		{Signer: c, SignedBlockID: 930},
+		{Signer: a, SignedBlockID: 945},
+		{Signer: a, SignedBlockID: 945},
+		{Signer: a, SignedBlockID: 960},
+		{Signer: b, SignedBlockID: 960},
+		// Reverted signing transaction.
+		{Signer: b, SignedBlockID: 930, Status: typ.NUint16(0)},
+		// Signature of an orphaned block.
+		{Signer: b, SignedBlockID: 930, SignedBlockHash: "bb"},
+		{Signer: c, SignedBlockID: 975, SignedBlockHash: "bb"},
+	}
+	slices.SortStableFunc(signatures, func(x, y *db.BlockSignature) int {
+		return cmp.Compare(x.SignedBlockID, y.SignedBlockID)
+	})
+	blockHashes := map[uint64]string{930: "", 975: "aa"}
+	report := NewEpochSigningReport(1, signers, signatures, blockHashes)
+	if report.FromBlock != 901 || report.ToBlock != 1800 || report.SignableBlockCount != 4 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	tests := []struct {
+		address string
+		listed  bool
+		signed  int
+		missed  int
+		ranges  string
+	}{
+		{a, true, 4, 0, ""},
+		{b, true, 2, 2, "930-945"},
+		{c, false, 1, 3, "915, 945-960"},
+	}
+	if len(report.Masternodes) != len(tests) {
+		t.Fatalf("Expected %d masternodes, got %d.", len(tests), len(report.Masternodes))
+	}
+	for i, test := range tests {
+		masternode := report.Masternodes[i]
+		if masternode.Address != test.address || masternode.Listed != test.listed || masternode.SignedCount != test.signed ||
+			masternode.MissedCount != test.missed || db.FormatBlockRanges(masternode.MissedBlocks) != test.ranges {
+			t.Errorf("Unexpected masternode #%d: %+v", i, masternode)
+		}
+	}
+	if report.Masternodes[1].Rate != 0.5 {
+		t.Errorf("Expected rate 0.5, got %f.", report.Masternodes[1].Rate)
+	}
+}
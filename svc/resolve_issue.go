@@ -0,0 +1,233 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+type ResolveIssue struct {
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+}
+
+func NewResolveIssue(logger diag.Logger) *ResolveIssue {
+	svc := &ResolveIssue{}
+	svc.i = svc.InitServiceCore("ResolveIssue", logger, svc.coreProcessHook)
+	return svc
+}
+
+func (s *ResolveIssue) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "resolve_issues":
+		hashes := msg.GetParam("hashes", []string{}).([]string)
+		filter := msg.GetParam("filter", &db.IssueFilter{}).(*db.IssueFilter)
+		auto := msg.GetParam("auto", false).(bool)
+		result := s.resolveIssues(workerID, hashes, filter, auto)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Issue resolution stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Resolve open issues of `hashes`, or all open issues matching `filter` if no hashes are given.
+// If `auto` is set, only issues confirmed by re-checking the chain are resolved.
+func (s *ResolveIssue) resolveIssues(workerID uint64, hashes []string, filter *db.IssueFilter, auto bool) *ResolveIssuesResult {
+	result := &ResolveIssuesResult{
+		Checks: []*IssueCheck{},
+	}
+	issues := []*db.Issue{}
+	if len(hashes) > 0 {
+		for _, hash := range hashes {
+			issueRequest := multiplex.ExecParams{
+				"hash": hash,
+			}
+			issueRequest.ExpectReturn()
+			s.Dispatch("ReadDatabase", "get_issue", issueRequest)
+			issueResponse := issueRequest.WaitForReturn().(*IssuesResult)
+			if issueResponse.Error != nil {
+				result.Error = issueResponse.Error
+				return result
+			}
+			if len(issueResponse.Data) == 0 {
+				result.Error = fmt.Errorf("issue %s not found", hash)
+				return result
+			}
+			issues = append(issues, issueResponse.Data...)
+		}
+	} else {
+		openFilter := *filter
+		openFilter.Status = new(bool)
+		issuesRequest := multiplex.ExecParams{
+			"filter": &openFilter,
+		}
+		issuesRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_issues", issuesRequest)
+		issuesResponse := issuesRequest.WaitForReturn().(*IssuesResult)
+		if issuesResponse.Error != nil {
+			result.Error = issuesResponse.Error
+			return result
+		}
+		issues = issuesResponse.Data
+	}
+
+	resolvedIssues := []*db.Issue{}
+	for _, issue := range issues {
+		check := &IssueCheck{
+			Issue: issue,
+		}
+		switch {
+		case issue.Status:
+			check.Reason = "already resolved"
+		case !auto:
+			check.Resolved = true
+			check.Reason = "resolved manually"
+		default:
+			check.Resolved, check.Reason = s.recheckIssue(issue)
+		}
+		if check.Resolved {
+			resolvedIssues = append(resolvedIssues, issue)
+		}
+		s.i.Logger.Infof("%s#%d: Issue %s of block #%d checked. Resolved = %t. %s.", s.ServiceID(), workerID, issue.Hash, issue.BlockNumber, check.Resolved, check.Reason)
+		result.Checks = append(result.Checks, check)
+	}
+	if len(resolvedIssues) == 0 {
+		return result
+	}
+	resolveRequest := multiplex.ExecParams{
+		"issues": resolvedIssues,
+	}
+	resolveRequest.ExpectReturn()
+	s.Dispatch("WriteDatabase", "resolve_issues", resolveRequest)
+	resolveResponse := resolveRequest.WaitForReturn().(*IssuesResult)
+	result.Error = resolveResponse.Error
+	return result
+}
+
+// Check whether an issue still holds against the canonical chain and the database.
+// Return true if the issue no longer holds along with the reason.
+func (s *ResolveIssue) recheckIssue(issue *db.Issue) (bool, string) {
+	blockNumber := new(big.Int).SetUint64(issue.BlockNumber)
+	switch issue.Type {
+	case db.DUPLICATED_TX_HASH_ISSUE:
+		block, err := s.getCanonicalBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		if block.Hash.Hex() != issue.BlockHash {
+			return false, fmt.Sprintf("canonical block is %s", block.Hash.Hex())
+		}
+		for _, tx := range block.Transactions {
+			if tx.Hash.Hex() == issue.TxHash {
+				return true, "transaction is confirmed in canonical block"
+			}
+		}
+		return false, "transaction is not in canonical block"
+	case db.REORG_BLOCK_ISSUE:
+		block, err := s.getCanonicalBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		dbBlock, err := s.getStoredBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		if dbBlock.Hash != block.Hash.Hex() {
+			return false, fmt.Sprintf("stored block %s is not canonical block %s", dbBlock.Hash, block.Hash.Hex())
+		}
+		return true, "stored block is canonical"
+	case db.ERROR_ISSUE:
+		if category, _ := issue.Extras["category"].(string); category != BlockCategory {
+			return false, "only failed blocks can be re-checked"
+		}
+		_, err := s.getStoredBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		return true, "block is stored"
+	case db.BROKEN_PARENT_LINK_ISSUE:
+		dbBlock, err := s.getStoredBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		prevBlock, err := s.getStoredBlock(new(big.Int).Sub(blockNumber, big.NewInt(1)))
+		if err != nil {
+			return false, err.Error()
+		}
+		if dbBlock.ParentHash != prevBlock.Hash {
+			return false, "parent hash still does not match previous block"
+		}
+		return true, "parent hash matches previous block"
+	case db.TX_COUNT_MISMATCH_ISSUE:
+		_, err := s.getStoredBlock(blockNumber)
+		if err != nil {
+			return false, err.Error()
+		}
+		mismatchesRequest := multiplex.ExecParams{
+			"from_block_number": blockNumber,
+			"to_block_number":   blockNumber,
+		}
+		mismatchesRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_tx_count_mismatches", mismatchesRequest)
+		mismatchesResponse := mismatchesRequest.WaitForReturn().(*BlockGapsResult)
+		if mismatchesResponse.Error != nil {
+			return false, mismatchesResponse.Error.Error()
+		}
+		if len(mismatchesResponse.Mismatches) > 0 {
+			return false, "transaction count still mismatches"
+		}
+		return true, "all transactions are stored"
+	}
+	return false, fmt.Sprintf("%s issues cannot be re-checked", db.IssueTypeName(issue.Type))
+}
+
+func (s *ResolveIssue) getCanonicalBlock(blockNumber *big.Int) (*rpc.Block, error) {
+	blockRequest := multiplex.ExecParams{
+		"block_number": blockNumber,
+	}
+	blockRequest.ExpectReturn()
+	s.Dispatch("GetBlock", "get_block", blockRequest)
+	blockResponse := blockRequest.WaitForReturn().(*GetBlockResult)
+	if blockResponse.Error != nil {
+		return nil, blockResponse.Error
+	}
+	return blockResponse.Data, nil
+}
+
+func (s *ResolveIssue) getStoredBlock(blockNumber *big.Int) (*db.Block, error) {
+	blockRequest := multiplex.ExecParams{
+		"block_number": blockNumber,
+	}
+	blockRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_block", blockRequest)
+	blockResponse := blockRequest.WaitForReturn().(*DbBlockResult)
+	if blockResponse.Error != nil {
+		return nil, blockResponse.Error
+	}
+	if blockResponse.Data == nil {
+		return nil, errors.New("block is not stored")
+	}
+	return blockResponse.Data, nil
+}
+
+// Outcome of checking a single issue.
+type IssueCheck struct {
+	Issue    *db.Issue
+	Resolved bool
+	Reason   string
+}
+
+type ResolveIssuesResult struct {
+	Checks []*IssueCheck
+	Error  error
+}
@@ -1,146 +1,138 @@
 package svc
 
 import (
+	"sync"
 	"time"
 
-	"github.com/rs/zerolog"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
 )
 
 type JobMetadata struct {
 	nextExecution int64
+	running       bool
 
 	IntervalMs int64
 	ServiceID  string
 	Command    string
-	Params     ExecParams
+	Params     multiplex.ExecParams
 }
 
-type ScheduleSvcCommand struct {
-	Command string
+// Return a copy of job's params so every execution has its own return signal.
+func (j *JobMetadata) newParams() multiplex.ExecParams {
+	params := multiplex.ExecParams{}
+	for k, v := range j.Params {
+		if k == "return" {
+			continue
+		}
+		params[k] = v
+	}
+	return params
 }
 
 type ScheduleSvc struct {
-	i *ScheduleSvcInternal
-}
-
-type ScheduleSvcInternal struct {
-	Jobs       map[string]*JobMetadata
-	ExitSignal bool
-
-	WorkerMainCounter *WorkerCounter
-	WorkerMainCount   uint16
-	WorkerID          uint64
-
-	MainChan chan *ScheduleSvcCommand
-
-	IntervalMs int64
-	//Controller ServiceController
-	Logger *zerolog.Logger
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+
+	jobs       map[string]*JobMetadata
+	jobsLock   sync.Mutex
+	jobsSignal sync.WaitGroup
+	exitSignal bool
+	intervalMs int64
+	startOnce  sync.Once
 }
 
-func NewScheduleSvc(intervalMs int64 /*, controller ServiceController*/, logger *zerolog.Logger) ScheduleSvc {
-	svc := ScheduleSvc{
-		i: &ScheduleSvcInternal{
-			Jobs: map[string]*JobMetadata{},
-
-			WorkerMainCounter: &WorkerCounter{},
-
-			MainChan: make(chan *ScheduleSvcCommand, MAIN_CHAN_CAPACITY),
-
-			IntervalMs: intervalMs,
-			//Controller: controller,
-			Logger: logger,
-		},
+func NewScheduleSvc(logger diag.Logger, intervalMs int64) *ScheduleSvc {
+	if intervalMs < 250 {
+		intervalMs = 250
 	}
-	return svc
-}
-
-func (s ScheduleSvc) AddJob(jobID string, intervalMs int64, serviceID string, command string, params ExecParams) {
-	s.i.Jobs[jobID] = &JobMetadata{
-		IntervalMs: intervalMs,
-		ServiceID:  serviceID,
-		Command:    command,
-		Params:     params,
+	if intervalMs > 60000 {
+		intervalMs = 60000
 	}
+	svc := &ScheduleSvc{
+		jobs:       map[string]*JobMetadata{},
+		intervalMs: intervalMs,
+	}
+	svc.i = svc.InitServiceCore("Scheduler", logger, svc.coreProcessHook)
+	return svc
 }
 
-func (s ScheduleSvc) ServiceID() string {
-	return "Scheduler"
-}
-
-// func (s ScheduleSvc) Controller() ServiceController {
-// 	return s.i.Controller
-// }
-
-func (s ScheduleSvc) SetWorker(workerCount uint16) {
-	s.i.WorkerMainCounter.Lock()
-	defer s.i.WorkerMainCounter.Unlock()
-	if s.i.WorkerMainCounter.ValueNoLock() != s.i.WorkerMainCount {
-		return
-	}
-	if workerCount > 0 && s.i.WorkerMainCounter.ValueNoLock() == 0 {
-		if s.i.IntervalMs < 250 {
-			s.i.IntervalMs = 250
+func (s *ScheduleSvc) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "add_job":
+		jobID := msg.GetParam("job_id", "").(string)
+		job := &JobMetadata{
+			IntervalMs: msg.GetParam("interval_ms", int64(60000)).(int64),
+			ServiceID:  msg.GetParam("service_id", "").(string),
+			Command:    msg.GetParam("command", "").(string),
+			Params:     msg.GetParam("params", multiplex.ExecParams{}).(multiplex.ExecParams),
 		}
-		if s.i.IntervalMs > 60000 {
-			s.i.IntervalMs = 60000
+		if job.IntervalMs < 1 {
+			job.IntervalMs = 1
 		}
-		s.i.WorkerMainCount = 2
-		s.i.WorkerID++
-		go s.process(s.i.WorkerID)
-		s.i.WorkerID++
-		go s.processInterval(s.i.WorkerID)
-		s.i.Logger.Info().Msg("Scheduler started.")
-	}
-	if workerCount == 0 && s.i.WorkerMainCounter.ValueNoLock() > 0 {
-		s.i.WorkerMainCount = 0
-		cmd := &ScheduleSvcCommand{
-			Command: "exit",
-		}
-		s.i.MainChan <- cmd
-	}
-}
-
-func (s ScheduleSvc) WorkerCount() uint16 {
-	return s.i.WorkerMainCounter.ValueNoLock()
-}
-
-func (s ScheduleSvc) Exec(command string, params ExecParams) {
-	if command == "exit" {
-		return
+		s.jobsLock.Lock()
+		s.jobs[jobID] = job
+		s.jobsLock.Unlock()
+		// Interval loop starts with the first job so one-shot commands never run it.
+		s.startOnce.Do(func() {
+			go s.processInterval()
+		})
+		s.i.Logger.Infof("%s#%d: Job %s added. Interval = %dms.", s.ServiceID(), workerID, jobID, job.IntervalMs)
+		msg.Return(true)
+	case "remove_job":
+		jobID := msg.GetParam("job_id", "").(string)
+		s.jobsLock.Lock()
+		delete(s.jobs, jobID)
+		s.jobsLock.Unlock()
+		s.i.Logger.Infof("%s#%d: Job %s removed.", s.ServiceID(), workerID, jobID)
+		msg.Return(true)
+	case "stop":
+		s.jobsLock.Lock()
+		s.exitSignal = true
+		s.jobsLock.Unlock()
+		s.i.Logger.Infof("%s#%d: Waiting for running jobs.", s.ServiceID(), workerID)
+		s.jobsSignal.Wait()
+		s.i.Logger.Infof("%s#%d: Scheduler stopped.", s.ServiceID(), workerID)
+		msg.Return(true)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
 	}
+	return &multiplex.HookState{Handled: true}
 }
 
-func (s *ScheduleSvc) process(workerID uint64) {
-	s.i.WorkerMainCounter.Increase()
-	s.i.Logger.Info().Uint64("WorkerID", workerID).Msg("Scheduler Process started.")
-	status := INIT_STATE
-	for status != EXIT_STATE {
-		msg := <-s.i.MainChan
-		switch msg.Command {
-		case "exit":
-			status = EXIT_STATE
-			s.i.ExitSignal = true
+func (s *ScheduleSvc) processInterval() {
+	s.i.Logger.Infof("%s: ProcessInterval started.", s.ServiceID())
+	for {
+		s.jobsLock.Lock()
+		if s.exitSignal {
+			s.jobsLock.Unlock()
+			break
 		}
-	}
-	s.i.WorkerMainCounter.Decrease()
-	s.i.Logger.Info().Uint64("WorkerID", workerID).Msg("Scheduler Process exited.")
-}
-
-func (s *ScheduleSvc) processInterval(workerID uint64) {
-	s.i.WorkerMainCounter.Increase()
-	s.i.Logger.Info().Uint64("WorkerID", workerID).Msg("Scheduler ProcessInterval started.")
-	for !s.i.ExitSignal {
 		currentTimeMs := time.Now().UnixMilli()
-		for jobID := range s.i.Jobs {
-			job := s.i.Jobs[jobID]
-			if job.nextExecution < currentTimeMs {
-				// s.i.Controller.ExecService(job.ServiceID, job.Command, job.Params)
-				job.nextExecution = currentTimeMs - (currentTimeMs % job.IntervalMs) + job.IntervalMs
+		for jobID, job := range s.jobs {
+			if job.running || job.nextExecution > currentTimeMs {
+				continue
 			}
+			job.running = true
+			job.nextExecution = currentTimeMs - (currentTimeMs % job.IntervalMs) + job.IntervalMs
+			params := job.newParams()
+			params.ExpectReturn()
+			s.jobsSignal.Add(1)
+			s.i.Logger.Debugf("%s: Job %s triggered.", s.ServiceID(), jobID)
+			s.Dispatch(job.ServiceID, job.Command, params)
+			go s.waitJob(job, params)
 		}
-		time.Sleep(time.Duration(s.i.IntervalMs * int64(time.Millisecond)))
+		s.jobsLock.Unlock()
+		time.Sleep(time.Duration(s.intervalMs * int64(time.Millisecond)))
 	}
-	s.i.WorkerMainCounter.Decrease()
-	s.i.Logger.Info().Uint64("WorkerID", workerID).Msg("Scheduler Process exited.")
+	s.i.Logger.Infof("%s: ProcessInterval exited.", s.ServiceID())
+}
+
+func (s *ScheduleSvc) waitJob(job *JobMetadata, params multiplex.ExecParams) {
+	params.Wait()
+	s.jobsLock.Lock()
+	job.running = false
+	s.jobsLock.Unlock()
+	s.jobsSignal.Done()
 }
@@ -2,7 +2,8 @@ package svc
 
 import (
 	"math/big"
-	"strings"
+	"time"
+	"viction-rpc-crawler-go/config"
 	"viction-rpc-crawler-go/rpc"
 
 	"github.com/tforce-io/tf-golib/diag"
@@ -14,18 +15,15 @@ type TraceBlock struct {
 	multiplex.ServiceCore
 	i   *multiplex.ServiceCoreInternal
 	o   *NetworkOptions
-	rpc *rpc.EthClient
+	rpc *rpc.EthPool
 }
 
-func NewTraceBlock(logger diag.Logger, rpc *rpc.EthClient) *TraceBlock {
+func NewTraceBlock(logger diag.Logger, rpc *rpc.EthPool, retry *config.RetryPolicyConfig) *TraceBlock {
 	svc := &TraceBlock{
 		rpc: rpc,
 	}
 	svc.i = svc.InitServiceCore("TraceBlock", logger, svc.coreProcessHook)
-	svc.o = &NetworkOptions{
-		MaxRetries:  5,
-		MaxRetryGap: 200 * 1000000,
-	}
+	svc.o = NewNetworkOptions(retry)
 	return svc
 }
 
@@ -33,34 +31,36 @@ func (s *TraceBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMess
 	switch msg.Command {
 	case "trace_block":
 		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
-		blockTraces, str, err := s.rpc.TraceBlockByNumber(blockNumber)
-		retryCount := 0
-		halfRetry := false
-		for err != nil && retryCount < s.o.MaxRetries {
-			errStr := err.Error()
-			if strings.HasPrefix(err.Error(), "503 Service Unavailable: <html><body><h1>503 Service Unavailable</h1>") {
-				if !halfRetry {
-					retryCount--
-				}
-				halfRetry = !halfRetry
-			} else {
-				s.i.Logger.Warnf("%s#%02d: Block #%d retrying. %v", s.i.ServiceID, workerID, blockNumber.Uint64(), errStr)
-			}
-			s.o.WaitRetryGap()
+		var blockTraces rpc.TraceBlockResult
+		var str string
+		startTime := time.Now()
+		retryCount, retryTime, err := s.o.Retry(func() error {
+			var err error
 			blockTraces, str, err = s.rpc.TraceBlockByNumber(blockNumber)
-			retryCount++
-		}
+			return err
+		}, func(err error) {
+			s.i.Logger.Warnf("%s#%02d: Block #%d retrying. %s: %v", s.i.ServiceID, workerID, blockNumber.Uint64(), rpc.ErrorClassOf(err), err)
+		})
 		result := &TraceBlockResult{
-			Number:  blockNumber,
-			Data:    blockTraces,
-			RawData: str,
-			Error:   err,
+			Number:     blockNumber,
+			Data:       blockTraces,
+			RawData:    str,
+			Error:      err,
+			ErrorClass: rpc.ErrorClassOf(err),
+			Latency:    time.Since(startTime),
+			RetryCount: retryCount,
 		}
-		s.i.Logger.Infof("%s#%02d: Block #%d processed. %s. Retry count = %d.", s.i.ServiceID, workerID, blockNumber.Uint64(),
-			opx.Ternary(err == nil, "SUCCESS", "FAILED"),
+		s.i.Logger.Infof("%s#%02d: Block #%d processed. %s. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID, blockNumber.Uint64(),
+			opx.Ternary(err == nil, "SUCCESS", "FAILED "+string(result.ErrorClass)),
 			retryCount,
+			retryTime.Round(time.Millisecond),
 		)
 		msg.Return(result)
+	case "trace_blocks_batch":
+		blockNumbers := msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
+		msg.Return(&TraceBlocksResult{
+			Data: s.traceBlocksBatch(workerID, blockNumbers),
+		})
 	default:
 		s.i.Logger.Warnf("%s#%02d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
 		msg.Return(nil)
@@ -68,9 +68,86 @@ func (s *TraceBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMess
 	return &multiplex.HookState{Handled: true}
 }
 
+// Trace blocks in one JSON-RPC batch. Only failed items are retried.
+func (s *TraceBlock) traceBlocksBatch(workerID uint64, blockNumbers []*big.Int) []*TraceBlockResult {
+	results := make([]*TraceBlockResult, len(blockNumbers))
+	pending := make([]int, len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		results[i] = &TraceBlockResult{
+			Number: blockNumber,
+		}
+		pending[i] = i
+	}
+	start := time.Now()
+	var retryStart time.Time
+	retryCount := 0
+	for len(pending) > 0 {
+		pendingNumbers := make([]*big.Int, len(pending))
+		for j, i := range pending {
+			pendingNumbers[j] = blockNumbers[i]
+		}
+		blockTraces, strs, errs, err := s.rpc.TraceBlocksByNumber(pendingNumbers)
+		failed := []int{}
+		for j, i := range pending {
+			if err != nil {
+				results[i].Error = err
+			} else {
+				results[i].Data = blockTraces[j]
+				results[i].RawData = strs[j]
+				results[i].Error = errs[j]
+			}
+			results[i].ErrorClass = rpc.ErrorClassOf(results[i].Error)
+			if s.o.CanRetry(results[i].Error, retryCount, time.Since(start)) {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 {
+			break
+		}
+		for _, i := range pending {
+			results[i].RetryCount++
+		}
+		firstErr := results[pending[0]].Error
+		s.i.Logger.Warnf("%s#%02d: %d of %d blocks retrying. %s: %v", s.i.ServiceID, workerID, len(pending), len(blockNumbers), rpc.ErrorClassOf(firstErr), firstErr)
+		if retryCount == 0 {
+			retryStart = time.Now()
+		}
+		s.o.WaitRetryDelay(firstErr, retryCount, time.Since(start))
+		retryCount++
+	}
+	retryTime := time.Duration(0)
+	if retryCount > 0 {
+		retryTime = time.Since(retryStart)
+	}
+	latency := time.Since(start)
+	for _, result := range results {
+		result.Latency = latency
+	}
+	errorCount := 0
+	for _, result := range results {
+		if result.Error != nil {
+			errorCount++
+		}
+	}
+	if len(blockNumbers) > 0 {
+		s.i.Logger.Infof("%s#%02d: Block #%d to #%d processed. Error count = %d. Retry count = %d. Retry time = %s.", s.i.ServiceID, workerID,
+			blockNumbers[0].Uint64(), blockNumbers[len(blockNumbers)-1].Uint64(),
+			errorCount,
+			retryCount,
+			retryTime.Round(time.Millisecond),
+		)
+	}
+	return results
+}
+
 type TraceBlockResult struct {
-	Number  *big.Int
-	Data    rpc.TraceBlockResult
-	RawData string
-	Error   error
+	Number     *big.Int
+	Data       rpc.TraceBlockResult
+	RawData    string
+	Error      error
+	ErrorClass rpc.ErrorClass
+	// Time spent on the request including retries. Blocks fetched in one batch share the same latency.
+	Latency    time.Duration
+	RetryCount int
 }
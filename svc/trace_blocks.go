@@ -11,63 +11,48 @@ import (
 
 type TraceBlocks struct {
 	multiplex.ServiceCore
-	i *multiplex.ServiceCoreInternal
+	i            *multiplex.ServiceCoreInternal
+	rpcBatchSize int
 }
 
-func NewTraceBlocks(logger diag.Logger) *TraceBlocks {
-	svc := &TraceBlocks{}
+func NewTraceBlocks(logger diag.Logger, rpcBatchSize int) *TraceBlocks {
+	svc := &TraceBlocks{
+		rpcBatchSize: rpcBatchSize,
+	}
 	svc.i = svc.InitServiceCore("TraceBlocks", logger, svc.coreProcessHook)
 	return svc
 }
 
 func (s *TraceBlocks) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
 	switch msg.Command {
-	case "trace_blocks":
-	case "trace_blocks_range":
+	case "trace_blocks", "trace_blocks_range":
 		s.i.Logger.Infof("%s#%02d: %s started.", s.i.ServiceID, workerID, msg.Command)
 		startTime := time.Now()
-		requests := []multiplex.ExecParams{}
-		signal := new(sync.WaitGroup)
+		blockNumbers := []*big.Int{}
 		if msg.Command == "trace_blocks" {
-			blockNumbers := msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
-			for _, blockNumber := range blockNumbers {
-				request := multiplex.ExecParams{
-					"block_number": blockNumber,
-					"signal":       signal,
-				}
-				request.ExpectReturnCustomSignal(signal)
-				requests = append(requests, request)
-			}
+			blockNumbers = msg.GetParam("block_numbers", []*big.Int{}).([]*big.Int)
 		}
 		if msg.Command == "trace_blocks_range" {
 			fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
 			toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
-			for blockNumber := fromBlockNumber; blockNumber.Cmp(toBlockNumber) <= 0; blockNumber.Set(new(big.Int).Add(blockNumber, big.NewInt(1))) {
-				request := multiplex.ExecParams{
-					"block_number": new(big.Int).Set(blockNumber),
-				}
-				request.ExpectReturnCustomSignal(signal)
-				requests = append(requests, request)
+			for blockNumber := new(big.Int).Set(fromBlockNumber); blockNumber.Cmp(toBlockNumber) <= 0; blockNumber = new(big.Int).Add(blockNumber, big.NewInt(1)) {
+				blockNumbers = append(blockNumbers, blockNumber)
 			}
 		}
-		results := &TraceBlocksResult{
-			Data: make([]*TraceBlockResult, len(requests)),
+		results := &TraceBlocksResult{}
+		if s.rpcBatchSize > 1 {
+			results.Data = s.traceBlocksBatch(blockNumbers)
+		} else {
+			results.Data = s.traceBlocks(blockNumbers)
 		}
 		errorCount := 0
-		if len(requests) > 0 {
-			signal.Add(len(requests))
-			for _, request := range requests {
-				s.Dispatch("TraceBlock", "trace_block", request)
-			}
-			signal.Wait()
-			for i, request := range requests {
-				results.Data[i] = request.ReturnResult().(*TraceBlockResult)
-				if results.Data[i].Error != nil {
-					errorCount++
-				}
+		for _, result := range results.Data {
+			if result.Error != nil {
+				errorCount++
 			}
 		}
-		s.i.Logger.Infof("%s#%02d: %d blocks traced in %v. Error count = %d.", s.i.ServiceID, workerID, len(requests), time.Since(startTime), errorCount)
+		results.Elapsed = time.Since(startTime)
+		s.i.Logger.Infof("%s#%02d: %d blocks traced in %v. Error count = %d.", s.i.ServiceID, workerID, len(blockNumbers), results.Elapsed, errorCount)
 		msg.Return(results)
 	default:
 		s.i.Logger.Warnf("%s#%02d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
@@ -76,6 +61,53 @@ func (s *TraceBlocks) coreProcessHook(workerID uint64, msg *multiplex.ServiceMes
 	return &multiplex.HookState{Handled: true}
 }
 
+func (s *TraceBlocks) traceBlocks(blockNumbers []*big.Int) []*TraceBlockResult {
+	results := make([]*TraceBlockResult, len(blockNumbers))
+	if len(blockNumbers) == 0 {
+		return results
+	}
+	requests := make([]multiplex.ExecParams, len(blockNumbers))
+	signal := new(sync.WaitGroup)
+	signal.Add(len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		requests[i] = multiplex.ExecParams{
+			"block_number": blockNumber,
+		}
+		requests[i].ExpectReturnCustomSignal(signal)
+		s.Dispatch("TraceBlock", "trace_block", requests[i])
+	}
+	signal.Wait()
+	for i, request := range requests {
+		results[i] = request.ReturnResult().(*TraceBlockResult)
+	}
+	return results
+}
+
+func (s *TraceBlocks) traceBlocksBatch(blockNumbers []*big.Int) []*TraceBlockResult {
+	results := []*TraceBlockResult{}
+	requests := []multiplex.ExecParams{}
+	signal := new(sync.WaitGroup)
+	for start := 0; start < len(blockNumbers); start += s.rpcBatchSize {
+		end := min(start+s.rpcBatchSize, len(blockNumbers))
+		request := multiplex.ExecParams{
+			"block_numbers": blockNumbers[start:end],
+		}
+		request.ExpectReturnCustomSignal(signal)
+		requests = append(requests, request)
+	}
+	signal.Add(len(requests))
+	for _, request := range requests {
+		s.Dispatch("TraceBlock", "trace_blocks_batch", request)
+	}
+	signal.Wait()
+	for _, request := range requests {
+		results = append(results, request.ReturnResult().(*TraceBlocksResult).Data...)
+	}
+	return results
+}
+
 type TraceBlocksResult struct {
 	Data []*TraceBlockResult
+	// Wall time to fetch all blocks. Only set by commands fetching multiple blocks.
+	Elapsed time.Duration
 }
@@ -0,0 +1,66 @@
+package svc
+
+import (
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/gurukami/typ"
+)
+
+// Categories of system contracts keyed by address without 0x prefix.
+var SystemContracts = map[string]uint16{
+	BlockSignerAddress:                         db.SIGN_TX,
+	"0000000000000000000000000000000000000090": db.RANDOMIZE_TX,
+	"0000000000000000000000000000000000000091": db.TOMOX_TX,
+	"0000000000000000000000000000000000000092": db.TOMOX_TRADING_STATE_TX,
+	"0000000000000000000000000000000000000093": db.LENDING_TX,
+	"0000000000000000000000000000000000000094": db.FINAL_LENDING_TX,
+}
+
+// Return category of the transaction by its recipient. Contract creations and calls to
+// any other address are user transactions.
+func ClassifyTransaction(tx *rpc.Transaction) uint16 {
+	if tx.To == nil {
+		return db.USER_TX
+	}
+	if category, ok := SystemContracts[tx.To.Hex()]; ok {
+		return category
+	}
+	return db.USER_TX
+}
+
+// TransactionCounts is number of transactions of a block per category.
+type TransactionCounts map[uint16]uint16
+
+func CountTransactions(block *rpc.Block) TransactionCounts {
+	counts := TransactionCounts{}
+	for _, tx := range block.Transactions {
+		counts[ClassifyTransaction(tx)]++
+	}
+	return counts
+}
+
+// Return number of transactions of all categories.
+func (c TransactionCounts) Total() uint16 {
+	total := uint16(0)
+	for _, count := range c {
+		total += count
+	}
+	return total
+}
+
+// Return number of transactions calling system contracts.
+func (c TransactionCounts) System() uint16 {
+	return c.Total() - c[db.USER_TX]
+}
+
+func (c TransactionCounts) copyTo(dbBlock *db.Block) {
+	dbBlock.TransactionCount = typ.NUint16(c.Total())
+	dbBlock.TransactionCountSystem = typ.NUint16(c.System())
+	dbBlock.TransactionCountSign = typ.NUint16(c[db.SIGN_TX])
+	dbBlock.TransactionCountRandomize = typ.NUint16(c[db.RANDOMIZE_TX])
+	dbBlock.TransactionCountTomoX = typ.NUint16(c[db.TOMOX_TX])
+	dbBlock.TransactionCountTomoXTradingState = typ.NUint16(c[db.TOMOX_TRADING_STATE_TX])
+	dbBlock.TransactionCountLending = typ.NUint16(c[db.LENDING_TX])
+	dbBlock.TransactionCountFinalLending = typ.NUint16(c[db.FINAL_LENDING_TX])
+}
@@ -0,0 +1,74 @@
+package svc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+)
+
+func loadFixtureBlock(t *testing.T, name string) *rpc.Block {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Error while reading fixture %s. %v", name, err)
+	}
+	block, err := rpc.DecodeBlock(data)
+	if err != nil {
+		t.Fatalf("Error while decoding fixture %s. %v", name, err)
+	}
+	return block
+}
+
+func TestClassifyTransaction(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		categories []uint16
+	}{
+		{"synthetic_block_user.json", []uint16{db.SIGN_TX, db.SIGN_TX, db.USER_TX, db.USER_TX, db.USER_TX}},
+		{"synthetic_block_system.json", []uint16{db.RANDOMIZE_TX, db.RANDOMIZE_TX, db.SIGN_TX, db.TOMOX_TX, db.LENDING_TX,
+			db.FINAL_LENDING_TX, db.TOMOX_TRADING_STATE_TX}},
+		{"synthetic_block_empty.json", []uint16{}},
+	}
+	for _, test := range tests {
+		block := loadFixtureBlock(t, test.fixture)
+		if len(block.Transactions) != len(test.categories) {
+			t.Fatalf("%s: expected %d transactions, got %d.", test.fixture, len(test.categories), len(block.Transactions))
+		}
+		for i, tx := range block.Transactions {
+			if category := ClassifyTransaction(tx); category != test.categories[i] {
+				t.Errorf("%s: expected transaction #%d to be %s, got %s.", test.fixture, i,
+					db.TransactionCategoryName(test.categories[i]), db.TransactionCategoryName(category))
+			}
+		}
+	}
+}
+
+func TestCountTransactions(t *testing.T) {
+	tests := []struct {
+		fixture string
+		total   uint16
+		system  uint16
+		sign    uint16
+		random  uint16
+		tomox   uint16
+		trading uint16
+		lending uint16
+		final   uint16
+	}{
+		{"synthetic_block_user.json", 5, 2, 2, 0, 0, 0, 0, 0},
+		{"synthetic_block_system.json", 7, 7, 1, 2, 1, 1, 1, 1},
+		{"synthetic_block_empty.json", 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		dbBlock := &db.Block{}
+		CountTransactions(loadFixtureBlock(t, test.fixture)).copyTo(dbBlock)
+		if dbBlock.TransactionCount.V() != test.total || dbBlock.TransactionCountSystem.V() != test.system ||
+			dbBlock.TransactionCountSign.V() != test.sign || dbBlock.TransactionCountRandomize.V() != test.random ||
+			dbBlock.TransactionCountTomoX.V() != test.tomox || dbBlock.TransactionCountTomoXTradingState.V() != test.trading ||
+			dbBlock.TransactionCountLending.V() != test.lending ||
+			dbBlock.TransactionCountFinalLending.V() != test.final {
+			t.Errorf("%s: unexpected transaction counts %+v.", test.fixture, dbBlock)
+		}
+	}
+}
@@ -1,24 +1,144 @@
 package svc
 
 import (
+	"math"
 	"sync"
 	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/rpc"
 
 	"github.com/tforce-io/tf-golib/random/securerng"
 )
 
+// RetryPolicy defines how a class of RPC errors is retried.
+type RetryPolicy struct {
+	MaxRetries int
+	// Multiplier applied to the retry delay.
+	DelayFactor float64
+}
+
 type NetworkOptions struct {
-	MaxRetries  int
-	MaxRetryGap uint64
+	MaxRetries int
+	BaseDelay  time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration
+	Jitter     string
+	Deadline   time.Duration
+	Policies   map[rpc.ErrorClass]*RetryPolicy
+}
+
+// Create options from retry config with a retry policy per error class.
+// Classes missing from the config are retried with the attempts of the service and no extra delay.
+// Errors caused by the request itself (JSON-RPC errors, not found and decode errors) are never retried.
+func NewNetworkOptions(cfg *config.RetryPolicyConfig) *NetworkOptions {
+	maxRetries := cfg.MaxAttempts - 1
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	policies := map[rpc.ErrorClass]*RetryPolicy{
+		rpc.ErrorClassJsonRpc:  {MaxRetries: 0},
+		rpc.ErrorClassNotFound: {MaxRetries: 0},
+		rpc.ErrorClassDecode:   {MaxRetries: 0},
+	}
+	for name, classCfg := range cfg.Classes {
+		class := rpc.ErrorClass(name)
+		if !class.IsTransient() || classCfg == nil {
+			continue
+		}
+		policy := &RetryPolicy{MaxRetries: maxRetries, DelayFactor: classCfg.DelayFactor}
+		if classCfg.MaxAttempts > 0 {
+			policy.MaxRetries = min(classCfg.MaxAttempts-1, maxRetries)
+		}
+		if policy.DelayFactor <= 0 {
+			policy.DelayFactor = 1
+		}
+		policies[class] = policy
+	}
+	return &NetworkOptions{
+		MaxRetries: maxRetries,
+		BaseDelay:  time.Duration(cfg.BaseDelay) * time.Millisecond,
+		Multiplier: cfg.Multiplier,
+		MaxDelay:   time.Duration(cfg.MaxDelay) * time.Millisecond,
+		Jitter:     cfg.Jitter,
+		Deadline:   time.Duration(cfg.Deadline) * time.Millisecond,
+		Policies:   policies,
+	}
+}
+
+func (o *NetworkOptions) Policy(class rpc.ErrorClass) *RetryPolicy {
+	if policy, ok := o.Policies[class]; ok {
+		return policy
+	}
+	return &RetryPolicy{MaxRetries: o.MaxRetries, DelayFactor: 1}
+}
+
+// Return true if a request failed with err may be retried after retryCount retries
+// and elapsed time since the first attempt.
+func (o *NetworkOptions) CanRetry(err error, retryCount int, elapsed time.Duration) bool {
+	if err == nil {
+		return false
+	}
+	if o.Deadline > 0 && elapsed >= o.Deadline {
+		return false
+	}
+	return retryCount < o.Policy(rpc.ErrorClassOf(err)).MaxRetries
+}
+
+// Call fn until it succeeds, the retry policy of its error class is exhausted or the deadline is reached.
+// onRetry is called before every retry. Return the number of retries, time spent retrying and the last error.
+func (o *NetworkOptions) Retry(fn func() error, onRetry func(err error)) (int, time.Duration, error) {
+	start := time.Now()
+	err := fn()
+	retryStart := time.Now()
+	retryCount := 0
+	for o.CanRetry(err, retryCount, time.Since(start)) {
+		onRetry(err)
+		o.WaitRetryDelay(err, retryCount, time.Since(start))
+		err = fn()
+		retryCount++
+	}
+	if retryCount == 0 {
+		return 0, 0, err
+	}
+	return retryCount, time.Since(retryStart), err
+}
+
+// Wait before the next retry. The wait never exceeds the remaining time before the deadline.
+func (o *NetworkOptions) WaitRetryDelay(err error, retryCount int, elapsed time.Duration) {
+	delay := o.RetryDelay(err, retryCount)
+	if o.Deadline > 0 && elapsed+delay > o.Deadline {
+		delay = o.Deadline - elapsed
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Return the delay before retry number retryCount+1 with jitter applied.
+func (o *NetworkOptions) RetryDelay(err error, retryCount int) time.Duration {
+	multiplier := o.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(o.BaseDelay) * math.Pow(multiplier, float64(retryCount)) * o.Policy(rpc.ErrorClassOf(err)).DelayFactor
+	if o.MaxDelay > 0 && delay > float64(o.MaxDelay) {
+		delay = float64(o.MaxDelay)
+	}
+	return applyJitter(time.Duration(delay), o.Jitter)
 }
 
-func (o *NetworkOptions) WaitRetryGap() {
-	minRetryGap := uint64(20000000)
-	if o.MaxRetryGap < minRetryGap {
-		time.Sleep(time.Duration(minRetryGap))
+func applyJitter(delay time.Duration, jitter string) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	switch jitter {
+	case config.JitterFull:
+		return time.Duration(securerng.Uint64r(0, uint64(delay)+1))
+	case config.JitterEqual:
+		half := uint64(delay) / 2
+		return time.Duration(half + securerng.Uint64r(0, uint64(delay)-half+1))
 	}
-	duration := securerng.Uint64r(minRetryGap/2, o.MaxRetryGap)
-	time.Sleep(time.Duration(duration))
+	return delay
 }
 
 type ProcessState int8
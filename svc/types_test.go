@@ -0,0 +1,182 @@
+package svc
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"viction-rpc-crawler-go/config"
+	"viction-rpc-crawler-go/rpc"
+)
+
+func newTestRpcError(class rpc.ErrorClass) error {
+	return &rpc.RpcError{Class: class, Err: errors.New(string(class))}
+}
+
+func TestNewNetworkOptionsPolicies(t *testing.T) {
+	cfg := config.DefaultRootConfig().Service.Retry.GetBlock
+	cfg.MaxAttempts = 4
+	cfg.Classes["TIMEOUT"] = &config.ErrorClassRetryConfig{MaxAttempts: 2, DelayFactor: 3}
+	cfg.Classes["CONNECTION_REFUSED"] = &config.ErrorClassRetryConfig{MaxAttempts: 10}
+	cfg.Classes["NOT_FOUND"] = &config.ErrorClassRetryConfig{MaxAttempts: 4, DelayFactor: 1}
+	delete(cfg.Classes, "UNKNOWN")
+	o := NewNetworkOptions(cfg)
+	tests := []struct {
+		class       rpc.ErrorClass
+		maxRetries  int
+		delayFactor float64
+	}{
+		{rpc.ErrorClassRateLimited, 3, config.RateLimitedDelayFactor},
+		{rpc.ErrorClassTimeout, 1, 3},
+		{rpc.ErrorClassConnectionRefused, 3, 1},
+		{rpc.ErrorClassUnknown, 3, 1},
+		{rpc.ErrorClassJsonRpc, 0, 0},
+		{rpc.ErrorClassNotFound, 0, 0},
+		{rpc.ErrorClassDecode, 0, 0},
+	}
+	for _, test := range tests {
+		policy := o.Policy(test.class)
+		if policy.MaxRetries != test.maxRetries || (test.maxRetries > 0 && policy.DelayFactor != test.delayFactor) {
+			t.Errorf("%s: unexpected policy %+v.", test.class, policy)
+		}
+		if canRetry := o.CanRetry(newTestRpcError(test.class), 0, 0); canRetry != (test.maxRetries > 0) {
+			t.Errorf("%s: expected retry to be %t.", test.class, !canRetry)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	o := &NetworkOptions{
+		BaseDelay:  100 * time.Millisecond,
+		Multiplier: 2,
+		MaxDelay:   1000 * time.Millisecond,
+		Jitter:     config.JitterNone,
+		Policies: map[rpc.ErrorClass]*RetryPolicy{
+			rpc.ErrorClassRateLimited: {MaxRetries: 3, DelayFactor: 4},
+		},
+	}
+	tests := []struct {
+		class      rpc.ErrorClass
+		retryCount int
+		expected   time.Duration
+	}{
+		{rpc.ErrorClassUnknown, 0, 100 * time.Millisecond},
+		{rpc.ErrorClassUnknown, 1, 200 * time.Millisecond},
+		{rpc.ErrorClassUnknown, 3, 800 * time.Millisecond},
+		{rpc.ErrorClassUnknown, 4, 1000 * time.Millisecond},
+		{rpc.ErrorClassRateLimited, 0, 400 * time.Millisecond},
+		{rpc.ErrorClassRateLimited, 2, 1000 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if actual := o.RetryDelay(newTestRpcError(test.class), test.retryCount); actual != test.expected {
+			t.Errorf("%s#%d: expected delay %v, got %v.", test.class, test.retryCount, test.expected, actual)
+		}
+	}
+}
+
+func TestApplyJitter(t *testing.T) {
+	delay := 1000 * time.Millisecond
+	tests := []struct {
+		jitter string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{config.JitterNone, delay, delay},
+		{config.JitterFull, 0, delay},
+		{config.JitterEqual, delay / 2, delay},
+	}
+	for _, test := range tests {
+		for i := 0; i < 1000; i++ {
+			if actual := applyJitter(delay, test.jitter); actual < test.min || actual > test.max {
+				t.Fatalf("%s: delay %v out of range %v to %v.", test.jitter, actual, test.min, test.max)
+			}
+		}
+		if actual := applyJitter(0, test.jitter); actual != 0 {
+			t.Errorf("%s: expected zero delay, got %v.", test.jitter, actual)
+		}
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	o := &NetworkOptions{MaxRetries: 2, Deadline: time.Second}
+	err := newTestRpcError(rpc.ErrorClassTimeout)
+	tests := []struct {
+		name       string
+		err        error
+		retryCount int
+		elapsed    time.Duration
+		expected   bool
+	}{
+		{"no_error", nil, 0, 0, false},
+		{"first_retry", err, 0, 0, true},
+		{"last_retry", err, 1, 0, true},
+		{"exhausted", err, 2, 0, false},
+		{"deadline", err, 0, time.Second, false},
+	}
+	for _, test := range tests {
+		if actual := o.CanRetry(test.err, test.retryCount, test.elapsed); actual != test.expected {
+			t.Errorf("%s: expected %t, got %t.", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestWaitRetryDelay(t *testing.T) {
+	o := &NetworkOptions{
+		BaseDelay:  time.Minute,
+		Multiplier: 1,
+		Jitter:     config.JitterNone,
+		Deadline:   time.Second,
+	}
+	err := newTestRpcError(rpc.ErrorClassTimeout)
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"clamped", 950 * time.Millisecond, 50 * time.Millisecond, 500 * time.Millisecond},
+		{"past_deadline", 2 * time.Second, 0, 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		o.WaitRetryDelay(err, 0, test.elapsed)
+		if waited := time.Since(start); waited < test.min || waited > test.max {
+			t.Errorf("%s: waited %v, expected %v to %v.", test.name, waited, test.min, test.max)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failCount   int
+		calls       int
+		retryCount  int
+		failed      bool
+	}{
+		{"zero_attempts", 0, 5, 1, 0, true},
+		{"one_attempt", 1, 5, 1, 0, true},
+		{"success", 3, 0, 1, 0, false},
+		{"success_after_retry", 3, 1, 2, 1, false},
+		{"exhausted", 3, 5, 3, 2, true},
+	}
+	for _, test := range tests {
+		o := NewNetworkOptions(&config.RetryPolicyConfig{MaxAttempts: test.maxAttempts, Jitter: config.JitterNone})
+		calls, retries := 0, 0
+		retryCount, retryTime, err := o.Retry(func() error {
+			calls++
+			if calls <= test.failCount {
+				return newTestRpcError(rpc.ErrorClassTimeout)
+			}
+			return nil
+		}, func(err error) {
+			retries++
+		})
+		if calls != test.calls || retryCount != test.retryCount || retries != test.retryCount || (err != nil) != test.failed {
+			t.Errorf("%s: unexpected calls %d, retries %d, retry count %d, error %v.", test.name, calls, retries, retryCount, err)
+		}
+		if retryCount == 0 && retryTime != 0 {
+			t.Errorf("%s: expected no retry time, got %v.", test.name, retryTime)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/ethutil"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Number of blocks in one epoch. The first block of every epoch is a checkpoint block
+// which carries the signer list in its extra data.
+const EpochLength = 900
+
+const (
+	extraVanityLength = 32
+	extraSealLength   = 65
+	addressLength     = 20
+)
+
+// Signers of the epoch being verified. Signers are nil if the checkpoint block is not stored.
+type epochSigners struct {
+	checkpointNumber uint64
+	signers          [][]byte
+}
+
+type VerifyBlock struct {
+	multiplex.ServiceCore
+	i *multiplex.ServiceCoreInternal
+	// Only signers of the current epoch are kept as blocks are verified in order.
+	signers *epochSigners
+}
+
+func NewVerifyBlock(logger diag.Logger) *VerifyBlock {
+	svc := &VerifyBlock{}
+	svc.i = svc.InitServiceCore("VerifyBlock", logger, svc.coreProcessHook)
+	return svc
+}
+
+func (s *VerifyBlock) coreProcessHook(workerID uint64, msg *multiplex.ServiceMessage) *multiplex.HookState {
+	switch msg.Command {
+	case "verify_blocks":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		batchSize := msg.GetParam("batch_size", 1).(int)
+		result := s.verifyBlocks(workerID, fromBlockNumber, toBlockNumber, batchSize)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Chain verification stopped.", s.ServiceID(), workerID)
+		}
+		msg.Return(result)
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
+	}
+	return &multiplex.HookState{Handled: true}
+}
+
+// Walk stored blocks from `from` to `to` inclusively and record every integrity violation as an issue.
+// If `to` is zero, verification stops at the index checkpoint.
+func (s *VerifyBlock) verifyBlocks(workerID uint64, from, to *big.Int, batch int) *VerifyBlocksResult {
+	result := &VerifyBlocksResult{
+		From: new(big.Int).Set(from),
+		To:   new(big.Int).Set(to),
+	}
+	if result.To.Sign() == 0 {
+		checkpointRequest := multiplex.ExecParams{}
+		checkpointRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_highest_index_block", checkpointRequest)
+		checkpointResponse := checkpointRequest.WaitForReturn().(*CheckpointResult)
+		if checkpointResponse.Error != nil {
+			result.Error = checkpointResponse.Error
+			return result
+		}
+		if checkpointResponse.Number == nil {
+			result.Error = errors.New("no index checkpoint found")
+			return result
+		}
+		result.To.Set(checkpointResponse.Number)
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	s.i.Logger.Infof("%s#%d: Chain verification started. From #%d to #%d.", s.ServiceID(), workerID, result.From.Uint64(), result.To.Uint64())
+	var prevBlock *db.Block
+	if result.From.Sign() > 0 {
+		blockRequest := multiplex.ExecParams{
+			"block_number": new(big.Int).Sub(result.From, big.NewInt(1)),
+		}
+		blockRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_block", blockRequest)
+		blockResponse := blockRequest.WaitForReturn().(*DbBlockResult)
+		if blockResponse.Error != nil {
+			result.Error = blockResponse.Error
+			return result
+		}
+		prevBlock = blockResponse.Data
+	}
+	to64 := result.To.Uint64()
+	for batchStart := result.From.Uint64(); batchStart <= to64; batchStart += uint64(batch) {
+		batchEnd := min(batchStart+uint64(batch)-1, to64)
+		blocksRequest := multiplex.ExecParams{
+			"from_block_number": new(big.Int).SetUint64(batchStart),
+			"to_block_number":   new(big.Int).SetUint64(batchEnd),
+		}
+		blocksRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_blocks_range", blocksRequest)
+		blocksResponse := blocksRequest.WaitForReturn().(*DbBlocksResult)
+		if blocksResponse.Error != nil {
+			result.Error = blocksResponse.Error
+			return result
+		}
+		issues := []*db.Issue{}
+		blockHashes := map[uint64]string{}
+		for _, block := range blocksResponse.Data {
+			blockHashes[block.ID] = block.Hash
+			issues = append(issues, s.verifyBlock(block, prevBlock, result)...)
+			prevBlock = block
+		}
+		result.CheckedCount += uint64(len(blocksResponse.Data))
+
+		mismatchesRequest := multiplex.ExecParams{
+			"from_block_number": new(big.Int).SetUint64(batchStart),
+			"to_block_number":   new(big.Int).SetUint64(batchEnd),
+		}
+		mismatchesRequest.ExpectReturn()
+		s.Dispatch("ReadDatabase", "get_tx_count_mismatches", mismatchesRequest)
+		mismatchesResponse := mismatchesRequest.WaitForReturn().(*BlockGapsResult)
+		if mismatchesResponse.Error != nil {
+			result.Error = mismatchesResponse.Error
+			return result
+		}
+		for _, mismatch := range mismatchesResponse.Mismatches {
+			issues = append(issues, db.NewTxCountMismatchIssue(mismatch.BlockNumber, blockHashes[mismatch.BlockNumber], mismatch.Expected, mismatch.Actual))
+			result.TxCountMismatchCount++
+		}
+
+		if len(issues) > 0 {
+			issuesRequest := multiplex.ExecParams{
+				"issues": issues,
+			}
+			issuesRequest.ExpectReturn()
+			s.Dispatch("WriteDatabase", "save_verification_issues", issuesRequest)
+			issuesResponse := issuesRequest.WaitForReturn().(*IssuesResult)
+			if issuesResponse.Error != nil {
+				result.Error = issuesResponse.Error
+				return result
+			}
+		}
+		s.i.Logger.Infof("%s#%d: Verified block #%d to #%d. Issue count = %d.", s.ServiceID(), workerID, batchStart, batchEnd, len(issues))
+		if batchEnd == to64 {
+			break
+		}
+	}
+	return result
+}
+
+// Check a single block against its predecessor. Parent link is only checked if prevBlock directly precedes block.
+func (s *VerifyBlock) verifyBlock(block, prevBlock *db.Block, result *VerifyBlocksResult) []*db.Issue {
+	issues := []*db.Issue{}
+	if prevBlock != nil && prevBlock.ID+1 == block.ID && block.ParentHash != prevBlock.Hash {
+		issues = append(issues, db.NewBrokenParentLinkIssue(block.ID, block.Hash, block.ParentHash, prevBlock.Hash))
+		result.BrokenParentLinkCount++
+	}
+
+	ethBlock := newRpcBlockFromDb(block)
+	// Validators and penalties of checkpoint blocks indexed before these columns existed are unknown.
+	if block.ID%EpochLength == 0 && block.Validators == nil && block.Penalties == nil {
+		result.UnverifiableHashCount++
+	} else {
+		computedHash := hex.EncodeToString(ethBlock.HeaderHash())
+		if computedHash != block.Hash {
+			issues = append(issues, db.NewInvalidBlockHashIssue(block.ID, block.Hash, computedHash))
+			result.InvalidBlockHashCount++
+		}
+	}
+
+	if block.ID == 0 {
+		return issues
+	}
+	creator, reason := s.recoverCreator(ethBlock)
+	if reason == "" && block.Creator.V() != creator {
+		reason = "creator_mismatch"
+	}
+	if reason == "" {
+		signers := s.getSigners(((block.ID - 1) / EpochLength) * EpochLength)
+		if signers != nil && !containsAddress(signers, ethutil.HexToBytes(creator)) {
+			reason = "not_signer"
+		}
+	}
+	if reason != "" {
+		issues = append(issues, db.NewInvalidCreatorIssue(block.ID, block.Hash, block.Creator.V(), creator, reason))
+		result.InvalidCreatorCount++
+	}
+	return issues
+}
+
+// Recover address of block creator from the seal. Return a reason if the creator cannot be recovered.
+func (s *VerifyBlock) recoverCreator(ethBlock *rpc.Block) (string, string) {
+	extraData := ethBlock.ExtraData.Bytes()
+	if len(extraData) < extraSealLength {
+		return "", "missing_seal"
+	}
+	pubkey, err := crypto.Ecrecover(ethBlock.SigHash(), extraData[len(extraData)-extraSealLength:])
+	if err != nil {
+		return "", "unrecoverable_seal"
+	}
+	return hex.EncodeToString(ethutil.PubkeyToAddress(pubkey)), ""
+}
+
+// Return signers listed in extra data of the checkpoint block. Return nil if the checkpoint block
+// is not stored or has no signer list, in which case signer membership is not checked.
+// Lookups are cached for the current epoch including missing checkpoint blocks.
+func (s *VerifyBlock) getSigners(checkpointNumber uint64) [][]byte {
+	if s.signers != nil && s.signers.checkpointNumber == checkpointNumber {
+		return s.signers.signers
+	}
+	blockRequest := multiplex.ExecParams{
+		"block_number": new(big.Int).SetUint64(checkpointNumber),
+	}
+	blockRequest.ExpectReturn()
+	s.Dispatch("ReadDatabase", "get_block", blockRequest)
+	blockResponse := blockRequest.WaitForReturn().(*DbBlockResult)
+	if blockResponse.Error != nil {
+		return nil
+	}
+	s.signers = &epochSigners{checkpointNumber: checkpointNumber}
+	if blockResponse.Data != nil {
+		// Copy signers so extra data of the checkpoint block is not retained.
+		for _, signer := range parseSigners(blockResponse.Data.ExtraData) {
+			s.signers.signers = append(s.signers.signers, bytes.Clone(signer))
+		}
+	}
+	return s.signers.signers
+}
+
+// Extract signer addresses from extra data of a checkpoint block.
+// Extra data consists of 32 bytes vanity, 20 bytes for every signer and 65 bytes seal.
+func parseSigners(extraData []byte) [][]byte {
+	if len(extraData) <= extraVanityLength+extraSealLength {
+		return nil
+	}
+	signerBytes := extraData[extraVanityLength : len(extraData)-extraSealLength]
+	if len(signerBytes)%addressLength != 0 {
+		return nil
+	}
+	signers := make([][]byte, len(signerBytes)/addressLength)
+	for i := range signers {
+		signers[i] = signerBytes[i*addressLength : (i+1)*addressLength]
+	}
+	return signers
+}
+
+func containsAddress(addresses [][]byte, address []byte) bool {
+	for _, addr := range addresses {
+		if bytes.Equal(addr, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func newRpcBlockFromDb(block *db.Block) *rpc.Block {
+	return &rpc.Block{
+		Number:           rpc.NewUint256(new(big.Int).SetUint64(block.ID)),
+		Hash:             rpc.NewHex(ethutil.HexToBytes(block.Hash)),
+		Timestamp:        rpc.NewUint256(big.NewInt(block.Timestamp)),
+		Size:             rpc.NewUint64(uint64(block.Size)),
+		GasLimit:         rpc.NewUint64(block.GasLimit),
+		GasUsed:          rpc.NewUint64(block.GasUsed),
+		Difficulty:       rpc.NewUint256(block.Difficulty.BigInt()),
+		TotalDifficulty:  rpc.NewUint256(block.TotalDifficulty.BigInt()),
+		Nonce:            rpc.NewHex(block.Nonce),
+		ExtraData:        rpc.NewHex(block.ExtraData),
+		LogsBloom:        rpc.NewHex(block.LogsBloom),
+		ParentHash:       rpc.NewHex(ethutil.HexToBytes(block.ParentHash)),
+		StateRoot:        rpc.NewHex(block.StateRoot),
+		TransactionsRoot: rpc.NewHex(block.TransactionsRoot),
+		ReceiptsRoot:     rpc.NewHex(block.ReceiptsRoot),
+		Sha3Uncles:       rpc.NewHex(block.UncleHash),
+		MixDigest:        rpc.NewHex(block.MixDigest),
+		Miner:            rpc.NewHex(block.Miner),
+		Validator:        rpc.NewHex(block.Validator),
+		Validators:       rpc.NewHex(block.Validators),
+		Penalties:        rpc.NewHex(block.Penalties),
+	}
+}
+
+type VerifyBlocksResult struct {
+	From                  *big.Int
+	To                    *big.Int
+	CheckedCount          uint64
+	BrokenParentLinkCount uint64
+	InvalidBlockHashCount uint64
+	InvalidCreatorCount   uint64
+	TxCountMismatchCount  uint64
+	UnverifiableHashCount uint64
+	Error                 error
+}
+
+func (r *VerifyBlocksResult) IssueCount() uint64 {
+	return r.BrokenParentLinkCount + r.InvalidBlockHashCount + r.InvalidCreatorCount + r.TxCountMismatchCount
+}
+
+type IssuesResult struct {
+	Data  []*db.Issue
+	Error error
+}
@@ -0,0 +1,165 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+	"viction-rpc-crawler-go/db"
+	"viction-rpc-crawler-go/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/tforce-io/tf-golib/diag"
+	"github.com/tforce-io/tf-golib/multiplex"
+)
+
+// Header layout of Viction, i.e. Ethereum header before London followed by masternode fields.
+type victionHeader struct {
+	ParentHash  common.Hash
+	UncleHash   common.Hash
+	Coinbase    common.Address
+	Root        common.Hash
+	TxHash      common.Hash
+	ReceiptHash common.Hash
+	Bloom       types.Bloom
+	Difficulty  *big.Int
+	Number      *big.Int
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        *big.Int
+	Extra       []byte
+	MixDigest   common.Hash
+	Nonce       types.BlockNonce
+	Validators  []byte
+	Validator   []byte
+	Penalties   []byte
+}
+
+func TestRecoverCreator(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	creator := crypto.PubkeyToAddress(key.PublicKey)
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	header := &victionHeader{
+		ParentHash:  common.HexToHash("0x5a1b8f0c1e7c0a3e07d8d0cb9d46ad9f3a0f0f5ae7a34c5d9e1c3bd3f1e2a4b6"),
+		UncleHash:   types.EmptyUncleHash,
+		Root:        common.HexToHash("0x8c1c8f0d9e2b3a4f5e6d7c8b9a0f1e2d3c4b5a69788796a5b4c3d2e1f0a1b2c3"),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(1),
+		Number:      big.NewInt(86400000),
+		GasLimit:    420000000,
+		Time:        big.NewInt(1700000000),
+		Validators:  []byte("00000002"),
+		Validator:   bytes.Repeat([]byte{0x77}, extraSealLength),
+		Penalties:   other.Bytes(),
+	}
+	header.Extra = make([]byte, extraVanityLength)
+	header.Extra = append(header.Extra, creator.Bytes()...)
+	header.Extra = append(header.Extra, other.Bytes()...)
+	header.Extra = append(header.Extra, make([]byte, extraSealLength)...)
+
+	sigHash := rlpHash(t, []interface{}{
+		header.ParentHash, header.UncleHash, header.Coinbase, header.Root, header.TxHash, header.ReceiptHash, header.Bloom,
+		header.Difficulty, header.Number, header.GasLimit, header.GasUsed, header.Time,
+		header.Extra[:len(header.Extra)-extraSealLength], header.MixDigest, header.Nonce,
+	})
+	seal, err := crypto.Sign(sigHash, key)
+	if err != nil {
+		t.Fatalf("Error while sealing header. %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-extraSealLength:], seal)
+	block := newTestRpcBlock(t, header)
+
+	if !bytes.Equal(block.SigHash(), sigHash) {
+		t.Fatalf("Sig hash mismatch. Expected '%x' Actual '%x'", sigHash, block.SigHash())
+	}
+	headerHash := rlpHash(t, header)
+	if !bytes.Equal(block.HeaderHash(), headerHash) {
+		t.Fatalf("Header hash mismatch. Expected '%x' Actual '%x'", headerHash, block.HeaderHash())
+	}
+	recoveredCreator, reason := (&VerifyBlock{}).recoverCreator(block)
+	if reason != "" || recoveredCreator != hex.EncodeToString(creator.Bytes()) {
+		t.Fatalf("Creator mismatch. Expected '%x' Actual '%s'. %s", creator, recoveredCreator, reason)
+	}
+	signers := parseSigners(block.ExtraData.Bytes())
+	if len(signers) != 2 || !bytes.Equal(signers[0], creator.Bytes()) || !bytes.Equal(signers[1], other.Bytes()) {
+		t.Fatalf("Signers mismatch. %x", signers)
+	}
+
+	block.ExtraData = rpc.NewHex(header.Extra[:extraVanityLength+extraSealLength-1])
+	if _, reason := (&VerifyBlock{}).recoverCreator(block); reason != "unrecoverable_seal" {
+		t.Fatalf("Reason mismatch. Expected 'unrecoverable_seal' Actual '%s'", reason)
+	}
+}
+
+func TestGetSigners(t *testing.T) {
+	signer := bytes.Repeat([]byte{0x11}, addressLength)
+	extraData := make([]byte, extraVanityLength)
+	extraData = append(extraData, signer...)
+	extraData = append(extraData, make([]byte, extraSealLength)...)
+	requests := []uint64{}
+	router := multiplex.NewServiceController(diag.NewDebugLogger(10))
+	router.Run(false)
+	newStubService("ReadDatabase", router, func(msg *multiplex.ServiceMessage) interface{} {
+		number := msg.GetParam("block_number", new(big.Int)).(*big.Int).Uint64()
+		requests = append(requests, number)
+		result := &DbBlockResult{Number: new(big.Int).SetUint64(number)}
+		if number == 900 {
+			result.Data = &db.Block{ID: number, ExtraData: extraData}
+		}
+		return result
+	})
+	svc := NewVerifyBlock(diag.NewDebugLogger(10))
+	svc.SetRouter(router)
+
+	tests := []struct {
+		checkpointNumber uint64
+		found            bool
+		requests         []uint64
+	}{
+		{900, true, []uint64{900}},
+		{900, true, []uint64{900}},
+		{1800, false, []uint64{900, 1800}},
+		{1800, false, []uint64{900, 1800}},
+		{900, true, []uint64{900, 1800, 900}},
+	}
+	for i, test := range tests {
+		signers := svc.getSigners(test.checkpointNumber)
+		if (signers != nil) != test.found || !slices.Equal(requests, test.requests) {
+			t.Fatalf("Lookup #%d mismatch. Signers %x. Requests %v", i, signers, requests)
+		}
+	}
+	extraData[extraVanityLength] = 0x22
+	if signers := svc.getSigners(900); len(signers) != 1 || !bytes.Equal(signers[0], signer) {
+		t.Fatalf("Signers must not share memory with extra data. %x", signers)
+	}
+}
+
+func rlpHash(t *testing.T, v interface{}) []byte {
+	data, err := rlp.EncodeToBytes(v)
+	if err != nil {
+		t.Fatalf("Error while encoding header. %v", err)
+	}
+	return crypto.Keccak256(data)
+}
+
+func newTestRpcBlock(t *testing.T, header *victionHeader) *rpc.Block {
+	rawBlock := fmt.Sprintf(`{"number":"0x%x","hash":"0x%x","parentHash":"0x%x","sha3Uncles":"0x%x","miner":"0x%x",
+		"stateRoot":"0x%x","transactionsRoot":"0x%x","receiptsRoot":"0x%x","logsBloom":"0x%x","difficulty":"0x%x",
+		"gasLimit":"0x%x","gasUsed":"0x%x","timestamp":"0x%x","extraData":"0x%x","mixHash":"0x%x","nonce":"0x%x",
+		"validators":"0x%x","validator":"0x%x","penalties":"0x%x"}`,
+		header.Number, rlpHash(t, header), header.ParentHash, header.UncleHash, header.Coinbase,
+		header.Root, header.TxHash, header.ReceiptHash, header.Bloom, header.Difficulty,
+		header.GasLimit, header.GasUsed, header.Time, header.Extra, header.MixDigest, header.Nonce,
+		header.Validators, header.Validator, header.Penalties)
+	block, err := rpc.DecodeBlock([]byte(rawBlock))
+	if err != nil {
+		t.Fatalf("Error while decoding block. %v", err)
+	}
+	return block
+}
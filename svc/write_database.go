@@ -2,7 +2,8 @@ package svc
 
 import (
 	"encoding/hex"
-	"slices"
+	"errors"
+	"math/big"
 	"viction-rpc-crawler-go/db"
 	"viction-rpc-crawler-go/ethutil"
 	"viction-rpc-crawler-go/rpc"
@@ -13,15 +14,6 @@ import (
 	"github.com/tforce-io/tf-golib/multiplex"
 )
 
-var SystemAddresses = []string{
-	"0000000000000000000000000000000000000089", // Sign block
-	"0000000000000000000000000000000000000090", // Randomize
-	"0000000000000000000000000000000000000091", // TomoX
-	"0000000000000000000000000000000000000092", // TomoXTradingState
-	"0000000000000000000000000000000000000093", // TomoXLending
-	"0000000000000000000000000000000000000094", // TomoXFinalLending
-}
-
 type WriteDatabase struct {
 	multiplex.ServiceCore
 	i  *multiplex.ServiceCoreInternal
@@ -40,50 +32,291 @@ func (s *WriteDatabase) coreProcessHook(workerID uint64, msg *multiplex.ServiceM
 	switch msg.Command {
 	case "write_blocks":
 		blocks := msg.GetParam("blocks", []*rpc.Block{}).([]*rpc.Block)
-		batchData, err := s.prepareBatchData(blocks)
+		includeTxs := msg.GetParam("include_txs", true).(bool)
+		result := s.writeBlocks(blocks, includeTxs)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Failed to write %d blocks.", s.ServiceID(), workerID, len(blocks))
+		}
+		msg.Return(result)
+	case "revert_blocks":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		issues := msg.GetParam("issues", []*db.Issue{}).([]*db.Issue)
+		err := s.db.RevertBlocks(blockNumber.Uint64(), issues)
 		if err != nil {
-			panic(err)
+			s.i.Logger.Errorf(err, "%s#%d: Failed to revert blocks above #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
 		}
-		if len(batchData.NewBlocks)+len(batchData.ChangedBlocks) > 0 {
-			err = s.db.SaveBlocks(batchData.NewBlocks, batchData.ChangedBlocks)
-			if err != nil {
-				panic(err)
-			}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "rollback_blocks":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		removeIssues := msg.GetParam("remove_issues", false).(bool)
+		err := s.db.RollbackBlocks(blockNumber.Uint64(), removeIssues)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to roll back blocks above #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
 		}
-		if len(batchData.NewTxs)+len(batchData.ChangedTxs) > 0 {
-			err = s.db.SaveTransactions(batchData.NewTxs, batchData.ChangedTxs)
-			if err != nil {
-				panic(err)
-			}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "save_highest_index_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestIndexBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save index checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "save_highest_import_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestImportBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save import checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "save_highest_trace_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestTraceBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save trace checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "write_internal_transactions":
+		txCounts := msg.GetParam("tx_counts", map[uint64]uint16{}).(map[uint64]uint16)
+		internalTxs := msg.GetParam("internal_txs", []*db.InternalTransaction{}).([]*db.InternalTransaction)
+		err := s.db.SaveInternalTransactions(txCounts, internalTxs)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to write internal transactions of %d blocks.", s.ServiceID(), workerID, len(txCounts))
+		}
+		msg.Return(&WriteBlocksResult{
+			BlockCount: len(txCounts),
+			TxCount:    len(internalTxs),
+			Error:      err,
+		})
+	case "write_receipts":
+		blockReceipts := msg.GetParam("block_receipts", []*GetReceiptsResult{}).([]*GetReceiptsResult)
+		result := s.writeReceipts(blockReceipts)
+		if result.Error != nil {
+			s.i.Logger.Errorf(result.Error, "%s#%d: Failed to write receipts of %d blocks.", s.ServiceID(), workerID, len(blockReceipts))
+		}
+		msg.Return(result)
+	case "save_highest_receipt_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestReceiptBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save receipt checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "write_token_transfers":
+		fromBlockNumber := msg.GetParam("from_block_number", new(big.Int)).(*big.Int)
+		toBlockNumber := msg.GetParam("to_block_number", new(big.Int)).(*big.Int)
+		transfers := msg.GetParam("transfers", []*db.TokenTransfer{}).([]*db.TokenTransfer)
+		tokens := msg.GetParam("tokens", []*db.Token{}).([]*db.Token)
+		err := s.db.SaveTokenTransfers(fromBlockNumber.Uint64(), toBlockNumber.Uint64(), transfers, tokens)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to write token transfers of block #%d to #%d.", s.ServiceID(), workerID, fromBlockNumber.Uint64(), toBlockNumber.Uint64())
+		}
+		msg.Return(&WriteBlocksResult{
+			BlockCount: int(toBlockNumber.Uint64() - fromBlockNumber.Uint64() + 1),
+			TxCount:    len(transfers),
+			Error:      err,
+		})
+	case "save_highest_token_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestTokenBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save token checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "write_validator_stats":
+		epochIDs := msg.GetParam("epoch_ids", []uint64{}).([]uint64)
+		stats := msg.GetParam("stats", []*db.ValidatorStat{}).([]*db.ValidatorStat)
+		missedSlots := msg.GetParam("missed_slots", []*db.MissedSlot{}).([]*db.MissedSlot)
+		err := s.db.SaveValidatorStats(epochIDs, stats, missedSlots)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to write validator statistics of %d epochs.", s.ServiceID(), workerID, len(epochIDs))
 		}
-		if len(batchData.Issues) > 0 {
-			err = s.db.SaveIssues(batchData.Issues)
-			if err != nil {
-				panic(err)
+		msg.Return(&WriteBlocksResult{
+			Error: err,
+		})
+	case "save_highest_validator_block":
+		blockNumber := msg.GetParam("block_number", new(big.Int)).(*big.Int)
+		err := s.db.SaveHighestValidatorBlock(blockNumber)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save validator checkpoint #%d.", s.ServiceID(), workerID, blockNumber.Uint64())
+		}
+		msg.Return(&CheckpointResult{
+			Number: blockNumber,
+			Error:  err,
+		})
+	case "save_failed_blocks":
+		failedBlocks := msg.GetParam("failed_blocks", []*FailedBlock{}).([]*FailedBlock)
+		issues := make([]*db.Issue, len(failedBlocks))
+		for j, failedBlock := range failedBlocks {
+			issues[j] = s.db.NewFailedBlockIssue(failedBlock.Category, failedBlock.Number.Uint64(), string(failedBlock.ErrorClass), errors.New(failedBlock.Error))
+		}
+		err := s.db.SaveFailedBlockIssues(issues)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save %d failed blocks.", s.ServiceID(), workerID, len(failedBlocks))
+		}
+		msg.Return(&FailedBlocksResult{
+			Data:  failedBlocks,
+			Error: err,
+		})
+	case "resolve_failed_blocks":
+		failedBlocks := msg.GetParam("failed_blocks", []*FailedBlock{}).([]*FailedBlock)
+		issueIDs := []uint64{}
+		for _, failedBlock := range failedBlocks {
+			if failedBlock.IssueID > 0 {
+				issueIDs = append(issueIDs, failedBlock.IssueID)
 			}
 		}
+		err := s.db.ResolveIssues(issueIDs)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to resolve %d failed blocks.", s.ServiceID(), workerID, len(failedBlocks))
+		}
+		msg.Return(&FailedBlocksResult{
+			Data:  failedBlocks,
+			Error: err,
+		})
+	case "save_verification_issues":
+		issues := msg.GetParam("issues", []*db.Issue{}).([]*db.Issue)
+		err := s.db.SaveVerificationIssues(issues)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to save %d verification issues.", s.ServiceID(), workerID, len(issues))
+		}
+		msg.Return(&IssuesResult{
+			Data:  issues,
+			Error: err,
+		})
+	case "resolve_issues":
+		issues := msg.GetParam("issues", []*db.Issue{}).([]*db.Issue)
+		issueIDs := make([]uint64, len(issues))
+		for j, issue := range issues {
+			issueIDs[j] = issue.ID
+		}
+		err := s.db.ResolveIssues(issueIDs)
 		if err != nil {
-			panic(err)
+			s.i.Logger.Errorf(err, "%s#%d: Failed to resolve %d issues.", s.ServiceID(), workerID, len(issues))
 		}
-		defer msg.Return(nil)
+		msg.Return(&IssuesResult{
+			Data:  issues,
+			Error: err,
+		})
+	default:
+		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
+		msg.Return(nil)
 	}
 	return &multiplex.HookState{Handled: true}
 }
 
+func (s *WriteDatabase) writeBlocks(blocks []*rpc.Block, includeTxs bool) *WriteBlocksResult {
+	result := &WriteBlocksResult{}
+	batchData, err := s.prepareBatchData(blocks)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	// Signatures are replaced even if none are found so signatures of reorganized blocks are removed.
+	blockData := &db.BlockData{
+		Epochs:          batchData.Epochs,
+		EpochValidators: batchData.EpochValidators,
+		EpochPenalties:  batchData.EpochPenalties,
+	}
+	if includeTxs {
+		blockData.SignatureBlockIDs = batchData.BlockIDs
+		blockData.Signatures = batchData.Signatures
+	}
+	if len(batchData.NewBlocks)+len(batchData.ChangedBlocks)+len(blockData.SignatureBlockIDs)+len(blockData.Epochs) > 0 {
+		err = s.db.SaveBlocks(batchData.NewBlocks, batchData.ChangedBlocks, blockData)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.BlockCount = len(batchData.NewBlocks) + len(batchData.ChangedBlocks)
+	}
+	if includeTxs && len(batchData.NewTxs)+len(batchData.ChangedTxs) > 0 {
+		err = s.db.SaveTransactions(batchData.NewTxs, batchData.ChangedTxs)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.TxCount = len(batchData.NewTxs) + len(batchData.ChangedTxs)
+	}
+	if len(batchData.Issues) > 0 {
+		err = s.db.SaveIssues(batchData.Issues)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.IssueCount = len(batchData.Issues)
+	}
+	return result
+}
+
+// Replace receipts and logs of blocks. TxCount of the result is number of receipts.
+func (s *WriteDatabase) writeReceipts(blockReceipts []*GetReceiptsResult) *WriteBlocksResult {
+	result := &WriteBlocksResult{}
+	blockIDs := make([]uint64, len(blockReceipts))
+	receipts := []*db.Receipt{}
+	logs := []*db.Log{}
+	issues := []*db.Issue{}
+	for i, blockReceipt := range blockReceipts {
+		blockIDs[i] = blockReceipt.Number.Uint64()
+		issues = append(issues, blockReceipt.Issues...)
+		for _, ethReceipt := range blockReceipt.Data {
+			receipt := &db.Receipt{}
+			s.copyReceiptProperties(ethReceipt, receipt)
+			receipts = append(receipts, receipt)
+			for _, ethLog := range ethReceipt.Logs {
+				log := &db.Log{}
+				s.copyLogProperties(ethLog, log)
+				logs = append(logs, log)
+			}
+		}
+	}
+	result.Error = s.db.SaveReceipts(blockIDs, receipts, logs, issues)
+	if result.Error == nil {
+		result.BlockCount = len(blockIDs)
+		result.TxCount = len(receipts)
+		result.IssueCount = len(issues)
+	}
+	return result
+}
+
 func (s *WriteDatabase) prepareBatchData(blocks []*rpc.Block) (*BlockBatchData, error) {
 	result := &BlockBatchData{
-		NewBlocks:     []*db.Block{},
-		ChangedBlocks: []*db.Block{},
-		NewTxs:        []*db.Transaction{},
-		ChangedTxs:    []*db.Transaction{},
-		Issues:        []*db.Issue{},
+		NewBlocks:       []*db.Block{},
+		ChangedBlocks:   []*db.Block{},
+		NewTxs:          []*db.Transaction{},
+		ChangedTxs:      []*db.Transaction{},
+		Epochs:          []*db.Epoch{},
+		EpochValidators: []*db.EpochValidator{},
+		EpochPenalties:  []*db.EpochPenalty{},
+		Issues:          []*db.Issue{},
 	}
 
-	blockHashes := []string{}
+	blockNumbers := []uint64{}
 	txHashes := []string{}
+	signatures := []*db.BlockSignature{}
 	issues := []*db.Issue{}
 	for _, block := range blocks {
-		blockHashes = append(blockHashes, block.Hash.Hex())
+		blockNumbers = append(blockNumbers, block.Number.Int())
 		for _, tx := range block.Transactions {
 			txHashes = append(txHashes, tx.Hash.Hex())
 		}
@@ -91,7 +324,7 @@ func (s *WriteDatabase) prepareBatchData(blocks []*rpc.Block) (*BlockBatchData,
 
 	newBlockMap := make(map[uint64]*db.Block)
 	changedBlockMap := make(map[uint64]*db.Block)
-	changedBlocks, err := s.db.GetBlocksByHashes(blockHashes)
+	changedBlocks, err := s.db.GetBlocks(blockNumbers)
 	if err != nil {
 		return nil, err
 	}
@@ -110,16 +343,16 @@ func (s *WriteDatabase) prepareBatchData(blocks []*rpc.Block) (*BlockBatchData,
 	for _, block := range blocks {
 		blockNumber := block.Number.BigInt()
 		blockHash := block.Hash.Hex()
-		txCount := uint16(len(block.Transactions))
-		systemTxCount := uint16(0)
+		txCounts := CountTransactions(block)
+		if epoch, validators, penalties := DecodeEpoch(block); epoch != nil {
+			result.Epochs = append(result.Epochs, epoch)
+			result.EpochValidators = append(result.EpochValidators, validators...)
+			result.EpochPenalties = append(result.EpochPenalties, penalties...)
+		}
 		for _, tx := range block.Transactions {
 			txHash := tx.Hash.Hex()
-			toAddress := ""
-			if tx.To != nil {
-				toAddress = tx.To.Hex()
-			}
-			if slices.Contains(SystemAddresses, toAddress) {
-				systemTxCount += 1
+			if signature := DecodeSignTransaction(tx, block); signature != nil {
+				signatures = append(signatures, signature)
 			}
 			if ctx, ok := changedTxMap[txHash]; ok {
 				if ctx.BlockID != blockNumber.Uint64() {
@@ -146,22 +379,19 @@ func (s *WriteDatabase) prepareBatchData(blocks []*rpc.Block) (*BlockBatchData,
 				issues = append(issues, issue)
 			}
 			s.copyBlockProperties(block, cblock)
-			cblock.TransactionCount.Scan(&txCount)
-			cblock.TransactionCountSystem.Scan(&systemTxCount)
+			txCounts.copyTo(cblock)
 		} else if nblock, ok := newBlockMap[blockNumber.Uint64()]; ok {
 			if nblock.Hash != blockHash {
-				issue := db.NewReorgBlockIssue(blockNumber.Uint64(), cblock.Hash, blockHash)
+				issue := db.NewReorgBlockIssue(blockNumber.Uint64(), nblock.Hash, blockHash)
 				issues = append(issues, issue)
 			}
 			s.copyBlockProperties(block, nblock)
-			nblock.TransactionCount.Scan(&txCount)
-			nblock.TransactionCountSystem.Scan(&systemTxCount)
+			txCounts.copyTo(nblock)
 		} else {
 			nblock := &db.Block{ID: blockNumber.Uint64()}
 			s.copyBlockProperties(block, nblock)
 			newBlockMap[blockNumber.Uint64()] = nblock
-			nblock.TransactionCount.Scan(&txCount)
-			nblock.TransactionCountSystem.Scan(&systemTxCount)
+			txCounts.copyTo(nblock)
 		}
 	}
 	for _, block := range newBlockMap {
@@ -176,11 +406,17 @@ func (s *WriteDatabase) prepareBatchData(blocks []*rpc.Block) (*BlockBatchData,
 	for _, tx := range changedTxMap {
 		result.ChangedTxs = append(result.ChangedTxs, tx)
 	}
+	result.BlockIDs = blockNumbers
+	result.Signatures = signatures
 	result.Issues = issues
 	return result, err
 }
 
 func (s *WriteDatabase) copyBlockProperties(ethBlock *rpc.Block, dbBlock *db.Block) {
+	// Debug transaction count is filled by trace indexing and only kept while the block is unchanged.
+	if dbBlock.Hash != ethBlock.Hash.Hex() {
+		dbBlock.TransactionCountDebug = typ.NullUint16{}
+	}
 	dbBlock.Hash = ethBlock.Hash.Hex()
 	dbBlock.ParentHash = ethBlock.ParentHash.Hex()
 	dbBlock.Timestamp = int64(ethBlock.Timestamp.Int())
@@ -191,7 +427,12 @@ func (s *WriteDatabase) copyBlockProperties(ethBlock *rpc.Block, dbBlock *db.Blo
 	dbBlock.TotalDifficulty = ethBlock.TotalDifficulty.Decimal()
 	dbBlock.TransactionCount = typ.NullUint16{}
 	dbBlock.TransactionCountSystem = typ.NullUint16{}
-	dbBlock.TransactionCountDebug = typ.NullUint16{}
+	dbBlock.TransactionCountSign = typ.NullUint16{}
+	dbBlock.TransactionCountRandomize = typ.NullUint16{}
+	dbBlock.TransactionCountTomoX = typ.NullUint16{}
+	dbBlock.TransactionCountTomoXTradingState = typ.NullUint16{}
+	dbBlock.TransactionCountLending = typ.NullUint16{}
+	dbBlock.TransactionCountFinalLending = typ.NullUint16{}
 	dbBlock.BlockMintDuration = typ.NullUint64{}
 	dbBlock.UncleHash = ethBlock.Sha3Uncles.Bytes()
 	dbBlock.StateRoot = ethBlock.StateRoot.Bytes()
@@ -203,6 +444,8 @@ func (s *WriteDatabase) copyBlockProperties(ethBlock *rpc.Block, dbBlock *db.Blo
 	dbBlock.MixDigest = ethBlock.MixDigest.Bytes()
 	dbBlock.Nonce = ethBlock.Nonce.Bytes()
 	dbBlock.Validator = ethBlock.Validator.Bytes()
+	dbBlock.Validators = ethBlock.Validators.Bytes()
+	dbBlock.Penalties = ethBlock.Penalties.Bytes()
 	dbBlock.Creator = typ.NullString{}
 	dbBlock.Attestor = typ.NullString{}
 	signatureLength := 65
@@ -236,6 +479,43 @@ func (s *WriteDatabase) copyTransactionProperties(ethTransaction *rpc.Transactio
 	dbTransaction.Nonce = ethTransaction.Nonce.Int()
 	dbTransaction.Gas = ethTransaction.Gas.Int()
 	dbTransaction.GasPrice = ethTransaction.GasPrice.Decimal()
+	dbTransaction.Category = typ.NUint16(ClassifyTransaction(ethTransaction))
+}
+
+func (s *WriteDatabase) copyReceiptProperties(ethReceipt *rpc.Receipt, dbReceipt *db.Receipt) {
+	dbReceipt.TransactionHash = ethReceipt.TransactionHash.Hex()
+	dbReceipt.BlockID = ethReceipt.BlockNumber.Int()
+	dbReceipt.BlockHash = ethReceipt.BlockHash.Hex()
+	dbReceipt.TransactionIndex = uint16(ethReceipt.TransactionIndex.Int())
+	dbReceipt.CumulativeGasUsed = ethReceipt.CumulativeGasUsed.Int()
+	dbReceipt.GasUsed = ethReceipt.GasUsed.Int()
+	dbReceipt.Status = typ.NullUint16{}
+	if ethReceipt.Status != nil {
+		status := uint16(ethReceipt.Status.Int())
+		dbReceipt.Status.Scan(&status)
+	}
+	dbReceipt.ContractAddress = ""
+	if ethReceipt.ContractAddress != nil {
+		dbReceipt.ContractAddress = ethReceipt.ContractAddress.Hex()
+	}
+	dbReceipt.LogCount = uint16(len(ethReceipt.Logs))
+}
+
+func (s *WriteDatabase) copyLogProperties(ethLog *rpc.Log, dbLog *db.Log) {
+	dbLog.BlockID = ethLog.BlockNumber.Int()
+	dbLog.BlockHash = ethLog.BlockHash.Hex()
+	dbLog.TransactionHash = ethLog.TransactionHash.Hex()
+	dbLog.TransactionIndex = uint16(ethLog.TransactionIndex.Int())
+	dbLog.LogIndex = uint16(ethLog.LogIndex.Int())
+	dbLog.Address = ethLog.Address.Hex()
+	topics := make([]string, 4)
+	for i, topic := range ethLog.Topics {
+		if i < len(topics) {
+			topics[i] = topic.Hex()
+		}
+	}
+	dbLog.Topic0, dbLog.Topic1, dbLog.Topic2, dbLog.Topic3 = topics[0], topics[1], topics[2], topics[3]
+	dbLog.Data = ethLog.Data.Bytes()
 }
 
 type BlockBatchData struct {
@@ -243,5 +523,18 @@ type BlockBatchData struct {
 	ChangedBlocks []*db.Block
 	NewTxs        []*db.Transaction
 	ChangedTxs    []*db.Transaction
-	Issues        []*db.Issue
+	BlockIDs      []uint64
+	Signatures    []*db.BlockSignature
+	// Epochs opened by checkpoint blocks of the batch.
+	Epochs          []*db.Epoch
+	EpochValidators []*db.EpochValidator
+	EpochPenalties  []*db.EpochPenalty
+	Issues          []*db.Issue
+}
+
+type WriteBlocksResult struct {
+	BlockCount int
+	TxCount    int
+	IssueCount int
+	Error      error
 }
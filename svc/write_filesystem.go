@@ -1,8 +1,11 @@
 package svc
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"os"
 	"path/filepath"
 	"viction-rpc-crawler-go/filesystem"
 
@@ -10,6 +13,12 @@ import (
 	"github.com/tforce-io/tf-golib/multiplex"
 )
 
+// Directories under root where responses of each RPC method are stored.
+const (
+	BlockCategory      = "getBlockByNumber"
+	TraceBlockCategory = "traceBlockByNumber"
+)
+
 type WriteFileSystem struct {
 	multiplex.ServiceCore
 	i *multiplex.ServiceCoreInternal
@@ -31,10 +40,8 @@ func (s *WriteFileSystem) coreProcessHook(workerID uint64, msg *multiplex.Servic
 			msg.Return(nil)
 			break
 		}
-		outputDir := filepath.Join(rootDir, "getBlockByNumber")
 		for _, blockData := range blockDatas {
-			midDirs := GetNumberedDir(blockData.Number)
-			blockFile := filepath.Join(outputDir, midDirs[0], midDirs[1], blockData.Number.String()+".json")
+			blockFile := GetNumberedFile(rootDir, BlockCategory, blockData.Number)
 			err := filesystem.WriteFile(blockFile, []byte(blockData.RawData))
 			if err != nil {
 				s.i.Logger.Errorf(err, "%s#%d: Failed to write block file #%d.", s.ServiceID(), workerID, blockData.Number.Uint64())
@@ -50,10 +57,8 @@ func (s *WriteFileSystem) coreProcessHook(workerID uint64, msg *multiplex.Servic
 			msg.Return(nil)
 			break
 		}
-		outputDir := filepath.Join(rootDir, "traceBlockByNumber")
 		for _, blockTrace := range blockTraces {
-			midDirs := GetNumberedDir(blockTrace.Number)
-			blockTraceFile := filepath.Join(outputDir, midDirs[0], midDirs[1], blockTrace.Number.String()+".json")
+			blockTraceFile := GetNumberedFile(rootDir, TraceBlockCategory, blockTrace.Number)
 			err := filesystem.WriteFile(blockTraceFile, []byte(blockTrace.RawData))
 			if err != nil {
 				s.i.Logger.Errorf(err, "%s#%d: Failed to write block trace file #%d.", s.ServiceID(), workerID, blockTrace.Number.Uint64())
@@ -61,6 +66,28 @@ func (s *WriteFileSystem) coreProcessHook(workerID uint64, msg *multiplex.Servic
 			}
 		}
 		msg.Return(true)
+	case "record_failed_blocks":
+		failedBlocks := msg.GetParam("failed_blocks", []*FailedBlock{}).([]*FailedBlock)
+		rootDir := msg.GetParam("root", "").(string)
+		err := s.updateFailureLedger(rootDir, failedBlocks, nil)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to record %d failed blocks.", s.ServiceID(), workerID, len(failedBlocks))
+		}
+		msg.Return(&FailedBlocksResult{
+			Data:  failedBlocks,
+			Error: err,
+		})
+	case "resolve_failed_blocks":
+		failedBlocks := msg.GetParam("failed_blocks", []*FailedBlock{}).([]*FailedBlock)
+		rootDir := msg.GetParam("root", "").(string)
+		err := s.updateFailureLedger(rootDir, nil, failedBlocks)
+		if err != nil {
+			s.i.Logger.Errorf(err, "%s#%d: Failed to resolve %d failed blocks.", s.ServiceID(), workerID, len(failedBlocks))
+		}
+		msg.Return(&FailedBlocksResult{
+			Data:  failedBlocks,
+			Error: err,
+		})
 	default:
 		s.i.Logger.Warnf("%s#%d: Unknown command %s.", s.i.ServiceID, workerID, msg.Command)
 		msg.Return(nil)
@@ -68,6 +95,47 @@ func (s *WriteFileSystem) coreProcessHook(workerID uint64, msg *multiplex.Servic
 	return &multiplex.HookState{Handled: true}
 }
 
+// Add recorded blocks to the failure ledger and remove resolved ones. A block recorded again replaces its previous entry.
+func (s *WriteFileSystem) updateFailureLedger(rootDir string, recorded, resolved []*FailedBlock) error {
+	if rootDir == "" {
+		return errors.New("root dir is empty")
+	}
+	failedBlocks, err := ReadFailureLedger(rootDir)
+	if err != nil {
+		return err
+	}
+	entries := map[string]*FailedBlock{}
+	keys := []string{}
+	for _, failedBlock := range append(failedBlocks, recorded...) {
+		key := failedBlock.Key()
+		if _, ok := entries[key]; !ok {
+			keys = append(keys, key)
+		}
+		entries[key] = failedBlock
+	}
+	for _, failedBlock := range resolved {
+		delete(entries, failedBlock.Key())
+	}
+	remaining := []*FailedBlock{}
+	for _, key := range keys {
+		if failedBlock, ok := entries[key]; ok {
+			remaining = append(remaining, failedBlock)
+		}
+	}
+	data, err := json.MarshalIndent(remaining, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial ledger.
+	ledgerFile := GetFailureLedgerFile(rootDir)
+	tempFile := ledgerFile + ".tmp"
+	err = filesystem.WriteFile(tempFile, data)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile, ledgerFile)
+}
+
 func GetNumberedDir(number *big.Int) []string {
 	paddedNumber := fmt.Sprintf("%09d", number.Uint64())
 	length := len(paddedNumber)
@@ -76,3 +144,8 @@ func GetNumberedDir(number *big.Int) []string {
 	thirdLevel := paddedNumber[length-3 : length]
 	return []string{firstLevel, secondLevel, thirdLevel}
 }
+
+func GetNumberedFile(rootDir, category string, number *big.Int) string {
+	midDirs := GetNumberedDir(number)
+	return filepath.Join(rootDir, category, midDirs[0], midDirs[1], number.String()+".json")
+}